@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/configuration"
+	logger2 "url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+	"url-shortener/pkg/redis"
+	"url-shortener/pkg/zap"
+
+	"github.com/pkg/errors"
+)
+
+const keysCommand = "keys"
+
+var ErrKeysUsage = errors.New("usage: url_shortener keys create -name NAME -scopes SCOPE[,SCOPE] | list | revoke ID" +
+	" (each takes -workspace ID, default " + repository.DefaultWorkspace + ")")
+
+// runKeysCommand manages API keys from the command line, it is how the
+// first admin key gets created.
+func runKeysCommand(args []string) error {
+	if len(args) == 0 {
+		return ErrKeysUsage
+	}
+
+	cfg, err := configuration.NewAppConfiguration(os.Getenv(GolangEnv))
+	if err != nil {
+		return errors.WithMessage(err, "app configuration provider")
+	}
+
+	zapLogger, cleanupZapLogger, err := zap.New(zap.Mode(cfg.ZapLoggerMode))
+	if err != nil {
+		return errors.WithMessage(err, "zap logger provider")
+	}
+	defer cleanupZapLogger()
+
+	logger := logger2.NewLogger(zapLogger)
+
+	redisConn, redisConnCleanUp, err := redis.NewConnection(cfg.Redis, logger)
+	if err != nil {
+		return errors.WithMessage(err, "redis connection")
+	}
+	defer redisConnCleanUp()
+
+	redisRepo := repository.NewRedisRepository(redisConn)
+	auditLog := service.NewAuditLog(service.AuditConfig{Retention: cfg.Audit.Retention}, redisRepo, logger)
+	apiKeyService := service.NewAPIKeyService(redisRepo, auditLog, logger)
+
+	switch args[0] {
+	case "create":
+		return createKey(apiKeyService, args[1:])
+	case "list":
+		return listKeys(apiKeyService, args[1:])
+	case "revoke":
+		return revokeKey(apiKeyService, args[1:])
+	default:
+		return ErrKeysUsage
+	}
+}
+
+func createKey(apiKeyService *service.APIKeyService, args []string) error {
+	flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	workspace := workspaceFlag(flags)
+	name := flags.String("name", "", "what the key is used for")
+	scopes := flags.String("scopes", string(service.ScopeAdmin), "comma separated scopes: create, read, manage, admin")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	req := service.CreateAPIKeyRequest{Name: *name}
+	for _, scope := range strings.Split(*scopes, ",") {
+		req.Scopes = append(req.Scopes, service.Scope(strings.TrimSpace(scope)))
+	}
+
+	key, err := apiKeyService.Create(cliPrincipal(*workspace), req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("id:  %s\nkey: %s\n", key.ID, key.Key)
+
+	return nil
+}
+
+func listKeys(apiKeyService *service.APIKeyService, args []string) error {
+	flags := flag.NewFlagSet("keys list", flag.ContinueOnError)
+	workspace := workspaceFlag(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	keys, err := apiKeyService.List(*workspace)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tLAST USED\tREVOKED")
+
+	for _, key := range keys {
+		scopes := make([]string, len(key.Scopes))
+		for i, scope := range key.Scopes {
+			scopes[i] = string(scope)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, strings.Join(scopes, ","),
+			key.CreatedAt.Format(time.RFC3339), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+	}
+
+	return w.Flush()
+}
+
+func revokeKey(apiKeyService *service.APIKeyService, args []string) error {
+	flags := flag.NewFlagSet("keys revoke", flag.ContinueOnError)
+	workspace := workspaceFlag(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return ErrKeysUsage
+	}
+
+	return apiKeyService.Revoke(cliPrincipal(*workspace), flags.Arg(0))
+}
+
+func workspaceFlag(flags *flag.FlagSet) *string {
+	return flags.String("workspace", repository.DefaultWorkspace, "workspace the key acts in")
+}
+
+// cliPrincipal is who the audit log records command line changes by.
+func cliPrincipal(workspace string) service.Principal {
+	return service.Principal{Kind: service.PrincipalSystem, ID: "cli", Workspace: workspace}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
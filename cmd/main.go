@@ -1,18 +1,24 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	"url-shortener/internal/analytics"
 	"url-shortener/internal/configuration"
 	logger2 "url-shortener/internal/logger"
 	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/ratelimit"
 	"url-shortener/internal/repository"
+	"url-shortener/internal/scandetect"
 	"url-shortener/internal/service"
 	"url-shortener/internal/transport"
 	"url-shortener/internal/transport/handlers"
+	"url-shortener/pkg/jwt"
 	"url-shortener/pkg/redis"
 	"url-shortener/pkg/zap"
 
@@ -27,8 +33,16 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == keysCommand {
+		if err := runKeysCommand(os.Args[2:]); err != nil {
+			log.Fatal(errors.WithMessage(err, keysCommand))
+		}
+
+		return
+	}
+
 	cfg, errAppConf := configuration.NewAppConfiguration(
-		os.Getenv(GolangEnv), true)
+		os.Getenv(GolangEnv))
 	if errAppConf != nil {
 		log.Fatal(errors.WithMessage(errAppConf, "app configuration provider"))
 	}
@@ -45,6 +59,20 @@ func main() {
 		Subsystem: cfg.Metrics.Subsystem,
 	})
 
+	metricsCerts, err := transport.NewCertReloader("metrics", transport.TLSConfig{
+		Enabled:        cfg.Metrics.TLS.Enabled,
+		CertFile:       cfg.Metrics.TLS.CertFile,
+		KeyFile:        cfg.Metrics.TLS.KeyFile,
+		MinVersion:     cfg.Metrics.TLS.MinVersion,
+		CipherSuites:   cfg.Metrics.TLS.CipherSuites,
+		ClientCAFile:   cfg.Metrics.TLS.ClientCAFile,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ReloadInterval: cfg.Metrics.TLS.ReloadInterval,
+	}, logger, metricsRecorder)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "metrics tls"))
+	}
+
 	prometheusServer, prometheusServerCleanUp := transport.NewPrometheusMetricsServer(
 		transport.PrometheusMetricsServerConfig{Addr: cfg.Metrics.Addr},
 		logger,
@@ -59,16 +87,310 @@ func main() {
 	redisRepo := repository.NewRedisRepository(redisConn)
 
 	hashService := service.NewHashService(redisRepo, logger)
-	redirectService := service.NewRedirectService(redisRepo, logger)
-	urlShortenerService := service.NewURLShortenerService(hashService, redisRepo, logger, cfg.API.BaseURL)
+	auditLog := service.NewAuditLog(service.AuditConfig{Retention: cfg.Audit.Retention}, redisRepo, logger)
+	workspaceService := service.NewWorkspaceService(redisRepo, auditLog, logger)
+	accessService := service.NewAccessService(redisRepo, auditLog, logger)
+	auditService := service.NewAuditService(redisRepo, accessService, logger)
+	screeningService := service.NewScreeningService(service.ScreeningConfig{
+		BlocklistFile:    cfg.Screening.BlocklistFile,
+		AllowlistFile:    cfg.Screening.AllowlistFile,
+		ReloadInterval:   cfg.Screening.ReloadInterval,
+		RescreenInterval: cfg.Screening.RescreenInterval,
+		ShortDomains:     []string{cfg.API.BaseURL},
+	}, redisRepo, workspaceService, auditLog, logger)
+	reporterSecret, err := service.SharedSecret("reporter_secret", cfg.Moderation.ReporterSecret, redisRepo, logger)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "reporter secret"))
+	}
+
+	moderationService := service.NewModerationService(service.ModerationConfig{
+		ReporterSecret:    reporterSecret,
+		MaxReportsPerLink: cfg.Moderation.MaxReportsPerLink,
+		MaxDetailsLength:  cfg.Moderation.MaxDetailsLength,
+	}, redisRepo, workspaceService, accessService, auditLog, logger)
+	visitorCounter := analytics.NewVisitorCounter(analytics.VisitorCounterConfig{
+		Backend:         cfg.Analytics.VisitorBackend,
+		HourlyRetention: cfg.Analytics.HourlyRetention,
+		DailyRetention:  cfg.Analytics.VisitorDailyRetention,
+	}, redisRepo)
+
+	redirectService := service.NewRedirectService(redisRepo, workspaceService, visitorCounter, logger, service.InterstitialConfig{
+		Enabled:        cfg.Interstitial.Enabled,
+		TrustedDomains: cfg.Interstitial.TrustedDomains,
+	})
+	quotaWorkspaces := make(map[string]service.QuotaLimits, len(cfg.Quota.Workspaces))
+	for id, limits := range cfg.Quota.Workspaces {
+		quotaWorkspaces[id] = service.QuotaLimits{Daily: limits.Daily, Total: limits.Total}
+	}
+
+	quotaService := service.NewQuotaService(service.QuotaConfig{
+		Workspace:  service.QuotaLimits{Daily: cfg.Quota.Workspace.Daily, Total: cfg.Quota.Workspace.Total},
+		APIKey:     service.QuotaLimits{Daily: cfg.Quota.APIKey.Daily, Total: cfg.Quota.APIKey.Total},
+		Workspaces: quotaWorkspaces,
+	}, redisRepo, accessService, auditLog, logger)
+	signingKeys := make([]service.SigningKey, len(cfg.Signing.Keys))
+
+	for i, key := range cfg.Signing.Keys {
+		signingKeys[i].Secret = key.Secret
+
+		if key.RetiredAt != "" {
+			if signingKeys[i].RetiredAt, err = time.Parse(time.RFC3339, key.RetiredAt); err != nil {
+				log.Fatal(errors.WithMessage(err, "signing key retired_at"))
+			}
+		}
+	}
+
+	codeSigner, err := service.NewCodeSigner(service.SigningConfig{
+		Enabled:          cfg.Signing.Enabled,
+		Keys:             signingKeys,
+		GracePeriod:      cfg.Signing.GracePeriod,
+		SignatureLength:  cfg.Signing.SignatureLength,
+		RequireSignature: cfg.Signing.RequireSignature,
+	})
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "code signer"))
+	}
+
+	if cfg.Signing.Enabled && !cfg.Signing.RequireSignature {
+		logger.LogWarn("signing enabled without require_signature, unsigned codes are still looked up")
+	}
+
+	urlShortenerService := service.NewURLShortenerService(
+		hashService, redisRepo, workspaceService, accessService, screeningService, quotaService, codeSigner, auditLog,
+		logger, cfg.API.BaseURL)
+	statsService := service.NewStatsService(redisRepo, visitorCounter, accessService, logger)
+	apiKeyService := service.NewAPIKeyService(redisRepo, auditLog, logger)
+
+	clickBroker := analytics.NewBroker(cfg.Analytics.StreamBuffer, metricsRecorder)
+
+	sinkConfigs := make([]analytics.SinkConfig, 0, len(cfg.Analytics.Sinks))
+	sinks := make([]analytics.EventSink, 0, len(cfg.Analytics.Sinks))
+
+	for _, sinkCfg := range cfg.Analytics.Sinks {
+		sinkConfig := analytics.SinkConfig{
+			Type:          sinkCfg.Type,
+			Name:          sinkCfg.Name,
+			BatchSize:     sinkCfg.BatchSize,
+			FlushInterval: sinkCfg.FlushInterval,
+			QueueSize:     sinkCfg.QueueSize,
+			MaxRetries:    sinkCfg.MaxRetries,
+			RetryBackoff:  sinkCfg.RetryBackoff,
+			WriteTimeout:  sinkCfg.WriteTimeout,
+			Stream:        sinkCfg.Stream,
+			StreamMaxLen:  sinkCfg.StreamMaxLen,
+			Directory:     sinkCfg.Directory,
+			MaxFileSize:   sinkCfg.MaxFileSize,
+			URL:           sinkCfg.URL,
+			Headers:       sinkCfg.Headers,
+		}
+
+		sink, errSink := analytics.NewSink(sinkConfig, redisRepo)
+		if errSink != nil {
+			log.Fatal(errors.WithMessage(errSink, "event sink provider"))
+		}
+
+		sinkConfigs = append(sinkConfigs, sinkConfig)
+		sinks = append(sinks, sink)
+	}
+
+	sinkFanout := analytics.NewFanout(sinks, sinkConfigs, logger, metricsRecorder)
+
+	visitorSalt, err := service.SharedSecret("visitor_salt", cfg.Analytics.VisitorSalt, redisRepo, logger)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "visitor salt"))
+	}
+
+	privacy := analytics.NewPrivacy(analytics.PrivacyConfig{
+		IPMode:          cfg.Privacy.IPMode,
+		IPv4PrefixBits:  cfg.Privacy.IPv4PrefixBits,
+		IPv6PrefixBits:  cfg.Privacy.IPv6PrefixBits,
+		Secret:          visitorSalt,
+		SaltRotation:    cfg.Privacy.SaltRotation,
+		HonorDoNotTrack: cfg.Privacy.HonorDoNotTrack,
+	}, redisRepo, logger)
+
+	clickPipeline := analytics.NewPipeline(analytics.PipelineConfig{
+		QueueSize:     cfg.Analytics.QueueSize,
+		BatchSize:     cfg.Analytics.BatchSize,
+		FlushInterval: cfg.Analytics.FlushInterval,
+	}, redisRepo, visitorCounter, clickBroker, sinkFanout, privacy, logger, metricsRecorder)
+
+	privacyService := service.NewPrivacyService(redisRepo, visitorCounter, sinkFanout, accessService, auditLog, logger)
+
+	statsRollup := analytics.NewRollup(analytics.RollupConfig{
+		Interval:        cfg.Analytics.RollupInterval,
+		HourlyRetention: cfg.Analytics.HourlyRetention,
+		RawRetention:    cfg.Analytics.RawRetention,
+	}, redisRepo, sinkFanout, logger, metricsRecorder)
+
+	botClassifier := analytics.NewBotClassifier(analytics.BotClassifierConfig{
+		ListFile:       cfg.Analytics.BotListFile,
+		ReloadInterval: cfg.Analytics.BotListReloadInterval,
+	}, logger)
 
 	createHandler := handlers.NewCreateHandler(urlShortenerService, logger, metricsRecorder)
-	redirectHandler := handlers.NewRedirectHandler(redirectService, logger, metricsRecorder)
+	redirectHandler := handlers.NewRedirectHandler(handlers.RedirectHandlerConfig{
+		Countdown:     cfg.Interstitial.Countdown,
+		CountryHeader: cfg.Analytics.CountryHeader,
+	}, redirectService, clickPipeline, botClassifier, logger, metricsRecorder)
+
+	previewHandler := handlers.NewPreviewHandler(redirectService, logger, metricsRecorder)
+	statsHandler := handlers.NewStatsHandler(statsService, logger, metricsRecorder)
+	eventsHandler := handlers.NewEventsHandler(
+		handlers.EventsHandlerConfig{}, clickBroker, accessService, logger, metricsRecorder)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService, logger, metricsRecorder)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeyService, logger, metricsRecorder)
+	workspacesHandler := handlers.NewWorkspacesHandler(workspaceService, logger, metricsRecorder)
+	linksHandler := handlers.NewLinksHandler(urlShortenerService, accessService, logger, metricsRecorder)
+	membersHandler := handlers.NewMembersHandler(accessService, logger, metricsRecorder)
+	reportHandler := handlers.NewReportHandler(moderationService, logger, metricsRecorder)
+	moderationHandler := handlers.NewModerationHandler(moderationService, logger, metricsRecorder)
+	auditHandler := handlers.NewAuditHandler(auditService, logger, metricsRecorder)
+	quotasHandler := handlers.NewQuotasHandler(quotaService, logger, metricsRecorder)
+
+	// Service tokens come first, they have no prefix to tell them apart.
+	tokenAuthenticators := []transport.TokenAuthenticator{
+		service.NewServiceTokenAuthenticator(service.ServiceTokenConfig{
+			StreamToken:  cfg.Analytics.StreamToken,
+			ErasureToken: cfg.Privacy.ErasureToken,
+		}),
+		apiKeyService,
+		service.NewManageTokenAuthenticator(redisRepo, logger),
+	}
+
+	if cfg.Auth.JWT.JWKS != "" {
+		keySet := jwt.NewKeySet(jwt.KeySetConfig{
+			Source:          cfg.Auth.JWT.JWKS,
+			RefreshInterval: cfg.Auth.JWT.JWKSRefreshInterval,
+		})
+
+		// Keep starting when the SSO is down, the keys are fetched again on use.
+		if errKeys := keySet.Refresh(); errKeys != nil {
+			logger.LogError("load JWKS", errKeys)
+		}
+
+		roleScopes := make(map[string][]service.Scope, len(cfg.Auth.JWT.RoleScopes))
+		for role, scopes := range cfg.Auth.JWT.RoleScopes {
+			for _, scope := range scopes {
+				roleScopes[role] = append(roleScopes[role], service.Scope(scope))
+			}
+		}
+
+		tokenAuthenticators = append(tokenAuthenticators, service.NewJWTAuthenticator(service.JWTConfig{
+			Issuer:         cfg.Auth.JWT.Issuer,
+			Audience:       cfg.Auth.JWT.Audience,
+			UserClaim:      cfg.Auth.JWT.UserClaim,
+			RolesClaim:     cfg.Auth.JWT.RolesClaim,
+			WorkspaceClaim: cfg.Auth.JWT.WorkspaceClaim,
+			RoleScopes:     roleScopes,
+			Leeway:         cfg.Auth.JWT.Leeway,
+		}, keySet, workspaceService, logger))
+	}
+
+	authenticator := transport.NewAuthenticator(transport.AuthConfig{
+		AllowAnonymousCreate: cfg.Auth.AllowAnonymousCreate,
+	}, tokenAuthenticators, logger, metricsRecorder)
+
+	fastHTTPHandlers := transport.NewFastHTTPHandlers(
+		createHandler, redirectHandler, previewHandler, statsHandler, eventsHandler, privacyHandler, apiKeysHandler,
+		workspacesHandler, linksHandler, membersHandler, reportHandler, moderationHandler, auditHandler,
+		quotasHandler)
+	rateLimiter := transport.NewRateLimiter(transport.RateLimitConfig{
+		Enabled: cfg.RateLimit.Enabled,
+		Create: transport.RateLimitPolicy{
+			Policy: ratelimit.Policy{
+				Name:   "create",
+				Rate:   cfg.RateLimit.Create.Rate,
+				Period: cfg.RateLimit.Create.Period,
+				Burst:  cfg.RateLimit.Create.Burst,
+			},
+			Key: cfg.RateLimit.Create.Key,
+		},
+		Redirect: transport.RateLimitPolicy{
+			Policy: ratelimit.Policy{
+				Name:   "redirect",
+				Rate:   cfg.RateLimit.Redirect.Rate,
+				Period: cfg.RateLimit.Redirect.Period,
+				Burst:  cfg.RateLimit.Redirect.Burst,
+			},
+			Key: cfg.RateLimit.Redirect.Key,
+		},
+		Report: transport.RateLimitPolicy{
+			Policy: ratelimit.Policy{
+				Name:   "report",
+				Rate:   cfg.RateLimit.Report.Rate,
+				Period: cfg.RateLimit.Report.Period,
+				Burst:  cfg.RateLimit.Report.Burst,
+			},
+			Key: cfg.RateLimit.Report.Key,
+		},
+	}, ratelimit.NewLimiter(cfg.RateLimit.Backend, redisRepo, logger), metricsRecorder)
+
+	scanDetector, err := scandetect.NewDetector(scandetect.Config{
+		Enabled:       cfg.ScanDetection.Enabled,
+		Window:        cfg.ScanDetection.Window,
+		MinRequests:   cfg.ScanDetection.MinRequests,
+		SlowRatio:     cfg.ScanDetection.SlowRatio,
+		BlockRatio:    cfg.ScanDetection.BlockRatio,
+		SlowDelay:     cfg.ScanDetection.SlowDelay,
+		BlockDuration: cfg.ScanDetection.BlockDuration,
+		HoneypotCodes: cfg.ScanDetection.HoneypotCodes,
+		HoneypotCount: cfg.ScanDetection.HoneypotCount,
+	}, redisRepo, logger)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "scan detector"))
+	}
 
-	fastHTTPHandlers := transport.NewFastHTTPHandlers(createHandler, redirectHandler)
-	router := transport.NewFastHTTPRouter(fastHTTPHandlers)
+	// Keep starting without honeypots, scanners are still caught by their misses.
+	if errSeed := scanDetector.Seed(hashService.Generate); errSeed != nil {
+		logger.LogError("seed honeypot codes", errSeed)
+	}
+
+	serverCerts, err := transport.NewCertReloader("http", transport.TLSConfig{
+		Enabled:        cfg.TLS.Enabled,
+		CertFile:       cfg.TLS.CertFile,
+		KeyFile:        cfg.TLS.KeyFile,
+		MinVersion:     cfg.TLS.MinVersion,
+		CipherSuites:   cfg.TLS.CipherSuites,
+		ClientCAFile:   cfg.TLS.ClientCAFile,
+		ClientAuth:     tls.VerifyClientCertIfGiven,
+		ReloadInterval: cfg.TLS.ReloadInterval,
+	}, logger, metricsRecorder)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "http tls"))
+	}
 
-	server, serverCleanUp := transport.NewFastHTTPServer(router, logger)
+	adminCerts, err := transport.NewClientCertGuard(cfg.TLS.RequireAdminClientCert, serverCerts, metricsRecorder)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "admin client certificates"))
+	}
+
+	proxies, err := transport.NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "trusted proxies"))
+	}
+
+	cors, err := transport.NewCORS(transport.CORSConfig{
+		Enabled:          cfg.CORS.Enabled,
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}, metricsRecorder)
+	if err != nil {
+		log.Fatal(errors.WithMessage(err, "cors"))
+	}
+
+	router := transport.NewFastHTTPRouter(
+		fastHTTPHandlers, authenticator, rateLimiter, transport.NewCodeVerifier(codeSigner, metricsRecorder),
+		transport.NewScanGuard(scanDetector, metricsRecorder),
+		cors, adminCerts, proxies)
+
+	server, serverCleanUp := transport.NewFastHTTPServer(transport.FastHTTPServerConfig{
+		StreamWriteTimeout: cfg.Analytics.StreamMaxDuration,
+	}, router, logger)
 
 	interruptionChannel := make(chan os.Signal, 1)
 	var g run.Group
@@ -83,28 +405,78 @@ func main() {
 	})
 
 	g.Add(func() error {
+		ln, errListen := serverCerts.Listen("tcp4", cfg.Addr)
+		if errListen != nil {
+			return errListen
+		}
+
 		logger.LogInfo("fast http server",
-			fmt.Sprintf("started and listening at http://localhost%s", cfg.Addr),
+			fmt.Sprintf("started and listening at %s://localhost%s", serverCerts.Scheme(), cfg.Addr),
 		)
 
-		return server.ListenAndServe(cfg.Addr)
+		return server.Serve(ln)
 	}, func(err error) {
 		logger.LogError("fast http server", err)
+		clickBroker.Close()
 		serverCleanUp()
 	})
 
 	g.Add(func() error {
+		ln, errListen := metricsCerts.Listen("tcp", cfg.Metrics.Addr)
+		if errListen != nil {
+			return errListen
+		}
+
 		logger.LogInfo(
 			"prometheus server",
 			fmt.Sprintf("started and listening for incoming requests at: "+
-				"http://localhost%s/metrics", cfg.Metrics.Addr))
+				"%s://localhost%s/metrics", metricsCerts.Scheme(), cfg.Metrics.Addr))
 
-		return prometheusServer.ListenAndServe()
+		return prometheusServer.Serve(ln)
 	}, func(err error) {
 		logger.LogError("prometheus server", err)
 		prometheusServerCleanUp()
 	})
 
+	g.Add(func() error {
+		logger.LogInfo("click pipeline started")
+
+		return clickPipeline.Run()
+	}, func(err error) {
+		logger.LogError("click pipeline", err)
+		clickPipeline.Stop()
+	})
+
+	g.Add(func() error {
+		logger.LogInfo("link screening started")
+
+		return screeningService.Run()
+	}, func(err error) {
+		logger.LogError("link screening", err)
+		screeningService.Stop()
+	})
+
+	g.Add(func() error {
+		logger.LogInfo("stats rollup started")
+
+		return statsRollup.Run()
+	}, func(err error) {
+		logger.LogError("stats rollup", err)
+		statsRollup.Stop()
+	})
+
+	g.Add(func() error {
+		return serverCerts.Run()
+	}, func(err error) {
+		serverCerts.Stop()
+	})
+
+	g.Add(func() error {
+		return metricsCerts.Run()
+	}, func(err error) {
+		metricsCerts.Stop()
+	})
+
 	{
 		logger.LogInfo("app started")
 		logger.LogError("error", g.Run())
@@ -0,0 +1,183 @@
+package analytics
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/logger"
+)
+
+const DefaultBotListReloadInterval = time.Minute
+
+// Substrings of lowercased user agents of crawlers and link unfurlers, a
+// leading ^ anchors one at the start. Bots are caught by the bot suffix of
+// their product token or the +http link to their docs, rather than by any
+// "bot" which phone models and in-app browsers carry too.
+var defaultBotPatterns = []string{
+	"bot/", "bot;", "bot)", "bot-", "+http", "crawler", "spider", "slurp",
+	"facebookexternalhit", "facebot", "twitterbot", "slackbot", "slack-imgproxy",
+	"discordbot", "telegrambot", "^whatsapp/", "linkedinbot", "skypeuripreview",
+	"embedly", "pinterestbot", "^pinterest/", "redditbot", "applebot",
+	"bingpreview", "googleimageproxy", "baiduspider", "duckduckbot",
+	"headlesschrome", "lighthouse", "google web preview", "python-requests",
+	"go-http-client", "okhttp", "wget", "curl/",
+}
+
+// Request headers browsers send when loading a page speculatively.
+var prefetchHeaders = map[string]string{
+	"Purpose":     "prefetch",
+	"Sec-Purpose": "prefetch",
+	"X-Purpose":   "preview",
+	"X-Moz":       "prefetch",
+}
+
+type BotClassifierConfig struct {
+	// Optional file with one user agent pattern per line, # starts a comment
+	// and a leading ^ anchors the pattern at the start of the user agent.
+	ListFile       string
+	ReloadInterval time.Duration
+}
+
+// BotSignals are the parts of the request the classifier looks at.
+type BotSignals struct {
+	Method    string
+	UserAgent string
+	Header    func(name string) string
+}
+
+// BotClassifier tells automated traffic from people. The pattern list file is
+// re-read when it changes on disk.
+type BotClassifier struct {
+	cfg       BotClassifierConfig
+	logger    *logger.Logger
+	patterns  atomic.Value
+	mu        sync.Mutex
+	checkedAt time.Time
+	modTime   time.Time
+}
+
+func NewBotClassifier(cfg BotClassifierConfig, logger *logger.Logger) *BotClassifier {
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultBotListReloadInterval
+	}
+
+	c := &BotClassifier{
+		cfg:    cfg,
+		logger: logger,
+	}
+	c.patterns.Store(defaultBotPatterns)
+	c.reload(time.Now())
+
+	return c
+}
+
+func (c *BotClassifier) IsBot(signals BotSignals) bool {
+	if signals.Method == "HEAD" {
+		return true
+	}
+
+	for name, value := range prefetchHeaders {
+		// Chrome adds parameters, as in "prefetch;prerender".
+		purpose, _, _ := strings.Cut(signals.Header(name), ";")
+		if strings.EqualFold(strings.TrimSpace(purpose), value) {
+			return true
+		}
+	}
+
+	userAgent := strings.ToLower(signals.UserAgent)
+	if userAgent == "" {
+		return true
+	}
+
+	c.maybeReload()
+
+	for _, pattern := range c.patterns.Load().([]string) {
+		if matchesBotPattern(userAgent, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesBotPattern(userAgent, pattern string) bool {
+	if prefix := strings.TrimPrefix(pattern, "^"); prefix != pattern {
+		return strings.HasPrefix(userAgent, prefix)
+	}
+
+	return strings.Contains(userAgent, pattern)
+}
+
+func (c *BotClassifier) maybeReload() {
+	if c.cfg.ListFile == "" {
+		return
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	stale := now.Sub(c.checkedAt) >= c.cfg.ReloadInterval
+	c.mu.Unlock()
+
+	if stale {
+		c.reload(now)
+	}
+}
+
+func (c *BotClassifier) reload(now time.Time) {
+	if c.cfg.ListFile == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkedAt = now
+
+	info, err := os.Stat(c.cfg.ListFile)
+	if err != nil {
+		c.logger.LogError("stat bot list", err)
+
+		return
+	}
+
+	if !info.ModTime().After(c.modTime) {
+		return
+	}
+
+	patterns, err := readBotList(c.cfg.ListFile)
+	if err != nil {
+		c.logger.LogError("read bot list", err)
+
+		return
+	}
+
+	c.modTime = info.ModTime()
+	c.patterns.Store(append(append([]string{}, defaultBotPatterns...), patterns...))
+	c.logger.LogInfo("bot list loaded", len(patterns))
+}
+
+func readBotList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, strings.ToLower(line))
+	}
+
+	return patterns, scanner.Err()
+}
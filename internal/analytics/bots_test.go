@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+var botUserAgents = []string{
+	"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"Googlebot-Image/1.0",
+	"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+	"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+	"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)",
+	"DuckDuckBot/1.1; (+http://duckduckgo.com/duckduckbot.html)",
+	"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)",
+	"Mozilla/5.0 (Linux; Android 7.0;) AppleWebKit/537.36 (KHTML, like Gecko) Mobile Safari/537.36 (compatible; PetalBot;+https://webmaster.petalsearch.com/site/petalbot)",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.1 Safari/605.1.15 (Applebot/0.1; +http://www.apple.com/go/applebot)",
+	"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+	"Twitterbot/1.0",
+	"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+	"Slack-ImgProxy (+https://api.slack.com/robots)",
+	"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+	"TelegramBot (like TwitterBot)",
+	"WhatsApp/2.23.20.0 A",
+	"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)",
+	"Mozilla/5.0 (Windows NT 6.1; WOW64) SkypeUriPreview Preview/0.5",
+	"Pinterest/0.2 (+http://www.pinterest.com/bot.html)",
+	"Mozilla/5.0 (compatible; Pinterestbot/1.0; +http://www.pinterest.com/bot.html)",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (compatible; GPTBot/1.0; +https://openai.com/gptbot)",
+	"Mozilla/5.0 (compatible; Yahoo! Slurp; http://help.yahoo.com/help/us/ysearch/slurp)",
+	"python-requests/2.31.0",
+	"Go-http-client/1.1",
+	"okhttp/4.12.0",
+	"Wget/1.21.4",
+	"curl/8.4.0",
+}
+
+var browserUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+	// Phone models with "bot" in their name.
+	"Mozilla/5.0 (Linux; Android 10; CUBOT X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (Linux; Android 12; CUBOT_KINGKONG_7 Build/SP1A.210812.016) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Mobile Safari/537.36",
+	// In-app browsers.
+	"Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Mobile Safari/537.36 [Pinterest/Android]",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [Pinterest/iOS]",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/441.0.0.33.113;FBBV/537000000]",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 309.0.0.28.112",
+	"Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Mobile Safari/537.36 WhatsApp/2.23.20",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 LinkedInApp/9.29.1",
+	"Mozilla/5.0 (Linux; Android 13; SM-A536B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 YaBrowser/23.11.0.0 Mobile Safari/537.36",
+}
+
+func botSignals(method, userAgent string, headers map[string]string) BotSignals {
+	return BotSignals{
+		Method:    method,
+		UserAgent: userAgent,
+		Header:    func(name string) string { return headers[name] },
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	classifier := NewBotClassifier(BotClassifierConfig{}, logger.NewLogger(zap.NewNop()))
+
+	for _, userAgent := range botUserAgents {
+		if !classifier.IsBot(botSignals("GET", userAgent, nil)) {
+			t.Errorf("bot taken for a person: %s", userAgent)
+		}
+	}
+
+	for _, userAgent := range browserUserAgents {
+		if classifier.IsBot(botSignals("GET", userAgent, nil)) {
+			t.Errorf("browser taken for a bot: %s", userAgent)
+		}
+	}
+
+	browser := browserUserAgents[0]
+
+	tests := []struct {
+		name    string
+		signals BotSignals
+	}{
+		{name: "no user agent", signals: botSignals("GET", "", nil)},
+		{name: "HEAD request", signals: botSignals("HEAD", browser, nil)},
+		{name: "prefetch", signals: botSignals("GET", browser, map[string]string{"Sec-Purpose": "prefetch;prerender"})},
+		{name: "legacy prefetch", signals: botSignals("GET", browser, map[string]string{"Purpose": "Prefetch"})},
+		{name: "firefox prefetch", signals: botSignals("GET", browser, map[string]string{"X-Moz": "prefetch"})},
+		{name: "safari preview", signals: botSignals("GET", browser, map[string]string{"X-Purpose": "preview"})},
+	}
+
+	for _, tt := range tests {
+		if !classifier.IsBot(tt.signals) {
+			t.Errorf("%s: not taken for a bot", tt.name)
+		}
+	}
+}
+
+func TestIsBotReadsListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bots.txt")
+	if err := os.WriteFile(path, []byte("# internal monitors\nUptimeChecker\n^acme-\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	classifier := NewBotClassifier(BotClassifierConfig{ListFile: path, ReloadInterval: time.Nanosecond},
+		logger.NewLogger(zap.NewNop()))
+
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{userAgent: "Mozilla/5.0 (compatible; UptimeChecker 2.0)", want: true},
+		{userAgent: "acme-probe/1.0", want: true},
+		{userAgent: "Mozilla/5.0 (X11; Linux x86_64) acme-probe/1.0"},
+		{userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)", want: true},
+		{userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Firefox/121.0"},
+	}
+
+	for _, tt := range tests {
+		if got := classifier.IsBot(botSignals("GET", tt.userAgent, nil)); got != tt.want {
+			t.Errorf("IsBot(%s) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+
+	// The file is re-read once it changes.
+	later := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("firefox/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if !classifier.IsBot(botSignals("GET", "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Firefox/121.0", nil)) {
+		t.Error("changed bot list not reloaded")
+	}
+}
@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"sync"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
+)
+
+const DefaultSubscriberBuffer = 256
+
+// Subscription receives the click events of one link of a workspace, or of
+// all its links when the code is empty. Done is closed when the
+// subscription ends, either because it was cancelled or because the
+// subscriber fell behind.
+type Subscription struct {
+	Events <-chan repository.ClickEvent
+	Done   <-chan struct{}
+
+	workspace string
+	code      string
+	events    chan repository.ClickEvent
+	done      chan struct{}
+}
+
+// Broker fans click events out to live subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is disconnected.
+type Broker struct {
+	mu              sync.Mutex
+	bufferSize      int
+	subscribers     map[*Subscription]struct{}
+	closed          bool
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewBroker(bufferSize int, metricsRecorder *prometheus.MetricsRecorder) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriberBuffer
+	}
+
+	return &Broker{
+		bufferSize:      bufferSize,
+		subscribers:     map[*Subscription]struct{}{},
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (b *Broker) Subscribe(workspace, code string) *Subscription {
+	events := make(chan repository.ClickEvent, b.bufferSize)
+	done := make(chan struct{})
+
+	sub := &Subscription{
+		Events:    events,
+		Done:      done,
+		workspace: workspaceOrDefault(workspace),
+		code:      code,
+		events:    events,
+		done:      done,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(done)
+
+		return sub
+	}
+
+	b.subscribers[sub] = struct{}{}
+	b.metricsRecorder.SetEventStreamSubscribers(len(b.subscribers))
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+func (b *Broker) Publish(events []repository.ClickEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		for _, event := range events {
+			if sub.workspace != workspaceOrDefault(event.Workspace) || (sub.code != "" && sub.code != event.Code) {
+				continue
+			}
+
+			select {
+			case sub.events <- event:
+			default:
+				b.remove(sub)
+				b.metricsRecorder.RecordEventStreamSlowConsumer()
+			}
+
+			if _, ok := b.subscribers[sub]; !ok {
+				break
+			}
+		}
+	}
+}
+
+// Close ends all subscriptions so that open streams let the server shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+
+	b.closed = true
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers, sub)
+	close(sub.done)
+	b.metricsRecorder.SetEventStreamSubscribers(len(b.subscribers))
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"net/url"
+	"strings"
+	"url-shortener/internal/repository"
+)
+
+const (
+	ReferrerDirect = "direct"
+	Unknown        = "unknown"
+
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+)
+
+type browserRule struct {
+	token string
+	name  string
+}
+
+// Order matters: most browsers also claim to be Safari or Chrome.
+var browserRules = []browserRule{
+	{token: "edg/", name: "edge"},
+	{token: "opr/", name: "opera"},
+	{token: "samsungbrowser/", name: "samsung"},
+	{token: "firefox/", name: "firefox"},
+	{token: "fxios/", name: "firefox"},
+	{token: "crios/", name: "chrome"},
+	{token: "chrome/", name: "chrome"},
+	{token: "safari/", name: "safari"},
+	{token: "curl/", name: "curl"},
+}
+
+// Enrich fills the fields derived from the raw request data.
+func Enrich(event *repository.ClickEvent) {
+	event.ReferrerDomain = ReferrerDomain(event.Referrer)
+	event.Device, event.Browser = ParseUserAgent(event.UserAgent)
+
+	if event.Country == "" {
+		event.Country = Unknown
+	} else {
+		event.Country = strings.ToUpper(event.Country)
+	}
+}
+
+func ReferrerDomain(referrer string) string {
+	if referrer == "" {
+		return ReferrerDirect
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return Unknown
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func ParseUserAgent(userAgent string) (device, browser string) {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		device = DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		device = DeviceMobile
+	default:
+		device = DeviceDesktop
+	}
+
+	browser = Unknown
+
+	for _, rule := range browserRules {
+		if strings.Contains(ua, rule.token) {
+			browser = rule.name
+
+			break
+		}
+	}
+
+	return device, browser
+}
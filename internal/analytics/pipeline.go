@@ -0,0 +1,172 @@
+package analytics
+
+import (
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultQueueSize     = 10000
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = time.Second
+)
+
+type PipelineConfig struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Pipeline takes click events off the redirect path: Track never blocks,
+// events are queued in memory, stripped of personal data, aggregated in
+// batches by Run and handed to the event sinks. Raw addresses never leave
+// the queue.
+type Pipeline struct {
+	cfg             PipelineConfig
+	repo            *repository.RedisRepository
+	visitors        VisitorCounter
+	broker          *Broker
+	sinks           *Fanout
+	privacy         *Privacy
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+	queue           chan repository.ClickEvent
+	stop            chan struct{}
+	done            chan struct{}
+}
+
+func NewPipeline(
+	cfg PipelineConfig,
+	redisRepo *repository.RedisRepository,
+	visitors VisitorCounter,
+	broker *Broker,
+	sinks *Fanout,
+	privacy *Privacy,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *Pipeline {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+
+	return &Pipeline{
+		cfg:             cfg,
+		repo:            redisRepo,
+		visitors:        visitors,
+		broker:          broker,
+		sinks:           sinks,
+		privacy:         privacy,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+		queue:           make(chan repository.ClickEvent, cfg.QueueSize),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// Track queues the event, it is dropped when the queue is full.
+func (p *Pipeline) Track(event repository.ClickEvent) {
+	select {
+	case p.queue <- event:
+		p.metricsRecorder.RecordClickQueued()
+	default:
+		p.metricsRecorder.RecordClickDropped()
+	}
+}
+
+// Run flushes queued events until Stop is called, then flushes what is left
+// and waits for the sinks to deliver it.
+func (p *Pipeline) Run() error {
+	defer close(p.done)
+
+	p.sinks.Start()
+	defer p.sinks.Stop()
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]repository.ClickEvent, 0, p.cfg.BatchSize)
+
+	for {
+		select {
+		case event := <-p.queue:
+			batch = append(batch, event)
+			if len(batch) >= p.cfg.BatchSize {
+				batch = p.flush(batch)
+			}
+		case <-ticker.C:
+			batch = p.flush(batch)
+		case <-p.stop:
+			for {
+				select {
+				case event := <-p.queue:
+					batch = append(batch, event)
+					if len(batch) >= p.cfg.BatchSize {
+						batch = p.flush(batch)
+					}
+				default:
+					p.flush(batch)
+
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Stop makes Run return after the final flush.
+func (p *Pipeline) Stop() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+
+	<-p.done
+}
+
+func (p *Pipeline) flush(batch []repository.ClickEvent) []repository.ClickEvent {
+	p.metricsRecorder.SetClickQueueLength(len(p.queue))
+
+	if len(batch) == 0 {
+		return batch
+	}
+
+	for i := range batch {
+		Enrich(&batch[i])
+		p.privacy.Apply(&batch[i])
+	}
+
+	p.broker.Publish(batch)
+	p.sinks.Write(batch)
+
+	for workspace, events := range repository.GroupByWorkspace(batch) {
+		repo := p.repo.InWorkspace(workspace)
+
+		if err := repo.IncrementLinkStats(events); err != nil {
+			p.logger.LogError("aggregate click events", err)
+			p.metricsRecorder.RecordClickFlushFailed(len(events))
+		} else {
+			p.metricsRecorder.RecordClickFlushed(len(events))
+		}
+
+		if err := repo.IncrementLeaderboards(events); err != nil {
+			p.logger.LogError("update leaderboards", err)
+		}
+	}
+
+	if err := p.visitors.Add(batch); err != nil {
+		p.logger.LogError("count unique visitors", err)
+	}
+
+	return batch[:0]
+}
@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	IPModeTruncate = "truncate"
+	IPModeHash     = "hash"
+	IPModeDrop     = "drop"
+
+	DefaultIPv4PrefixBits = 24
+	DefaultIPv6PrefixBits = 48
+
+	visitorIDLength = 16
+	hashedIPLength  = 16
+	saltLength      = 32
+)
+
+type PrivacyConfig struct {
+	// How visitor addresses are stored. Valid values: truncate, hash, drop
+	IPMode         string
+	IPv4PrefixBits int
+	IPv6PrefixBits int
+	// Static secret mixed into every hash.
+	Secret string
+	// Period after which a fresh random salt is used for visitor and address
+	// hashes, 0 keeps the secret alone. Hashes from different periods can't
+	// be linked, so unique visitors are only exact within one period.
+	SaltRotation time.Duration
+	// Skip per-visitor data for requests sending DNT: 1 or Sec-GPC: 1.
+	HonorDoNotTrack bool
+}
+
+// Privacy strips the click events of personal data before they leave the
+// process. The salts are shared between instances through Redis.
+type Privacy struct {
+	cfg    PrivacyConfig
+	repo   *repository.RedisRepository
+	logger *logger.Logger
+
+	mu    sync.Mutex
+	salts map[int64]string
+}
+
+func NewPrivacy(cfg PrivacyConfig, redisRepo *repository.RedisRepository, logger *logger.Logger) *Privacy {
+	if cfg.IPMode == "" {
+		cfg.IPMode = IPModeTruncate
+	}
+
+	if cfg.IPv4PrefixBits <= 0 || cfg.IPv4PrefixBits > 8*net.IPv4len {
+		cfg.IPv4PrefixBits = DefaultIPv4PrefixBits
+	}
+
+	if cfg.IPv6PrefixBits <= 0 || cfg.IPv6PrefixBits > 8*net.IPv6len {
+		cfg.IPv6PrefixBits = DefaultIPv6PrefixBits
+	}
+
+	return &Privacy{
+		cfg:    cfg,
+		repo:   redisRepo,
+		logger: logger,
+		salts:  map[int64]string{},
+	}
+}
+
+// Apply replaces the raw address with its configured form and derives the
+// visitor id. Events opted out of tracking lose everything that identifies
+// the visitor, only coarse dimensions derived from it survive.
+func (p *Privacy) Apply(event *repository.ClickEvent) {
+	if p.cfg.HonorDoNotTrack && event.DoNotTrack {
+		event.IP = ""
+		event.UserAgent = ""
+		event.Referrer = ""
+		event.VisitorID = ""
+
+		return
+	}
+
+	salt := p.salt(event.Timestamp)
+	event.VisitorID = hash(salt, event.IP+"|"+event.UserAgent, visitorIDLength)
+
+	switch p.cfg.IPMode {
+	case IPModeHash:
+		event.IP = hash(salt, event.IP, hashedIPLength)
+	case IPModeDrop:
+		event.IP = ""
+	default:
+		event.IP = TruncateIP(event.IP, p.cfg.IPv4PrefixBits, p.cfg.IPv6PrefixBits)
+	}
+}
+
+// salt returns the secret combined with the salt of the rotation period the
+// time falls into.
+func (p *Privacy) salt(t time.Time) string {
+	if p.cfg.SaltRotation <= 0 {
+		return p.cfg.Secret
+	}
+
+	period := t.UnixNano() / int64(p.cfg.SaltRotation)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if salt, ok := p.salts[period]; ok {
+		return salt
+	}
+
+	random := make([]byte, saltLength)
+	if _, err := rand.Read(random); err != nil {
+		p.logger.LogError("generate salt", err)
+
+		return p.cfg.Secret
+	}
+
+	shared, err := p.repo.SharedSalt(strconv.FormatInt(period, 10), hex.EncodeToString(random), 2*p.cfg.SaltRotation)
+	if err != nil {
+		p.logger.LogError("load salt", err)
+
+		return p.cfg.Secret
+	}
+
+	// Earlier periods are not needed anymore, events arrive in order.
+	for old := range p.salts {
+		if old < period {
+			delete(p.salts, old)
+		}
+	}
+
+	p.salts[period] = p.cfg.Secret + shared
+
+	return p.salts[period]
+}
+
+// TruncateIP zeroes the host part of the address after the given prefixes.
+func TruncateIP(addr string, ipv4PrefixBits, ipv6PrefixBits int) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(ipv4PrefixBits, 8*net.IPv4len)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(ipv6PrefixBits, 8*net.IPv6len)).String()
+}
+
+func hash(salt, value string, length int) string {
+	if value == "" || value == "|" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(salt))
+	_, _ = mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))[:length]
+}
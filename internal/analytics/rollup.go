@@ -0,0 +1,97 @@
+package analytics
+
+import (
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
+)
+
+const DefaultRollupInterval = time.Minute
+
+type RollupConfig struct {
+	Interval time.Duration
+	// Hourly series are dropped after this long, daily ones are kept.
+	HourlyRetention time.Duration
+	// Raw events older than this are removed from the sinks keeping them.
+	RawRetention time.Duration
+}
+
+// Rollup periodically folds minute click counters into the hourly and daily
+// series and enforces the raw event retention.
+type Rollup struct {
+	cfg             RollupConfig
+	repo            *repository.RedisRepository
+	sinks           *Fanout
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+	stop            chan struct{}
+}
+
+func NewRollup(
+	cfg RollupConfig,
+	redisRepo *repository.RedisRepository,
+	sinks *Fanout,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *Rollup {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRollupInterval
+	}
+
+	return &Rollup{
+		cfg:             cfg,
+		repo:            redisRepo,
+		sinks:           sinks,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+		stop:            make(chan struct{}),
+	}
+}
+
+func (r *Rollup) Run() error {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.run(now)
+		case <-r.stop:
+			return nil
+		}
+	}
+}
+
+func (r *Rollup) Stop() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+}
+
+func (r *Rollup) run(now time.Time) {
+	workspaces, err := r.repo.Workspaces()
+	if err != nil {
+		r.logger.LogError("list workspaces", err)
+	}
+
+	repos := []*repository.RedisRepository{r.repo.InWorkspace(repository.DefaultWorkspace)}
+	for _, workspace := range workspaces {
+		repos = append(repos, r.repo.InWorkspace(workspace.ID))
+	}
+
+	for _, repo := range repos {
+		// Only complete minutes are rolled up, the current one is still written.
+		rolled, errRollup := repo.RollupLinkStats(now.Truncate(time.Minute), r.cfg.HourlyRetention)
+		if errRollup != nil {
+			r.logger.LogError("roll up link stats", errRollup)
+		}
+
+		r.metricsRecorder.RecordStatsRollup(rolled)
+	}
+
+	if r.cfg.RawRetention > 0 {
+		r.sinks.Prune(now.Add(-r.cfg.RawRetention))
+	}
+}
@@ -0,0 +1,292 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
+)
+
+const (
+	SinkTypeRedis   = "redis"
+	SinkTypeFile    = "file"
+	SinkTypeWebhook = "webhook"
+
+	DefaultSinkQueueSize    = 1000
+	DefaultSinkRetryBackoff = 500 * time.Millisecond
+	DefaultSinkWriteTimeout = 10 * time.Second
+)
+
+var ErrUnknownSinkType = errors.New("unknown event sink type")
+
+// EventSink delivers batches of enriched click events somewhere outside of
+// the process. Write is retried by the caller, so it should not retry itself.
+type EventSink interface {
+	Name() string
+	Write(ctx context.Context, events []repository.ClickEvent) error
+	Close() error
+}
+
+// PruningSink is implemented by sinks that keep the raw events themselves
+// and can drop the ones past the retention.
+type PruningSink interface {
+	Prune(before time.Time) error
+}
+
+// ErasingSink is implemented by sinks that can delete the raw events of a
+// link. Events already delivered elsewhere, like to a webhook, are out of
+// reach and have to be erased downstream.
+type ErasingSink interface {
+	Erase(workspace, code string) error
+}
+
+type SinkConfig struct {
+	Type string
+	// Defaults to the type, it labels the sink metrics.
+	Name string
+
+	BatchSize     int
+	FlushInterval time.Duration
+	// Batches waiting for the sink before new ones are dropped.
+	QueueSize    int
+	MaxRetries   int
+	RetryBackoff time.Duration
+	WriteTimeout time.Duration
+
+	// redis
+	Stream       string
+	StreamMaxLen int64
+
+	// file
+	Directory   string
+	MaxFileSize int64
+
+	// webhook
+	URL     string
+	Headers map[string]string
+}
+
+// NewSink builds one of the built-in sinks.
+func NewSink(cfg SinkConfig, redisRepo *repository.RedisRepository) (EventSink, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+
+	switch cfg.Type {
+	case SinkTypeRedis:
+		return NewRedisStreamSink(name, cfg.Stream, cfg.StreamMaxLen, redisRepo), nil
+	case SinkTypeFile:
+		return NewFileSink(name, cfg.Directory, cfg.MaxFileSize)
+	case SinkTypeWebhook:
+		return NewWebhookSink(name, cfg.URL, cfg.Headers, cfg.WriteTimeout), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSinkType, cfg.Type)
+	}
+}
+
+// Fanout hands every batch to all sinks. Each sink has its own queue,
+// batching and retries, so a slow or failing sink never holds up the others.
+type Fanout struct {
+	workers []*sinkWorker
+}
+
+func NewFanout(
+	sinks []EventSink,
+	configs []SinkConfig,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *Fanout {
+	f := &Fanout{}
+
+	for i, sink := range sinks {
+		f.workers = append(f.workers, newSinkWorker(sink, configs[i], logger, metricsRecorder))
+	}
+
+	return f
+}
+
+func (f *Fanout) Start() {
+	for _, w := range f.workers {
+		go w.run()
+	}
+}
+
+// Write queues a copy of the batch for every sink without blocking.
+func (f *Fanout) Write(events []repository.ClickEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	batch := make([]repository.ClickEvent, len(events))
+	copy(batch, events)
+
+	for _, w := range f.workers {
+		w.enqueue(batch)
+	}
+}
+
+// Prune drops raw events older than before from every sink that keeps them.
+func (f *Fanout) Prune(before time.Time) {
+	for _, w := range f.workers {
+		sink, ok := w.sink.(PruningSink)
+		if !ok {
+			continue
+		}
+
+		if err := sink.Prune(before); err != nil {
+			w.logger.LogError("prune event sink "+w.sink.Name(), err)
+		}
+	}
+}
+
+// Erase deletes the raw events of the workspace link from every sink that
+// keeps them.
+func (f *Fanout) Erase(workspace, code string) error {
+	for _, w := range f.workers {
+		sink, ok := w.sink.(ErasingSink)
+		if !ok {
+			continue
+		}
+
+		if err := sink.Erase(workspace, code); err != nil {
+			return fmt.Errorf("erase from event sink %s: %w", w.sink.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Stop writes out what the sinks still hold and closes them.
+func (f *Fanout) Stop() {
+	for _, w := range f.workers {
+		close(w.stop)
+	}
+
+	for _, w := range f.workers {
+		<-w.done
+
+		if err := w.sink.Close(); err != nil {
+			w.logger.LogError("close event sink "+w.sink.Name(), err)
+		}
+	}
+}
+
+type sinkWorker struct {
+	sink            EventSink
+	cfg             SinkConfig
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+	queue           chan []repository.ClickEvent
+	stop            chan struct{}
+	done            chan struct{}
+}
+
+func newSinkWorker(
+	sink EventSink,
+	cfg SinkConfig,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *sinkWorker {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultSinkQueueSize
+	}
+
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultSinkRetryBackoff
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultSinkWriteTimeout
+	}
+
+	return &sinkWorker{
+		sink:            sink,
+		cfg:             cfg,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+		queue:           make(chan []repository.ClickEvent, cfg.QueueSize),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+func (w *sinkWorker) enqueue(batch []repository.ClickEvent) {
+	select {
+	case w.queue <- batch:
+	default:
+		w.metricsRecorder.RecordSinkEvents(w.sink.Name(), metrics.SinkDropped, len(batch))
+	}
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var pending []repository.ClickEvent
+
+	for {
+		select {
+		case batch := <-w.queue:
+			pending = append(pending, batch...)
+			if len(pending) >= w.cfg.BatchSize {
+				w.write(pending)
+				pending = nil
+			}
+		case <-ticker.C:
+			w.write(pending)
+			pending = nil
+		case <-w.stop:
+			for {
+				select {
+				case batch := <-w.queue:
+					pending = append(pending, batch...)
+				default:
+					w.write(pending)
+
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *sinkWorker) write(events []repository.ClickEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	var err error
+
+	for attempt := 0; attempt <= w.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			w.metricsRecorder.RecordSinkRetry(w.sink.Name())
+			time.Sleep(w.cfg.RetryBackoff << (attempt - 1))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.cfg.WriteTimeout)
+		err = w.sink.Write(ctx, events)
+		cancel()
+
+		if err == nil {
+			w.metricsRecorder.RecordSinkEvents(w.sink.Name(), metrics.SinkWritten, len(events))
+
+			return
+		}
+	}
+
+	w.logger.LogError("write to event sink "+w.sink.Name(), err)
+	w.metricsRecorder.RecordSinkEvents(w.sink.Name(), metrics.SinkFailed, len(events))
+}
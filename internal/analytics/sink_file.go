@@ -0,0 +1,299 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"url-shortener/internal/repository"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	DefaultMaxFileSize = 100 << 20
+
+	activeFileName = "clicks.ndjson"
+	rotatedPrefix  = "clicks-"
+	rotatedSuffix  = ".ndjson"
+	rotatedLayout  = "20060102T150405.000000000"
+)
+
+// eventRecord is the exported shape of a click event.
+type eventRecord struct {
+	Timestamp      time.Time `json:"ts"`
+	Workspace      string    `json:"workspace"`
+	Code           string    `json:"code"`
+	Referrer       string    `json:"referrer"`
+	ReferrerDomain string    `json:"referrerDomain"`
+	UserAgent      string    `json:"userAgent"`
+	IP             string    `json:"ip"`
+	Country        string    `json:"country"`
+	Device         string    `json:"device"`
+	Browser        string    `json:"browser"`
+	VisitorID      string    `json:"visitor"`
+	Bot            bool      `json:"bot"`
+}
+
+func newEventRecord(event repository.ClickEvent) eventRecord {
+	return eventRecord{
+		Timestamp:      event.Timestamp,
+		Workspace:      event.Workspace,
+		Code:           event.Code,
+		Referrer:       event.Referrer,
+		ReferrerDomain: event.ReferrerDomain,
+		UserAgent:      event.UserAgent,
+		IP:             event.IP,
+		Country:        event.Country,
+		Device:         event.Device,
+		Browser:        event.Browser,
+		VisitorID:      event.VisitorID,
+		Bot:            event.Bot,
+	}
+}
+
+// FileSink appends newline delimited JSON to clicks.ndjson in its directory
+// and moves the file aside once it grows past maxSize.
+type FileSink struct {
+	name      string
+	directory string
+	maxSize   int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewFileSink(name, directory string, maxSize int64) (*FileSink, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFileSize
+	}
+
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("create sink directory: %w", err)
+	}
+
+	s := &FileSink{
+		name:      name,
+		directory: directory,
+		maxSize:   maxSize,
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+func (s *FileSink) Write(_ context.Context, events []repository.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter := &countingWriter{w: s.file}
+	w := bufio.NewWriter(counter)
+	encoder := json.NewEncoder(w)
+
+	var err error
+
+	for _, event := range events {
+		if err = encoder.Encode(newEventRecord(event)); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	// The buffer flushes on its own once full, so the bytes are counted on
+	// their way to the file rather than in the buffer.
+	s.size += counter.n
+
+	if err != nil {
+		return err
+	}
+
+	if s.size >= s.maxSize {
+		return s.rotate()
+	}
+
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// Prune removes rotated files that were rotated before the given time. The
+// time is read from the file name, erasures rewrite files and would reset
+// their modification time.
+func (s *FileSink) Prune(before time.Time) error {
+	rotated, err := filepath.Glob(filepath.Join(s.directory, rotatedPrefix+"*"+rotatedSuffix))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range rotated {
+		name := filepath.Base(path)
+
+		rotatedAt, err := time.Parse(rotatedLayout, name[len(rotatedPrefix):len(name)-len(rotatedSuffix)])
+		if err != nil {
+			continue
+		}
+
+		if rotatedAt.Before(before) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Erase rewrites every file holding events of the workspace link without
+// them.
+func (s *FileSink) Erase(workspace, code string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.directory, rotatedPrefix+"*"+rotatedSuffix))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		if err = eraseFromFile(path, workspace, code); err != nil {
+			return err
+		}
+	}
+
+	if err = s.file.Close(); err != nil {
+		return err
+	}
+
+	// Reopen even when the rewrite failed, every later write would fail
+	// otherwise.
+	defer func() {
+		if errOpen := s.open(); err == nil {
+			err = errOpen
+		}
+	}()
+
+	return eraseFromFile(filepath.Join(s.directory, activeFileName), workspace, code)
+}
+
+// eraseFromFile leaves files without events of the link untouched.
+func eraseFromFile(path, workspace, code string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".tmp"
+
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	erased := 0
+
+	for scanner.Scan() {
+		var record struct {
+			Workspace string `json:"workspace"`
+			Code      string `json:"code"`
+		}
+
+		if json.Unmarshal(scanner.Bytes(), &record) == nil &&
+			record.Code == code && workspaceOrDefault(record.Workspace) == workspace {
+			erased++
+
+			continue
+		}
+
+		_, _ = w.Write(scanner.Bytes())
+		_ = w.WriteByte('\n')
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if erased == 0 {
+		return nil
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(filepath.Join(s.directory, activeFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open sink file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("stat sink file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := rotatedPrefix + time.Now().UTC().Format(rotatedLayout) + rotatedSuffix
+	if err := os.Rename(filepath.Join(s.directory, activeFileName), filepath.Join(s.directory, rotated)); err != nil {
+		return fmt.Errorf("rotate sink file: %w", err)
+	}
+
+	return s.open()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,238 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/repository"
+)
+
+func TestFileSinkRotatesLargeBatch(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := NewFileSink("file", dir, 8<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// Well past the 4 KiB bufio buffer, so it flushes during the batch.
+	events := make([]repository.ClickEvent, 64)
+	for i := range events {
+		events[i] = repository.ClickEvent{
+			Timestamp: time.Now(),
+			Code:      "abc",
+			UserAgent: strings.Repeat("a", 200),
+		}
+	}
+
+	if err = sink.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "clicks-*.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rotated) != 1 {
+		t.Fatalf("got %d rotated files, want 1", len(rotated))
+	}
+
+	if lines := countLines(t, rotated[0]); lines != len(events) {
+		t.Errorf("rotated file has %d events, want %d", lines, len(events))
+	}
+
+	info, err := os.Stat(filepath.Join(dir, activeFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 0 {
+		t.Errorf("active file has %d bytes after rotation, want 0", info.Size())
+	}
+}
+
+func TestFileSinkCountsSizeAcrossWrites(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := NewFileSink("file", dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 3; i++ {
+		events := make([]repository.ClickEvent, 40)
+		for j := range events {
+			events[j] = repository.ClickEvent{Code: "abc", UserAgent: strings.Repeat("b", 150)}
+		}
+
+		if err = sink.Write(context.Background(), events); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, activeFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sink.size != info.Size() {
+		t.Errorf("sink counted %d bytes, file has %d", sink.size, info.Size())
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+
+	return lines
+}
+
+func TestFileSinkPrunesByRotationTime(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := NewFileSink("file", dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	now := time.Now().UTC()
+	old := writeRotated(t, dir, now.Add(-48*time.Hour), "old")
+	recent := writeRotated(t, dir, now.Add(-time.Hour), "abc")
+	stray := filepath.Join(dir, "clicks-copy.ndjson")
+
+	if err = os.WriteFile(stray, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Erasing rewrites the old file, which must not make it look recent.
+	if err = sink.Erase(repository.DefaultWorkspace, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sink.Prune(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, wantKept := range map[string]bool{old: false, recent: true, stray: true} {
+		if _, err := os.Stat(path); (err == nil) != wantKept {
+			t.Errorf("%s kept = %v, want %v", filepath.Base(path), err == nil, wantKept)
+		}
+	}
+}
+
+func TestFileSinkErase(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := NewFileSink("file", dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	rotatedAt := time.Now().UTC().Add(-time.Hour)
+	rotated := writeRotated(t, dir, rotatedAt, "abc", "xyz", "abc")
+	untouched := writeRotated(t, dir, rotatedAt.Add(time.Minute), "xyz")
+
+	before, err := os.Stat(untouched)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []repository.ClickEvent{
+		{Code: "abc"},
+		{Code: "abc", Workspace: "acme"},
+		{Code: "xyz"},
+	}
+	if err = sink.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sink.Erase(repository.DefaultWorkspace, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := countLines(t, rotated); lines != 1 {
+		t.Errorf("rotated file has %d events, want 1", lines)
+	}
+
+	if lines := countLines(t, filepath.Join(dir, activeFileName)); lines != 2 {
+		t.Errorf("active file has %d events, want the other workspace and link", lines)
+	}
+
+	if after, err := os.Stat(untouched); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Error("file without events of the link was rewritten")
+	}
+
+	if err = sink.Write(context.Background(), events[:1]); err != nil {
+		t.Fatalf("write after erase: %v", err)
+	}
+}
+
+func TestFileSinkWritesAfterFailedErase(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := NewFileSink("file", dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err = sink.Write(context.Background(), []repository.ClickEvent{{Code: "abc"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rewrite cannot create its temporary file.
+	if err = os.Mkdir(filepath.Join(dir, activeFileName+".tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sink.Erase(repository.DefaultWorkspace, "abc"); err == nil {
+		t.Fatal("erase succeeded without its temporary file")
+	}
+
+	if err = sink.Write(context.Background(), []repository.ClickEvent{{Code: "xyz"}}); err != nil {
+		t.Fatalf("write after failed erase: %v", err)
+	}
+
+	if lines := countLines(t, filepath.Join(dir, activeFileName)); lines != 2 {
+		t.Errorf("active file has %d events, want 2", lines)
+	}
+}
+
+// writeRotated writes a rotated file with one event per code, as rotated at
+// the given time.
+func writeRotated(t *testing.T, dir string, rotatedAt time.Time, codes ...string) string {
+	t.Helper()
+
+	var lines strings.Builder
+	for _, code := range codes {
+		lines.WriteString(`{"workspace":"","code":"` + code + `"}` + "\n")
+	}
+
+	path := filepath.Join(dir, rotatedPrefix+rotatedAt.Format(rotatedLayout)+rotatedSuffix)
+	if err := os.WriteFile(path, []byte(lines.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
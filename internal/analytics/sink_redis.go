@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"context"
+	"time"
+	"url-shortener/internal/repository"
+)
+
+// RedisStreamSink appends the events to a capped Redis Stream.
+type RedisStreamSink struct {
+	name   string
+	stream string
+	maxLen int64
+	repo   *repository.RedisRepository
+}
+
+func NewRedisStreamSink(name, stream string, maxLen int64, redisRepo *repository.RedisRepository) *RedisStreamSink {
+	return &RedisStreamSink{
+		name:   name,
+		stream: stream,
+		maxLen: maxLen,
+		repo:   redisRepo,
+	}
+}
+
+func (s *RedisStreamSink) Name() string {
+	return s.name
+}
+
+func (s *RedisStreamSink) Write(_ context.Context, events []repository.ClickEvent) error {
+	return s.repo.AppendClickEvents(s.stream, s.maxLen, events)
+}
+
+func (s *RedisStreamSink) Prune(before time.Time) error {
+	_, err := s.repo.TrimClickEvents(s.stream, before)
+
+	return err
+}
+
+func (s *RedisStreamSink) Erase(workspace, code string) error {
+	_, err := s.repo.InWorkspace(workspace).EraseClickEvents(s.stream, code)
+
+	return err
+}
+
+func (s *RedisStreamSink) Close() error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/repository"
+
+	json "github.com/json-iterator/go"
+)
+
+func TestWebhookSinkDeliversBatch(t *testing.T) {
+	var (
+		method  string
+		headers http.Header
+		records []eventRecord
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		headers = r.Header.Clone()
+
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &records); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink("webhook", server.URL, map[string]string{"Authorization": "Bearer secret"}, time.Second)
+	defer sink.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []repository.ClickEvent{
+		{Timestamp: now, Workspace: "acme", Code: "abc", Country: "DE", VisitorID: "v1"},
+		{Timestamp: now, Code: "xyz", Bot: true},
+	}
+
+	if err := sink.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	if method != http.MethodPost {
+		t.Errorf("method = %s, want POST", method)
+	}
+
+	if got := headers.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	if got := headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+
+	if len(records) != len(events) {
+		t.Fatalf("got %d records, want %d", len(records), len(events))
+	}
+
+	if records[0].Code != "abc" || records[0].Workspace != "acme" || records[0].Country != "DE" ||
+		records[0].VisitorID != "v1" || !records[0].Timestamp.Equal(now) {
+		t.Errorf("first record = %+v", records[0])
+	}
+
+	if records[1].Code != "xyz" || !records[1].Bot {
+		t.Errorf("second record = %+v", records[1])
+	}
+}
+
+func TestWebhookSinkStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusAccepted},
+		{status: http.StatusNoContent},
+		{status: http.StatusBadRequest, wantErr: true},
+		{status: http.StatusTooManyRequests, wantErr: true},
+		{status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+
+		sink := NewWebhookSink("webhook", server.URL, nil, time.Second)
+		err := sink.Write(context.Background(), []repository.ClickEvent{{Code: "abc"}})
+
+		if tt.wantErr != errors.Is(err, ErrWebhookStatus) {
+			t.Errorf("status %d: err = %v, want error %v", tt.status, err, tt.wantErr)
+		}
+
+		_ = sink.Close()
+		server.Close()
+	}
+}
+
+func TestWebhookSinkTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewWebhookSink("webhook", server.URL, nil, 50*time.Millisecond)
+	defer sink.Close()
+
+	if err := sink.Write(context.Background(), []repository.ClickEvent{{Code: "abc"}}); err == nil {
+		t.Fatal("write to a stalled webhook succeeded, want a timeout")
+	}
+}
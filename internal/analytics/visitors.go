@@ -0,0 +1,236 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+	"url-shortener/internal/repository"
+	"url-shortener/pkg/hyperloglog"
+)
+
+const (
+	VisitorBackendRedis  = "redis"
+	VisitorBackendMemory = "memory"
+
+	DefaultVisitorHourlyRetention = 90 * 24 * time.Hour
+	DefaultVisitorDailyRetention  = 366 * 24 * time.Hour
+
+	// The memory backend keeps a sketch per link and hour, older hourly
+	// buckets count no visitors there.
+	MaxMemoryVisitorHourlyRetention = 48 * time.Hour
+
+	memorySketchPrecision = 12
+	memoryPruneInterval   = time.Hour
+)
+
+type VisitorCounterConfig struct {
+	// Where the estimates are kept. Valid values: redis, memory
+	Backend string
+	// Hourly and daily estimates are dropped this long after their bucket
+	// starts.
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+}
+
+// VisitorCounter keeps per-link unique visitor estimates for the hourly and
+// daily stats buckets, links are told apart by workspace and code.
+type VisitorCounter interface {
+	Add(events []repository.ClickEvent) error
+	Count(workspace, code string, interval repository.StatsInterval, starts []time.Time) ([]int64, int64, error)
+	Erase(workspace, code string) error
+}
+
+// NewVisitorCounter returns the counter for the configured backend, Redis
+// unless memory is asked for explicitly.
+func NewVisitorCounter(cfg VisitorCounterConfig, redisRepo *repository.RedisRepository) VisitorCounter {
+	if cfg.HourlyRetention <= 0 {
+		cfg.HourlyRetention = DefaultVisitorHourlyRetention
+	}
+
+	if cfg.DailyRetention <= 0 {
+		cfg.DailyRetention = DefaultVisitorDailyRetention
+	}
+
+	retention := map[repository.StatsInterval]time.Duration{
+		repository.IntervalHour: cfg.HourlyRetention,
+		repository.IntervalDay:  cfg.DailyRetention,
+	}
+
+	if cfg.Backend == VisitorBackendMemory {
+		return NewMemoryVisitorCounter(retention)
+	}
+
+	return NewRedisVisitorCounter(redisRepo, retention)
+}
+
+// RedisVisitorCounter stores the estimates with PFADD and reads them with
+// PFCOUNT.
+type RedisVisitorCounter struct {
+	repo      *repository.RedisRepository
+	retention map[repository.StatsInterval]time.Duration
+}
+
+func NewRedisVisitorCounter(
+	redisRepo *repository.RedisRepository,
+	retention map[repository.StatsInterval]time.Duration) *RedisVisitorCounter {
+	return &RedisVisitorCounter{
+		repo:      redisRepo,
+		retention: retention,
+	}
+}
+
+func (c *RedisVisitorCounter) Add(events []repository.ClickEvent) error {
+	for workspace, group := range repository.GroupByWorkspace(events) {
+		if err := c.repo.InWorkspace(workspace).AddVisitors(group, c.retention); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *RedisVisitorCounter) Count(
+	workspace, code string,
+	interval repository.StatsInterval,
+	starts []time.Time) ([]int64, int64, error) {
+	return c.repo.InWorkspace(workspace).CountVisitors(code, interval, starts)
+}
+
+func (c *RedisVisitorCounter) Erase(workspace, code string) error {
+	return c.repo.InWorkspace(workspace).EraseVisitors(code)
+}
+
+// MemoryVisitorCounter keeps the estimates in process, they are lost on
+// restart and not shared between instances.
+type MemoryVisitorCounter struct {
+	retention  map[repository.StatsInterval]time.Duration
+	mu         sync.Mutex
+	sketches   map[string]*memorySketch
+	lastPruned time.Time
+}
+
+type memorySketch struct {
+	workspace string
+	code      string
+	sketch    *hyperloglog.Sketch
+	expires   time.Time
+}
+
+// NewMemoryVisitorCounter keeps hourly estimates at most
+// MaxMemoryVisitorHourlyRetention.
+func NewMemoryVisitorCounter(retention map[repository.StatsInterval]time.Duration) *MemoryVisitorCounter {
+	capped := make(map[repository.StatsInterval]time.Duration, len(retention))
+	for interval, keep := range retention {
+		if interval == repository.IntervalHour && keep > MaxMemoryVisitorHourlyRetention {
+			keep = MaxMemoryVisitorHourlyRetention
+		}
+
+		capped[interval] = keep
+	}
+
+	return &MemoryVisitorCounter{
+		retention:  capped,
+		sketches:   map[string]*memorySketch{},
+		lastPruned: time.Now(),
+	}
+}
+
+func (c *MemoryVisitorCounter) Add(events []repository.ClickEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, event := range events {
+		if event.VisitorID == "" || event.Bot {
+			continue
+		}
+
+		for interval, retention := range c.retention {
+			start := repository.BucketStart(event.Timestamp, interval)
+			key := memorySketchKey(event.Workspace, event.Code, interval, start)
+
+			entry, ok := c.sketches[key]
+			if !ok {
+				entry = &memorySketch{
+					workspace: workspaceOrDefault(event.Workspace),
+					code:      event.Code,
+					sketch:    hyperloglog.New(memorySketchPrecision),
+					expires:   start.Add(retention),
+				}
+				c.sketches[key] = entry
+			}
+
+			entry.sketch.Add(event.VisitorID)
+		}
+	}
+
+	c.prune()
+
+	return nil
+}
+
+func (c *MemoryVisitorCounter) Count(
+	workspace, code string,
+	interval repository.StatsInterval,
+	starts []time.Time) ([]int64, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	perBucket := make([]int64, len(starts))
+	union := hyperloglog.New(memorySketchPrecision)
+
+	for i, start := range starts {
+		entry, ok := c.sketches[memorySketchKey(workspace, code, interval, start)]
+		if !ok {
+			continue
+		}
+
+		perBucket[i] = int64(entry.sketch.Count())
+
+		if err := union.Merge(entry.sketch); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return perBucket, int64(union.Count()), nil
+}
+
+func (c *MemoryVisitorCounter) Erase(workspace, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	workspace = workspaceOrDefault(workspace)
+
+	for key, entry := range c.sketches {
+		if entry.code == code && entry.workspace == workspace {
+			delete(c.sketches, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *MemoryVisitorCounter) prune() {
+	now := time.Now()
+	if now.Sub(c.lastPruned) < memoryPruneInterval {
+		return
+	}
+
+	for key, entry := range c.sketches {
+		if now.After(entry.expires) {
+			delete(c.sketches, key)
+		}
+	}
+
+	c.lastPruned = now
+}
+
+func memorySketchKey(workspace, code string, interval repository.StatsInterval, start time.Time) string {
+	return workspaceOrDefault(workspace) + "/" + repository.VisitorsKey(code, interval, start)
+}
+
+func workspaceOrDefault(workspace string) string {
+	if workspace == "" {
+		return repository.DefaultWorkspace
+	}
+
+	return workspace
+}
@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+	"url-shortener/internal/repository"
+)
+
+func TestMemoryVisitorCounter(t *testing.T) {
+	counter := NewVisitorCounter(VisitorCounterConfig{Backend: VisitorBackendMemory}, nil).(*MemoryVisitorCounter)
+
+	if got := counter.retention[repository.IntervalHour]; got != MaxMemoryVisitorHourlyRetention {
+		t.Errorf("hourly retention = %v, want it capped at %v", got, MaxMemoryVisitorHourlyRetention)
+	}
+
+	if got := counter.retention[repository.IntervalDay]; got != DefaultVisitorDailyRetention {
+		t.Errorf("daily retention = %v, want %v", got, DefaultVisitorDailyRetention)
+	}
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	events := []repository.ClickEvent{
+		{Timestamp: hour, Code: "abc123", VisitorID: "v1"},
+		{Timestamp: hour, Code: "abc123", VisitorID: "v1"},
+		{Timestamp: hour, Code: "abc123", VisitorID: "v2"},
+		{Timestamp: hour.Add(time.Hour), Workspace: repository.DefaultWorkspace, Code: "abc123", VisitorID: "v2"},
+		{Timestamp: hour.Add(time.Hour), Code: "abc123", VisitorID: "v3"},
+		{Timestamp: hour, Code: "abc123", VisitorID: "crawler", Bot: true},
+		{Timestamp: hour, Code: "abc123"},
+		{Timestamp: hour, Workspace: "acme", Code: "abc123", VisitorID: "v4"},
+	}
+
+	if err := counter.Add(events); err != nil {
+		t.Fatal(err)
+	}
+
+	perBucket, total, err := counter.Count("", "abc123", repository.IntervalHour, []time.Time{hour, hour.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perBucket[0] != 2 || perBucket[1] != 2 || total != 3 {
+		t.Errorf("visitors per hour = %v, total = %d, want [2 2] and 3", perBucket, total)
+	}
+
+	if err = counter.Erase(repository.DefaultWorkspace, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, total, _ = counter.Count("", "abc123", repository.IntervalDay, []time.Time{repository.BucketStart(hour, repository.IntervalDay)})
+	if total != 0 {
+		t.Errorf("%d visitors left after erasing", total)
+	}
+
+	_, total, _ = counter.Count("acme", "abc123", repository.IntervalHour, []time.Time{hour})
+	if total != 1 {
+		t.Errorf("the other workspace's link has %d visitors after erasing, want 1", total)
+	}
+}
+
+func TestMemoryVisitorCounterKeepsShorterRetention(t *testing.T) {
+	counter := NewMemoryVisitorCounter(map[repository.StatsInterval]time.Duration{
+		repository.IntervalHour: 6 * time.Hour,
+		repository.IntervalDay:  30 * 24 * time.Hour,
+	})
+
+	if got := counter.retention[repository.IntervalHour]; got != 6*time.Hour {
+		t.Errorf("hourly retention = %v, want 6h", got)
+	}
+}
@@ -14,6 +14,8 @@ import (
 const (
 	EnvProduction      = "Production"
 	URLShortenerPrefix = "URL_SHORTENER"
+
+	redactedValue = "[redacted]"
 )
 
 var ErrUnmarshalConfig = errors.New("viper failed to unmarshal app config")
@@ -21,7 +23,12 @@ var ErrUnmarshalConfig = errors.New("viper failed to unmarshal app config")
 type Configuration struct {
 	/* ---------------------------  HTTP  ----------------------------------- */
 
-	Addr string `mapstructure:"address"`
+	Addr string    `mapstructure:"address"`
+	TLS  ServerTLS `mapstructure:"tls"`
+	// Addresses or CIDR ranges of the proxies in front of the service. Client addresses are read from the Forwarded and
+	// X-Forwarded-For headers, and request ids from X-Request-ID, of their requests only. Other requests come from
+	// their peer and get a request id of their own.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 
 	/* ---------------------------  API  ----------------------------------- */
 
@@ -40,7 +47,53 @@ type Configuration struct {
 
 	Redis Redis `mapstructure:"redis"`
 
-	/* ---------------------------  Base URL  ------------------------------------- */
+	/* ---------------------------  Interstitial  ------------------------------- */
+
+	Interstitial Interstitial `mapstructure:"interstitial"`
+
+	/* ---------------------------  Screening  --------------------------------- */
+
+	Screening Screening `mapstructure:"screening"`
+
+	/* ---------------------------  Moderation  -------------------------------- */
+
+	Moderation Moderation `mapstructure:"moderation"`
+
+	/* ---------------------------  Audit  ------------------------------------- */
+
+	Audit Audit `mapstructure:"audit"`
+
+	/* ---------------------------  Quota  ------------------------------------- */
+
+	Quota Quota `mapstructure:"quota"`
+
+	/* ---------------------------  Signing  ----------------------------------- */
+
+	Signing Signing `mapstructure:"signing"`
+
+	/* ---------------------------  Analytics  --------------------------------- */
+
+	Analytics Analytics `mapstructure:"analytics"`
+
+	/* ---------------------------  Privacy  ----------------------------------- */
+
+	Privacy Privacy `mapstructure:"privacy"`
+
+	/* ---------------------------  Auth  -------------------------------------- */
+
+	Auth Auth `mapstructure:"auth"`
+
+	/* ---------------------------  Rate Limit  -------------------------------- */
+
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+
+	/* ---------------------------  Scan Detection  ---------------------------- */
+
+	ScanDetection ScanDetection `mapstructure:"scan_detection"`
+
+	/* ---------------------------  CORS  -------------------------------------- */
+
+	CORS CORS `mapstructure:"cors"`
 }
 
 type API struct {
@@ -51,12 +104,246 @@ type Metrics struct {
 	Addr      string `mapstructure:"addr"`
 	Namespace string `mapstructure:"namespace"`
 	Subsystem string `mapstructure:"subsystem"`
+	// Scrapers must present a certificate verified against client_ca_file when it is set.
+	TLS TLS `mapstructure:"tls"`
+}
+
+type TLS struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// Oldest protocol version accepted, "1.2" or "1.3".
+	MinVersion string `mapstructure:"min_version"`
+	// TLS 1.2 cipher suites by their Go names, in order of preference. Empty keeps the Go defaults.
+	CipherSuites []string `mapstructure:"cipher_suites"`
+	ClientCAFile string   `mapstructure:"client_ca_file"`
+	// How often the files are checked for changes, SIGHUP reloads them right away.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type ServerTLS struct {
+	TLS `mapstructure:",squash"`
+	// Admin API requests must present a certificate verified against client_ca_file, on top of their token.
+	RequireAdminClientCert bool `mapstructure:"require_admin_client_cert"`
 }
 
 type Redis struct {
 	Host string `mapstructure:"host"`
 }
 
+type Interstitial struct {
+	// Show the warning page for every destination outside of TrustedDomains.
+	Enabled bool `mapstructure:"enabled"`
+	// How long the page waits before following the link automatically.
+	Countdown time.Duration `mapstructure:"countdown"`
+	// Domains (and their subdomains) that are redirected to without warning.
+	TrustedDomains []string `mapstructure:"trusted_domains"`
+}
+
+type Screening struct {
+	// Files with one domain or /regex/ per line, re-read when they change. Allowlisted destinations skip the blocklist.
+	BlocklistFile  string        `mapstructure:"blocklist_file"`
+	AllowlistFile  string        `mapstructure:"allowlist_file"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// Existing links are screened again this often and after the lists change, 0 only screens new links.
+	RescreenInterval time.Duration `mapstructure:"rescreen_interval"`
+}
+
+type Moderation struct {
+	// Abuse reports kept per link, older ones are dropped.
+	MaxReportsPerLink int64 `mapstructure:"max_reports_per_link"`
+	// Longest free text accepted with a report, in characters.
+	MaxDetailsLength int `mapstructure:"max_details_length"`
+	// Keys the hashes reporters are told apart by. When empty one is generated and kept in Redis.
+	ReporterSecret string `mapstructure:"reporter_secret"`
+}
+
+type Audit struct {
+	// Audit entries older than this are dropped, 0 keeps them forever.
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type Quota struct {
+	// Limits of the workspaces and API keys without limits of their own, set through the API or below.
+	Workspace QuotaLimits `mapstructure:"workspace"`
+	APIKey    QuotaLimits `mapstructure:"api_key"`
+	// Limits of single workspaces by id, the API overrides them.
+	Workspaces map[string]QuotaLimits `mapstructure:"workspaces"`
+}
+
+type QuotaLimits struct {
+	// Links created per UTC day and overall, 0 is unlimited.
+	Daily int64 `mapstructure:"daily"`
+	Total int64 `mapstructure:"total"`
+}
+
+type Signing struct {
+	// Append a truncated HMAC to the codes of new short URLs and refuse codes whose signature does not match.
+	Enabled bool `mapstructure:"enabled"`
+	// The first key signs, the others only verify. Put a new key first and retire the old one to rotate.
+	Keys []SigningKey `mapstructure:"keys"`
+	// How long retired keys keep verifying after their retired_at.
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	// Characters of the signature, 6 bits each.
+	SignatureLength int `mapstructure:"signature_length"`
+	// Refuse codes without a signature. Turn off while links shared before signing was enabled must keep working.
+	RequireSignature bool `mapstructure:"require_signature"`
+}
+
+type SigningKey struct {
+	// At least 16 characters.
+	Secret string `mapstructure:"secret"`
+	// RFC 3339 time the key stopped signing, empty while it is in use.
+	RetiredAt string `mapstructure:"retired_at"`
+}
+
+type Analytics struct {
+	// Click events kept in memory before new ones are dropped.
+	QueueSize     int           `mapstructure:"queue_size"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// Request header set by the edge proxy with the visitor country code.
+	CountryHeader string `mapstructure:"country_header"`
+	// Where unique visitor estimates are kept. Valid values: redis, memory. memory keeps hourly estimates 48h at most.
+	VisitorBackend string `mapstructure:"visitor_backend"`
+	// Secret mixed into the visitor and address hashes so they can't be reversed. When empty a random one is
+	// generated once and kept in Redis.
+	VisitorSalt string `mapstructure:"visitor_salt"`
+	// Extra bot user agent patterns, a leading ^ anchors one at the start. The file is re-read when it changes.
+	BotListFile           string        `mapstructure:"bot_list_file"`
+	BotListReloadInterval time.Duration `mapstructure:"bot_list_reload_interval"`
+	// Bearer token that only opens the live click streams of the default
+	// workspace, for consumers without an API key. Disabled when empty.
+	StreamToken string `mapstructure:"stream_token"`
+	// Events buffered per stream subscriber before it is disconnected.
+	StreamBuffer int `mapstructure:"stream_buffer"`
+	// Streams are closed after this long, clients are expected to reconnect.
+	StreamMaxDuration time.Duration `mapstructure:"stream_max_duration"`
+	// Destinations every click event is delivered to.
+	Sinks []Sink `mapstructure:"sinks"`
+	// How often minute counters are rolled up into hourly and daily series.
+	RollupInterval time.Duration `mapstructure:"rollup_interval"`
+	// Hourly series and unique visitor estimates are kept this long, daily series are kept forever.
+	HourlyRetention time.Duration `mapstructure:"hourly_retention"`
+	// Daily unique visitor estimates are kept this long.
+	VisitorDailyRetention time.Duration `mapstructure:"visitor_daily_retention"`
+	// Raw click events are removed from the sinks after this long, 0 keeps them.
+	RawRetention time.Duration `mapstructure:"raw_retention"`
+}
+
+type Privacy struct {
+	// How visitor addresses are stored. Valid values: truncate, hash, drop
+	IPMode         string `mapstructure:"ip_mode"`
+	IPv4PrefixBits int    `mapstructure:"ipv4_prefix_bits"`
+	IPv6PrefixBits int    `mapstructure:"ipv6_prefix_bits"`
+	// A fresh random salt is used for visitor hashes after this long, 0 disables rotation.
+	SaltRotation time.Duration `mapstructure:"salt_rotation"`
+	// Skip per-visitor data for requests sending DNT or Sec-GPC.
+	HonorDoNotTrack bool `mapstructure:"honor_do_not_track"`
+	// Bearer token that only erases link analytics in the default
+	// workspace, for erasure jobs without an API key. Disabled when empty.
+	ErasureToken string `mapstructure:"erasure_token"`
+}
+
+type Auth struct {
+	// Let requests without an API key create links.
+	AllowAnonymousCreate bool `mapstructure:"allow_anonymous_create"`
+	// Tokens issued by the SSO.
+	JWT JWT `mapstructure:"jwt"`
+}
+
+type JWT struct {
+	// Path or URL of the JWKS with the SSO signing keys, JWTs are rejected when empty.
+	JWKS                string        `mapstructure:"jwks"`
+	JWKSRefreshInterval time.Duration `mapstructure:"jwks_refresh_interval"`
+	// Checked when not empty.
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// Claims holding the user id, the roles and the workspace.
+	UserClaim      string `mapstructure:"user_claim"`
+	RolesClaim     string `mapstructure:"roles_claim"`
+	WorkspaceClaim string `mapstructure:"workspace_claim"`
+	// Scopes granted by each role. When empty, roles named after a scope grant it.
+	RoleScopes map[string][]string `mapstructure:"role_scopes"`
+	// Clock skew tolerated when checking expiry.
+	Leeway time.Duration `mapstructure:"leeway"`
+}
+
+type RateLimit struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Where request budgets are kept. Valid values: redis, memory
+	// Redis falls back to per-instance budgets while it is unreachable.
+	Backend  string          `mapstructure:"backend"`
+	Create   RateLimitPolicy `mapstructure:"create"`
+	Redirect RateLimitPolicy `mapstructure:"redirect"`
+	Report   RateLimitPolicy `mapstructure:"report"`
+}
+
+type ScanDetection struct {
+	// Off by default, behind a proxy missing from trusted_proxies every client shares the proxy's address.
+	Enabled bool `mapstructure:"enabled"`
+	// Clients are judged by their share of 404s over this sliding window, once they made min_requests in it.
+	Window      time.Duration `mapstructure:"window"`
+	MinRequests int           `mapstructure:"min_requests"`
+	// Share of 404s from which a client is slowed down by slow_delay, and blocked for block_duration. slow_ratio must
+	// not exceed block_ratio.
+	SlowRatio     float64       `mapstructure:"slow_ratio"`
+	BlockRatio    float64       `mapstructure:"block_ratio"`
+	SlowDelay     time.Duration `mapstructure:"slow_delay"`
+	BlockDuration time.Duration `mapstructure:"block_duration"`
+	// Codes never handed out, clients requesting one are blocked. Random ones are added until there are honeypot_count.
+	HoneypotCodes []string `mapstructure:"honeypot_codes"`
+	HoneypotCount int      `mapstructure:"honeypot_count"`
+}
+
+type CORS struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Origins allowed to call /create and /api/v1, "*" allows any and a leading "*." label as in
+	// "https://*.example.com" any subdomain.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	AllowedMethods []string `mapstructure:"allowed_methods"`
+	// Request headers allowed in preflights, "*" allows any.
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+	// Response headers scripts may read.
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+	// Let browsers send cookies and client certificates, not allowed together with the "*" origin.
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+type RateLimitPolicy struct {
+	// Requests allowed per period, up to burst of them at once. A zero rate disables the policy.
+	Rate   int           `mapstructure:"rate"`
+	Period time.Duration `mapstructure:"period"`
+	Burst  int           `mapstructure:"burst"`
+	// What requests are counted by. Valid values: ip, api_key, workspace
+	Key string `mapstructure:"key"`
+}
+
+type Sink struct {
+	// Valid values: redis, file, webhook
+	Type          string        `mapstructure:"type"`
+	Name          string        `mapstructure:"name"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	QueueSize     int           `mapstructure:"queue_size"`
+	MaxRetries    int           `mapstructure:"max_retries"`
+	RetryBackoff  time.Duration `mapstructure:"retry_backoff"`
+	WriteTimeout  time.Duration `mapstructure:"write_timeout"`
+
+	// Redis Stream the events are appended to and its approximate cap.
+	Stream       string `mapstructure:"stream"`
+	StreamMaxLen int64  `mapstructure:"stream_max_len"`
+
+	// Directory of the NDJSON files and the size they are rotated at.
+	Directory   string `mapstructure:"directory"`
+	MaxFileSize int64  `mapstructure:"max_file_size"`
+
+	// Endpoint receiving the batches as JSON arrays.
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
 type ProductionConfigurationLogging struct {
 	Level   string    `json:"level"`
 	TS      time.Time `json:"ts"`
@@ -66,7 +353,9 @@ type ProductionConfigurationLogging struct {
 	} `json:"payload"`
 }
 
-func NewAppConfiguration(env string, writeConfig bool) (cfg *Configuration, err error) {
+// NewAppConfiguration resolves the configuration from the settings file and
+// the environment and logs it with its secrets redacted.
+func NewAppConfiguration(env string) (cfg *Configuration, err error) {
 	var filename string
 
 	switch env {
@@ -92,7 +381,7 @@ func NewAppConfiguration(env string, writeConfig bool) (cfg *Configuration, err
 			Payload: struct {
 				Configuration *Configuration `json:"configuration"`
 			}{
-				Configuration: cfg,
+				Configuration: cfg.redacted(),
 			},
 		})
 
@@ -103,16 +392,49 @@ func NewAppConfiguration(env string, writeConfig bool) (cfg *Configuration, err
 		fmt.Printf("%s\n", data)
 	default:
 		fmt.Println("Logging the resolved configuration:")
-		_, _ = fmt.Println(cfg)
+		_, _ = fmt.Println(cfg.redacted())
+	}
+
+	return cfg, nil
+}
+
+// redacted copies the configuration with its secrets, and the sink headers
+// that may carry credentials, replaced.
+func (c *Configuration) redacted() *Configuration {
+	r := *c
+
+	r.Signing.Keys = make([]SigningKey, len(c.Signing.Keys))
+	for i, key := range c.Signing.Keys {
+		key.Secret = redact(key.Secret)
+		r.Signing.Keys[i] = key
 	}
 
-	if writeConfig {
-		if err = v.WriteConfig(); err != nil {
-			log.Println("viper failed to write app config file:", err)
+	r.Analytics.VisitorSalt = redact(c.Analytics.VisitorSalt)
+	r.Analytics.StreamToken = redact(c.Analytics.StreamToken)
+	r.Privacy.ErasureToken = redact(c.Privacy.ErasureToken)
+	r.Moderation.ReporterSecret = redact(c.Moderation.ReporterSecret)
+
+	r.Analytics.Sinks = make([]Sink, len(c.Analytics.Sinks))
+	for i, sink := range c.Analytics.Sinks {
+		headers := make(map[string]string, len(sink.Headers))
+		for name, value := range sink.Headers {
+			headers[name] = redact(value)
 		}
+
+		sink.Headers = headers
+		r.Analytics.Sinks[i] = sink
 	}
 
-	return cfg, nil
+	return &r
+}
+
+// redact keeps telling unset secrets apart from set ones.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return redactedValue
 }
 
 // Set the default config values for the viper object we are using.
@@ -129,6 +451,15 @@ func newViper(filename string) *viper.Viper {
 		/* ---------------------------  Transport  -------------------------------- */
 
 		v.SetDefault("address", ":8081")
+		v.SetDefault("tls.enabled", false)
+		v.SetDefault("tls.cert_file", "")
+		v.SetDefault("tls.key_file", "")
+		v.SetDefault("tls.min_version", "1.2")
+		v.SetDefault("tls.cipher_suites", []string{})
+		v.SetDefault("tls.client_ca_file", "")
+		v.SetDefault("tls.reload_interval", "1m")
+		v.SetDefault("tls.require_admin_client_cert", false)
+		v.SetDefault("trusted_proxies", []string{})
 	}
 	{
 		/* ---------------------------  Transport  -------------------------------- */
@@ -141,6 +472,13 @@ func newViper(filename string) *viper.Viper {
 			v.SetDefault("metrics.addr", ":5070")
 			v.SetDefault("metrics.namespace", "app")
 			v.SetDefault("metrics.subsystem", "url_shortener")
+			v.SetDefault("metrics.tls.enabled", false)
+			v.SetDefault("metrics.tls.cert_file", "")
+			v.SetDefault("metrics.tls.key_file", "")
+			v.SetDefault("metrics.tls.min_version", "1.2")
+			v.SetDefault("metrics.tls.cipher_suites", []string{})
+			v.SetDefault("metrics.tls.client_ca_file", "")
+			v.SetDefault("metrics.tls.reload_interval", "1m")
 		}
 	}
 	{
@@ -154,6 +492,148 @@ func newViper(filename string) *viper.Viper {
 			v.SetDefault("redis.host", ":6379")
 		}
 	}
+	{
+		/* ---------------------------  Interstitial  ----------------------------- */
+
+		v.SetDefault("interstitial.enabled", false)
+		v.SetDefault("interstitial.countdown", "5s")
+		v.SetDefault("interstitial.trusted_domains", []string{})
+	}
+	{
+		/* ---------------------------  Screening  -------------------------------- */
+
+		v.SetDefault("screening.blocklist_file", "")
+		v.SetDefault("screening.allowlist_file", "")
+		v.SetDefault("screening.reload_interval", "1m")
+		v.SetDefault("screening.rescreen_interval", "24h")
+	}
+	{
+		/* ---------------------------  Moderation  ------------------------------- */
+
+		v.SetDefault("moderation.max_reports_per_link", 100)
+		v.SetDefault("moderation.max_details_length", 1000)
+		v.SetDefault("moderation.reporter_secret", "")
+	}
+	{
+		/* ---------------------------  Audit  ------------------------------------ */
+
+		v.SetDefault("audit.retention", "0s")
+	}
+	{
+		/* ---------------------------  Quota  ------------------------------------ */
+
+		v.SetDefault("quota.workspace.daily", 0)
+		v.SetDefault("quota.workspace.total", 0)
+		v.SetDefault("quota.api_key.daily", 0)
+		v.SetDefault("quota.api_key.total", 0)
+	}
+	{
+		/* ---------------------------  Signing  ---------------------------------- */
+
+		v.SetDefault("signing.enabled", false)
+		v.SetDefault("signing.keys", []map[string]interface{}{})
+		v.SetDefault("signing.grace_period", "720h")
+		v.SetDefault("signing.signature_length", 8)
+		v.SetDefault("signing.require_signature", true)
+	}
+	{
+		/* ---------------------------  Analytics  -------------------------------- */
+
+		v.SetDefault("analytics.queue_size", 10000)
+		v.SetDefault("analytics.batch_size", 500)
+		v.SetDefault("analytics.flush_interval", "1s")
+		v.SetDefault("analytics.country_header", "CF-IPCountry")
+		v.SetDefault("analytics.visitor_backend", "redis")
+		v.SetDefault("analytics.visitor_salt", "")
+		v.SetDefault("analytics.bot_list_file", "")
+		v.SetDefault("analytics.bot_list_reload_interval", "1m")
+		v.SetDefault("analytics.stream_token", "")
+		v.SetDefault("analytics.stream_buffer", 256)
+		v.SetDefault("analytics.stream_max_duration", "1h")
+		v.SetDefault("analytics.rollup_interval", "1m")
+		v.SetDefault("analytics.hourly_retention", "2160h")
+		v.SetDefault("analytics.visitor_daily_retention", "8784h")
+		v.SetDefault("analytics.raw_retention", "168h")
+		v.SetDefault("analytics.sinks", []map[string]interface{}{
+			{
+				"type":           "redis",
+				"stream":         "clicks",
+				"stream_max_len": 1000000,
+				"max_retries":    3,
+			},
+		})
+	}
+	{
+		/* ---------------------------  Privacy  ---------------------------------- */
+
+		v.SetDefault("privacy.ip_mode", "truncate")
+		v.SetDefault("privacy.ipv4_prefix_bits", 24)
+		v.SetDefault("privacy.ipv6_prefix_bits", 48)
+		v.SetDefault("privacy.salt_rotation", "24h")
+		v.SetDefault("privacy.honor_do_not_track", true)
+		v.SetDefault("privacy.erasure_token", "")
+	}
+	{
+		/* ---------------------------  Auth  ------------------------------------- */
+
+		v.SetDefault("auth.allow_anonymous_create", false)
+		v.SetDefault("auth.jwt.jwks", "")
+		v.SetDefault("auth.jwt.jwks_refresh_interval", "1h")
+		v.SetDefault("auth.jwt.issuer", "")
+		v.SetDefault("auth.jwt.audience", "")
+		v.SetDefault("auth.jwt.user_claim", "sub")
+		v.SetDefault("auth.jwt.roles_claim", "roles")
+		v.SetDefault("auth.jwt.workspace_claim", "workspace")
+		v.SetDefault("auth.jwt.role_scopes", map[string][]string{})
+		v.SetDefault("auth.jwt.leeway", "30s")
+	}
+	{
+		/* ---------------------------  Rate Limit  ------------------------------- */
+
+		v.SetDefault("rate_limit.enabled", true)
+		v.SetDefault("rate_limit.backend", "redis")
+		v.SetDefault("rate_limit.create.rate", 60)
+		v.SetDefault("rate_limit.create.period", "1m")
+		v.SetDefault("rate_limit.create.burst", 20)
+		v.SetDefault("rate_limit.create.key", "api_key")
+		v.SetDefault("rate_limit.redirect.rate", 6000)
+		v.SetDefault("rate_limit.redirect.period", "1m")
+		v.SetDefault("rate_limit.redirect.burst", 500)
+		v.SetDefault("rate_limit.redirect.key", "ip")
+		v.SetDefault("rate_limit.report.rate", 10)
+		v.SetDefault("rate_limit.report.period", "1h")
+		v.SetDefault("rate_limit.report.burst", 5)
+		v.SetDefault("rate_limit.report.key", "ip")
+	}
+	{
+		/* ---------------------------  Scan Detection  --------------------------- */
+
+		v.SetDefault("scan_detection.enabled", false)
+		v.SetDefault("scan_detection.window", "1m")
+		v.SetDefault("scan_detection.min_requests", 20)
+		v.SetDefault("scan_detection.slow_ratio", 0.5)
+		v.SetDefault("scan_detection.block_ratio", 0.8)
+		v.SetDefault("scan_detection.slow_delay", "1s")
+		v.SetDefault("scan_detection.block_duration", "15m")
+		v.SetDefault("scan_detection.honeypot_codes", []string{})
+		v.SetDefault("scan_detection.honeypot_count", 0)
+	}
+	{
+		/* ---------------------------  CORS  ------------------------------------- */
+
+		v.SetDefault("cors.enabled", false)
+		v.SetDefault("cors.allowed_origins", []string{})
+		v.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+		v.SetDefault("cors.allowed_headers", []string{"Authorization", "Content-Type", "X-Request-ID"})
+		v.SetDefault("cors.exposed_headers", []string{
+			"X-Request-ID", "Retry-After",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+			"Quota-Daily-Limit", "Quota-Daily-Remaining", "Quota-Daily-Reset",
+			"Quota-Total-Limit", "Quota-Total-Remaining",
+		})
+		v.SetDefault("cors.allow_credentials", false)
+		v.SetDefault("cors.max_age", "10m")
+	}
 
 	// Set environment variable support:
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	json "github.com/json-iterator/go"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Configuration{
+		Signing:    Signing{Keys: []SigningKey{{Secret: "signing-secret", RetiredAt: "2026-01-01T00:00:00Z"}}},
+		Moderation: Moderation{ReporterSecret: "reporter-secret"},
+		Privacy:    Privacy{ErasureToken: "erasure-token"},
+		Analytics: Analytics{
+			VisitorSalt: "visitor-salt",
+			StreamToken: "stream-token",
+			Sinks:       []Sink{{Type: "webhook", Headers: map[string]string{"Authorization": "Bearer sink-token"}}},
+		},
+	}
+	secrets := []string{"signing-secret", "reporter-secret", "erasure-token", "visitor-salt", "stream-token", "sink-token"}
+
+	redacted := cfg.redacted()
+
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, printed := range []string{string(data), fmt.Sprint(redacted)} {
+		for _, secret := range secrets {
+			if strings.Contains(printed, secret) {
+				t.Errorf("%s printed in %s", secret, printed)
+			}
+		}
+	}
+
+	if redacted.Signing.Keys[0].RetiredAt != cfg.Signing.Keys[0].RetiredAt ||
+		redacted.Analytics.Sinks[0].Headers["Authorization"] != redactedValue {
+		t.Errorf("redacted = %+v, want only the secrets replaced", redacted)
+	}
+
+	if cfg.Signing.Keys[0].Secret != "signing-secret" ||
+		cfg.Analytics.Sinks[0].Headers["Authorization"] != "Bearer sink-token" {
+		t.Error("the configuration itself was redacted")
+	}
+
+	if (&Configuration{}).redacted().Analytics.StreamToken != "" {
+		t.Error("an unset secret was shown as set")
+	}
+}
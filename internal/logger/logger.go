@@ -5,6 +5,7 @@ import "go.uber.org/zap"
 type Engine interface {
 	LogError(message string, err error)
 	LogInfo(message string, value ...interface{})
+	LogWarn(message string, value ...interface{})
 }
 
 type Logger struct {
@@ -30,3 +31,10 @@ func (l *Logger) LogInfo(message string, value ...interface{}) {
 		zap.Any(message, value),
 	)
 }
+
+func (l *Logger) LogWarn(message string, value ...interface{}) {
+	l.zap.Warn(
+		message,
+		zap.Any(message, value),
+	)
+}
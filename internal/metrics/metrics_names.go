@@ -3,14 +3,70 @@ package metrics
 type EventType string
 
 const (
-	EventTypeCreate   EventType = "create"
-	EventTypeRedirect EventType = "redirect"
+	EventTypeCreate     EventType = "create"
+	EventTypeRedirect   EventType = "redirect"
+	EventTypePreview    EventType = "preview"
+	EventTypeStats      EventType = "stats"
+	EventTypeEvents     EventType = "events"
+	EventTypeErasure    EventType = "erasure"
+	EventTypeAPIKeys    EventType = "api_keys"
+	EventTypeWorkspaces EventType = "workspaces"
+	EventTypeLinks      EventType = "links"
+	EventTypeMembers    EventType = "members"
+	EventTypeReport     EventType = "report"
+	EventTypeModeration EventType = "moderation"
+	EventTypeAudit      EventType = "audit"
+	EventTypeQuotas     EventType = "quotas"
 )
 
 type ResponseType string
 
 const (
-	StatusOk            ResponseType = "200"
-	StatusBadRequest    ResponseType = "400"
-	StatusInternalError ResponseType = "500"
+	StatusOk                  ResponseType = "200"
+	StatusAccepted            ResponseType = "202"
+	StatusNoContent           ResponseType = "204"
+	StatusBadRequest          ResponseType = "400"
+	StatusUnauthorized        ResponseType = "401"
+	StatusForbidden           ResponseType = "403"
+	StatusNotFound            ResponseType = "404"
+	StatusConflict            ResponseType = "409"
+	StatusUnprocessableEntity ResponseType = "422"
+	StatusTooManyRequests     ResponseType = "429"
+	StatusInternalError       ResponseType = "500"
+)
+
+type ClickStatus string
+
+const (
+	ClickQueued  ClickStatus = "queued"
+	ClickDropped ClickStatus = "dropped"
+	// The events were aggregated into the link stats, delivery to the event
+	// sinks is counted per sink with SinkStatus.
+	ClickFlushed     ClickStatus = "flushed"
+	ClickFlushFailed ClickStatus = "flush_failed"
+)
+
+type AuthRejection string
+
+const (
+	AuthMissing   AuthRejection = "missing"
+	AuthInvalid   AuthRejection = "invalid"
+	AuthForbidden AuthRejection = "forbidden"
+	// A client certificate was required and not presented.
+	AuthClientCert AuthRejection = "client_cert"
+)
+
+type SinkStatus string
+
+const (
+	SinkWritten SinkStatus = "written"
+	SinkFailed  SinkStatus = "failed"
+	SinkDropped SinkStatus = "dropped"
+)
+
+type CertReloadStatus string
+
+const (
+	CertReloaded     CertReloadStatus = "reloaded"
+	CertReloadFailed CertReloadStatus = "failed"
 )
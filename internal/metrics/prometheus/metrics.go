@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"time"
 	"url-shortener/internal/metrics"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -8,14 +9,53 @@ import (
 )
 
 const (
-	MetricResponse = "response_total"
-	MetricRequest  = "request_total"
+	MetricResponse             = "response_total"
+	MetricRequest              = "request_total"
+	MetricInterstitialView     = "interstitial_view_total"
+	MetricInterstitialContinue = "interstitial_continue_total"
+	MetricClickEvent           = "click_event_total"
+	MetricClickQueueLength     = "click_queue_length"
+	MetricBotClick             = "bot_click_total"
+	MetricStreamSubscribers    = "event_stream_subscribers"
+	MetricStreamSlowConsumer   = "event_stream_slow_consumer_total"
+	MetricSinkEvent            = "sink_event_total"
+	MetricSinkRetry            = "sink_retry_total"
+	MetricStatsRollup          = "stats_rollup_bucket_total"
+	MetricAuthRejected         = "auth_rejected_total"
+	MetricRateLimited          = "rate_limited_total"
+	MetricAbuseReport          = "abuse_report_total"
+	MetricSignatureRejected    = "signature_rejected_total"
+	MetricScannerDetected      = "scanner_detected_total"
+	MetricScannerRequest       = "scanner_request_total"
+	MetricQuotaUsed            = "quota_used"
+	MetricQuotaLimit           = "quota_limit"
+	MetricCertReload           = "tls_cert_reload_total"
+	MetricCertExpiry           = "tls_cert_expiry_timestamp_seconds"
 )
 
 type MetricsRecorder struct {
-	Registry *prometheus.Registry
-	request  *prometheus.CounterVec
-	response *prometheus.CounterVec
+	Registry             *prometheus.Registry
+	request              *prometheus.CounterVec
+	response             *prometheus.CounterVec
+	interstitialView     prometheus.Counter
+	interstitialContinue prometheus.Counter
+	clickEvent           *prometheus.CounterVec
+	clickQueueLength     prometheus.Gauge
+	botClick             prometheus.Counter
+	streamSubscribers    prometheus.Gauge
+	streamSlowConsumer   prometheus.Counter
+	sinkEvent            *prometheus.CounterVec
+	sinkRetry            *prometheus.CounterVec
+	statsRollup          prometheus.Counter
+	authRejected         *prometheus.CounterVec
+	rateLimited          *prometheus.CounterVec
+	abuseReport          *prometheus.CounterVec
+	signatureRejected    prometheus.Counter
+	scannerDetected      *prometheus.CounterVec
+	scannerRequest       *prometheus.CounterVec
+	quotas               *quotaCollector
+	certReload           *prometheus.CounterVec
+	certExpiry           *prometheus.GaugeVec
 }
 
 type MetricsConfig struct {
@@ -25,6 +65,14 @@ type MetricsConfig struct {
 
 const (
 	LabelRequestType = "request_type"
+	LabelStatus      = "status"
+	LabelSink        = "sink"
+	LabelReason      = "reason"
+	LabelPolicy      = "policy"
+	LabelAction      = "action"
+	LabelWorkspace   = "workspace"
+	LabelPeriod      = "period"
+	LabelServer      = "server"
 )
 
 func NewMetricsRecorder(cfg MetricsConfig) *MetricsRecorder {
@@ -40,11 +88,88 @@ func NewMetricsRecorder(cfg MetricsConfig) *MetricsRecorder {
 	mtx.response = newCounter(
 		cfg, MetricResponse, "The url-shortener cumulative response total counter.", labelResponseType)
 
+	mtx.interstitialView = newSimpleCounter(
+		cfg, MetricInterstitialView, "The url-shortener interstitial page views counter.")
+
+	mtx.interstitialContinue = newSimpleCounter(
+		cfg, MetricInterstitialContinue, "The url-shortener interstitial page continues counter.")
+
+	mtx.clickEvent = newCounter(
+		cfg, MetricClickEvent, "The url-shortener click events counter by pipeline status. "+
+			"Flushed events were aggregated into the link stats, deliveries to sinks are counted by "+MetricSinkEvent+".",
+		[]string{LabelStatus})
+
+	mtx.clickQueueLength = newGauge(
+		cfg, MetricClickQueueLength, "The url-shortener number of click events waiting to be flushed.")
+
+	mtx.botClick = newSimpleCounter(
+		cfg, MetricBotClick, "The url-shortener redirects served to bots and crawlers counter.")
+
+	mtx.streamSubscribers = newGauge(
+		cfg, MetricStreamSubscribers, "The url-shortener number of open click event streams.")
+
+	mtx.streamSlowConsumer = newSimpleCounter(
+		cfg, MetricStreamSlowConsumer, "The url-shortener click event streams closed for falling behind counter.")
+
+	mtx.sinkEvent = newCounter(
+		cfg, MetricSinkEvent, "The url-shortener click events delivered to event sinks counter.", []string{LabelSink, LabelStatus})
+
+	mtx.sinkRetry = newCounter(
+		cfg, MetricSinkRetry, "The url-shortener event sink write retries counter.", []string{LabelSink})
+
+	mtx.statsRollup = newSimpleCounter(
+		cfg, MetricStatsRollup, "The url-shortener minute stats buckets rolled up counter.")
+
+	mtx.authRejected = newCounter(
+		cfg, MetricAuthRejected, "The url-shortener requests rejected by API key authentication counter.", []string{LabelReason})
+
+	mtx.rateLimited = newCounter(
+		cfg, MetricRateLimited, "The url-shortener requests throttled by rate limiting counter.", []string{LabelPolicy})
+
+	mtx.abuseReport = newCounter(
+		cfg, MetricAbuseReport, "The url-shortener abuse reports received counter.", []string{LabelReason})
+
+	mtx.signatureRejected = newSimpleCounter(
+		cfg, MetricSignatureRejected, "The url-shortener short link codes refused for a missing or bad signature counter.")
+
+	mtx.scannerDetected = newCounter(
+		cfg, MetricScannerDetected, "The url-shortener clients caught scanning short codes counter.", []string{LabelAction})
+
+	mtx.scannerRequest = newCounter(
+		cfg, MetricScannerRequest, "The url-shortener requests of scanners slowed down or refused counter.", []string{LabelAction})
+
+	mtx.quotas = newQuotaCollector(cfg)
+
+	mtx.certReload = newCounter(
+		cfg, MetricCertReload, "The url-shortener tls certificate loads counter.", []string{LabelServer, LabelStatus})
+
+	mtx.certExpiry = newGaugeVec(
+		cfg, MetricCertExpiry, "The url-shortener tls certificates expiry as a unix timestamp.", []string{LabelServer})
+
 	mtx.Registry.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		mtx.response,
 		mtx.request,
+		mtx.interstitialView,
+		mtx.interstitialContinue,
+		mtx.clickEvent,
+		mtx.clickQueueLength,
+		mtx.botClick,
+		mtx.streamSubscribers,
+		mtx.streamSlowConsumer,
+		mtx.sinkEvent,
+		mtx.sinkRetry,
+		mtx.statsRollup,
+		mtx.authRejected,
+		mtx.rateLimited,
+		mtx.abuseReport,
+		mtx.signatureRejected,
+		mtx.scannerDetected,
+		mtx.scannerRequest,
+		mtx.quotas,
+		mtx.certReload,
+		mtx.certExpiry,
 	)
 
 	return &mtx
@@ -61,6 +186,28 @@ func newSimpleCounter(cfg MetricsConfig, name string, help string) prometheus.Co
 	return prometheus.NewCounter(opts)
 }
 
+func newGauge(cfg MetricsConfig, name string, help string) prometheus.Gauge {
+	opts := prometheus.GaugeOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      name,
+		Help:      help,
+	}
+
+	return prometheus.NewGauge(opts)
+}
+
+func newGaugeVec(cfg MetricsConfig, name string, help string, labels []string) *prometheus.GaugeVec {
+	opts := prometheus.GaugeOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      name,
+		Help:      help,
+	}
+
+	return prometheus.NewGaugeVec(opts, labels)
+}
+
 func newCounter(cfg MetricsConfig, name string, help string, labels []string) *prometheus.CounterVec {
 	opts := prometheus.CounterOpts{
 		Namespace: cfg.Namespace,
@@ -79,3 +226,94 @@ func (m *MetricsRecorder) RecordRequest(reqType metrics.EventType) {
 func (m *MetricsRecorder) RecordResponse(resType metrics.ResponseType) {
 	m.response.WithLabelValues(string(resType)).Inc()
 }
+
+func (m *MetricsRecorder) RecordInterstitialView() {
+	m.interstitialView.Inc()
+}
+
+func (m *MetricsRecorder) RecordInterstitialContinue() {
+	m.interstitialContinue.Inc()
+}
+
+func (m *MetricsRecorder) RecordClickQueued() {
+	m.clickEvent.WithLabelValues(string(metrics.ClickQueued)).Inc()
+}
+
+func (m *MetricsRecorder) RecordClickDropped() {
+	m.clickEvent.WithLabelValues(string(metrics.ClickDropped)).Inc()
+}
+
+func (m *MetricsRecorder) RecordClickFlushed(count int) {
+	m.clickEvent.WithLabelValues(string(metrics.ClickFlushed)).Add(float64(count))
+}
+
+func (m *MetricsRecorder) RecordClickFlushFailed(count int) {
+	m.clickEvent.WithLabelValues(string(metrics.ClickFlushFailed)).Add(float64(count))
+}
+
+func (m *MetricsRecorder) SetClickQueueLength(length int) {
+	m.clickQueueLength.Set(float64(length))
+}
+
+func (m *MetricsRecorder) RecordBotClick() {
+	m.botClick.Inc()
+}
+
+func (m *MetricsRecorder) SetEventStreamSubscribers(count int) {
+	m.streamSubscribers.Set(float64(count))
+}
+
+func (m *MetricsRecorder) RecordEventStreamSlowConsumer() {
+	m.streamSlowConsumer.Inc()
+}
+
+func (m *MetricsRecorder) RecordSinkEvents(sink string, status metrics.SinkStatus, count int) {
+	m.sinkEvent.WithLabelValues(sink, string(status)).Add(float64(count))
+}
+
+func (m *MetricsRecorder) RecordSinkRetry(sink string) {
+	m.sinkRetry.WithLabelValues(sink).Inc()
+}
+
+func (m *MetricsRecorder) RecordStatsRollup(buckets int) {
+	m.statsRollup.Add(float64(buckets))
+}
+
+func (m *MetricsRecorder) RecordAuthRejected(reason metrics.AuthRejection) {
+	m.authRejected.WithLabelValues(string(reason)).Inc()
+}
+
+func (m *MetricsRecorder) RecordRateLimited(policy string) {
+	m.rateLimited.WithLabelValues(policy).Inc()
+}
+
+func (m *MetricsRecorder) RecordAbuseReport(reason string) {
+	m.abuseReport.WithLabelValues(reason).Inc()
+}
+
+func (m *MetricsRecorder) RecordSignatureRejected() {
+	m.signatureRejected.Inc()
+}
+
+func (m *MetricsRecorder) RecordScannerDetected(action string) {
+	m.scannerDetected.WithLabelValues(action).Inc()
+}
+
+func (m *MetricsRecorder) RecordScannerRequest(action string) {
+	m.scannerRequest.WithLabelValues(action).Inc()
+}
+
+// SetQuotaUsage records the usage of a workspace quota, daily usage drops to
+// 0 at resetAt. API key quotas are left out, their ids would make a series
+// per key.
+func (m *MetricsRecorder) SetQuotaUsage(workspace, period string, used, limit int64, resetAt time.Time) {
+	m.quotas.set(workspace, period, used, limit, resetAt)
+}
+
+func (m *MetricsRecorder) RecordCertReload(server string, status metrics.CertReloadStatus) {
+	m.certReload.WithLabelValues(server, string(status)).Inc()
+}
+
+func (m *MetricsRecorder) SetCertExpiry(server string, notAfter time.Time) {
+	m.certExpiry.WithLabelValues(server).Set(float64(notAfter.Unix()))
+}
@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// quotaCollector exports the quotas of the workspaces. Usage is only known
+// when a link is created, so daily usage reads 0 once its reset time has
+// passed rather than staying at the last count until the next link.
+type quotaCollector struct {
+	used   *prometheus.Desc
+	limit  *prometheus.Desc
+	mu     sync.Mutex
+	usages map[quotaKey]quotaUsage
+}
+
+type quotaKey struct {
+	workspace string
+	period    string
+}
+
+type quotaUsage struct {
+	used    int64
+	limit   int64
+	resetAt time.Time
+}
+
+func newQuotaCollector(cfg MetricsConfig) *quotaCollector {
+	labels := []string{LabelWorkspace, LabelPeriod}
+
+	return &quotaCollector{
+		used: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Namespace, cfg.Subsystem, MetricQuotaUsed),
+			"The url-shortener links counted against each workspace quota.", labels, nil),
+		limit: prometheus.NewDesc(
+			prometheus.BuildFQName(cfg.Namespace, cfg.Subsystem, MetricQuotaLimit),
+			"The url-shortener workspace quota limits, 0 is unlimited.", labels, nil),
+		usages: map[quotaKey]quotaUsage{},
+	}
+}
+
+func (c *quotaCollector) set(workspace, period string, used, limit int64, resetAt time.Time) {
+	c.mu.Lock()
+	c.usages[quotaKey{workspace: workspace, period: period}] = quotaUsage{used: used, limit: limit, resetAt: resetAt}
+	c.mu.Unlock()
+}
+
+func (c *quotaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.used
+	ch <- c.limit
+}
+
+func (c *quotaCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, usage := range c.usages {
+		used := usage.used
+		if !usage.resetAt.IsZero() && !now.Before(usage.resetAt) {
+			used = 0
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.used, prometheus.GaugeValue, float64(used), key.workspace, key.period)
+		ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, float64(usage.limit), key.workspace, key.period)
+	}
+}
@@ -0,0 +1,67 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+)
+
+// gatherQuotas returns the quota gauges by metric, workspace and period.
+func gatherQuotas(t *testing.T, m *MetricsRecorder) map[string]float64 {
+	t.Helper()
+
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+
+	for _, family := range families {
+		if family.GetName() != MetricQuotaUsed && family.GetName() != MetricQuotaLimit {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if len(labels) != 2 {
+				t.Errorf("%s labels = %v, want workspace and period only", family.GetName(), labels)
+			}
+
+			values[family.GetName()+"/"+labels[LabelWorkspace]+"/"+labels[LabelPeriod]] = metric.GetGauge().GetValue()
+		}
+	}
+
+	return values
+}
+
+func TestSetQuotaUsage(t *testing.T) {
+	m := NewMetricsRecorder(MetricsConfig{})
+
+	m.SetQuotaUsage("acme", "daily", 7, 10, time.Now().Add(time.Hour))
+	m.SetQuotaUsage("acme", "total", 70, 100, time.Time{})
+	m.SetQuotaUsage("globex", "daily", 3, 0, time.Now().Add(-time.Second))
+
+	want := map[string]float64{
+		"quota_used/acme/daily":    7,
+		"quota_limit/acme/daily":   10,
+		"quota_used/acme/total":    70,
+		"quota_limit/acme/total":   100,
+		"quota_used/globex/daily":  0,
+		"quota_limit/globex/daily": 0,
+	}
+
+	got := gatherQuotas(t, m)
+	if len(got) != len(want) {
+		t.Errorf("gauges = %v, want %v", got, want)
+	}
+
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %v, want %v", name, got[name], value)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+
+	memoryPruneInterval = time.Minute
+)
+
+// Policy allows Rate requests per Period, up to Burst of them at once.
+type Policy struct {
+	Name   string
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// Result tells whether a request was allowed and how much of the budget is
+// left.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// How long until a request would be allowed, zero when it was.
+	RetryAfter time.Duration
+	// How long until the whole budget is available again.
+	Reset time.Duration
+}
+
+// Store keeps the theoretical arrival time of every key, see
+// repository.TakeRateLimit.
+type Store interface {
+	Take(key string, interval, capacity time.Duration, now time.Time) (bool, time.Duration, error)
+}
+
+// Limiter enforces the policies with the generic cell rate algorithm. With
+// the Redis backend the budgets are shared by all instances, while Redis is
+// unreachable every instance falls back to budgets of its own.
+type Limiter struct {
+	store    Store
+	fallback Store
+	logger   *logger.Logger
+
+	mu       sync.Mutex
+	degraded bool
+}
+
+// NewLimiter returns the limiter for the configured backend, Redis unless
+// memory is asked for explicitly.
+func NewLimiter(backend string, redisRepo *repository.RedisRepository, logger *logger.Logger) *Limiter {
+	if backend == BackendMemory {
+		return &Limiter{store: NewMemoryStore(), logger: logger}
+	}
+
+	return &Limiter{
+		store:    NewRedisStore(redisRepo),
+		fallback: NewMemoryStore(),
+		logger:   logger,
+	}
+}
+
+// Allow spends one request of the key's budget under the policy, policies
+// without a rate allow everything.
+func (l *Limiter) Allow(policy Policy, key string) Result {
+	if policy.Rate <= 0 || policy.Period <= 0 {
+		return Result{Allowed: true}
+	}
+
+	burst := policy.Burst
+	if burst <= 0 {
+		burst = policy.Rate
+	}
+
+	interval := policy.Period / time.Duration(policy.Rate)
+	capacity := interval * time.Duration(burst)
+	key = policy.Name + ":" + key
+	now := time.Now()
+
+	allowed, ahead, err := l.store.Take(key, interval, capacity, now)
+	l.setDegraded(err)
+
+	if err != nil && l.fallback != nil {
+		allowed, ahead, _ = l.fallback.Take(key, interval, capacity, now)
+	}
+
+	if !allowed {
+		return Result{
+			Limit:      burst,
+			RetryAfter: ahead + interval - capacity,
+			Reset:      ahead,
+		}
+	}
+
+	return Result{
+		Allowed:   true,
+		Limit:     burst,
+		Remaining: int((capacity - ahead) / interval),
+		Reset:     ahead,
+	}
+}
+
+// setDegraded logs when the limiter starts and stops falling back, rather
+// than on every request.
+func (l *Limiter) setDegraded(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case err != nil && !l.degraded:
+		l.degraded = true
+		l.logger.LogError("rate limit store unavailable, using in-memory budgets", err)
+	case err == nil && l.degraded:
+		l.degraded = false
+		l.logger.LogInfo("rate limit store available again")
+	}
+}
+
+// RedisStore shares the budgets through Redis.
+type RedisStore struct {
+	repo *repository.RedisRepository
+}
+
+func NewRedisStore(redisRepo *repository.RedisRepository) *RedisStore {
+	return &RedisStore{repo: redisRepo}
+}
+
+func (s *RedisStore) Take(key string, interval, capacity time.Duration, now time.Time) (bool, time.Duration, error) {
+	return s.repo.TakeRateLimit(key, interval, capacity, now)
+}
+
+// MemoryStore keeps the budgets in process, they are lost on restart and
+// not shared between instances.
+type MemoryStore struct {
+	mu         sync.Mutex
+	arrivals   map[string]time.Time
+	lastPruned time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{arrivals: map[string]time.Time{}}
+}
+
+func (s *MemoryStore) Take(key string, interval, capacity time.Duration, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+
+	tat := s.arrivals[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	if newTat.Sub(now) > capacity {
+		return false, tat.Sub(now), nil
+	}
+
+	s.arrivals[key] = newTat
+
+	return true, newTat.Sub(now), nil
+}
+
+// prune drops the keys whose budget is full again.
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPruned) < memoryPruneInterval {
+		return
+	}
+
+	for key, tat := range s.arrivals {
+		if tat.Before(now) {
+			delete(s.arrivals, key)
+		}
+	}
+
+	s.lastPruned = now
+}
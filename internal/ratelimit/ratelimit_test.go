@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// TestStoreTake runs the same budget through both stores: one request per
+// second with a burst of three.
+func TestStoreTake(t *testing.T) {
+	const (
+		interval = time.Second
+		capacity = 3 * time.Second
+	)
+
+	steps := []struct {
+		name        string
+		at          time.Duration
+		wantAllowed bool
+		wantAhead   time.Duration
+	}{
+		{name: "first of the burst", at: 0, wantAllowed: true, wantAhead: time.Second},
+		{name: "second of the burst", at: 0, wantAllowed: true, wantAhead: 2 * time.Second},
+		{name: "last of the burst", at: 0, wantAllowed: true, wantAhead: 3 * time.Second},
+		{name: "burst spent", at: 0, wantAllowed: false, wantAhead: 3 * time.Second},
+		{name: "half an interval later", at: 500 * time.Millisecond, wantAllowed: false, wantAhead: 2500 * time.Millisecond},
+		{name: "one interval refilled", at: time.Second, wantAllowed: true, wantAhead: 3 * time.Second},
+		{name: "spent again", at: time.Second, wantAllowed: false, wantAhead: 3 * time.Second},
+		{name: "fully refilled", at: time.Minute, wantAllowed: true, wantAhead: time.Second},
+	}
+
+	conn, _ := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+
+	stores := map[string]Store{
+		BackendMemory: NewMemoryStore(),
+		BackendRedis:  NewRedisStore(redisRepo),
+	}
+
+	start := time.Unix(1700000000, 0)
+
+	for backend, store := range stores {
+		for _, step := range steps {
+			allowed, ahead, err := store.Take("test:key", interval, capacity, start.Add(step.at))
+			if err != nil {
+				t.Fatalf("%s %s: %v", backend, step.name, err)
+			}
+
+			if allowed != step.wantAllowed || ahead != step.wantAhead {
+				t.Errorf("%s %s: allowed = %v, ahead = %v, want %v, %v",
+					backend, step.name, allowed, ahead, step.wantAllowed, step.wantAhead)
+			}
+		}
+	}
+}
+
+func TestRedisStoreExpiresFullBudgets(t *testing.T) {
+	conn, mr := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+	store := NewRedisStore(redisRepo)
+
+	if _, _, err := store.Take("test:key", time.Second, 3*time.Second, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := mr.TTL("ratelimit:test:key"); ttl <= 0 || ttl > time.Second {
+		t.Fatalf("ttl = %v, want the time until the budget is full again", ttl)
+	}
+
+	mr.FastForward(time.Second)
+
+	if mr.Exists("ratelimit:test:key") {
+		t.Fatal("key kept after the budget was full again")
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	policy := Policy{Name: "create", Rate: 60, Period: time.Minute, Burst: 2}
+
+	tests := []struct {
+		name          string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{name: "first", wantAllowed: true, wantRemaining: 1},
+		{name: "second", wantAllowed: true, wantRemaining: 0},
+		{name: "throttled", wantAllowed: false},
+	}
+
+	limiter := NewLimiter(BackendMemory, nil, logger.NewLogger(zap.NewNop()))
+
+	for _, tt := range tests {
+		result := limiter.Allow(policy, "api_key:1")
+
+		if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.Limit != policy.Burst {
+			t.Fatalf("%s: result = %+v", tt.name, result)
+		}
+
+		if result.Allowed && result.RetryAfter != 0 {
+			t.Errorf("%s: retry after %v on an allowed request", tt.name, result.RetryAfter)
+		}
+	}
+
+	// One request frees up every second, the budget is full again once both
+	// requests are paid back.
+	result := limiter.Allow(policy, "api_key:1")
+	if result.RetryAfter <= 900*time.Millisecond || result.RetryAfter > time.Second {
+		t.Errorf("retry after = %v, want just under a second", result.RetryAfter)
+	}
+
+	if result.Reset <= 1900*time.Millisecond || result.Reset > 2*time.Second {
+		t.Errorf("reset = %v, want just under two seconds", result.Reset)
+	}
+
+	if other := limiter.Allow(policy, "api_key:2"); !other.Allowed {
+		t.Error("another key was throttled")
+	}
+
+	if other := limiter.Allow(Policy{Name: "redirect", Rate: 60, Period: time.Minute, Burst: 2}, "api_key:1"); !other.Allowed {
+		t.Error("the key was throttled under another policy")
+	}
+}
+
+func TestLimiterWithoutRate(t *testing.T) {
+	limiter := NewLimiter(BackendMemory, nil, logger.NewLogger(zap.NewNop()))
+
+	for i := 0; i < 100; i++ {
+		if result := limiter.Allow(Policy{Name: "open"}, "ip:1"); !result.Allowed {
+			t.Fatalf("request %d throttled without a rate", i)
+		}
+	}
+}
+
+func TestLimiterFallsBackWhenRedisIsDown(t *testing.T) {
+	conn, mr := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+	limiter := NewLimiter(BackendRedis, redisRepo, logger.NewLogger(zap.NewNop()))
+	policy := Policy{Name: "create", Rate: 1, Period: time.Minute, Burst: 1}
+
+	mr.Close()
+
+	if result := limiter.Allow(policy, "ip:1"); !result.Allowed {
+		t.Fatalf("first request throttled: %+v", result)
+	}
+
+	if result := limiter.Allow(policy, "ip:1"); result.Allowed {
+		t.Fatal("in-memory budget not enforced while Redis is down")
+	}
+}
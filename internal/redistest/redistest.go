@@ -0,0 +1,22 @@
+package redistest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+)
+
+// NewClient starts a miniredis server for the test and returns a client of
+// it. The client does not retry, so tests closing the server see the error
+// right away. Both are closed when the test ends.
+func NewClient(t testing.TB) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+
+	conn := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, mr
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	abuseReportsPrefix   = "abuse:reports:"
+	abuseReportersPrefix = "abuse:reporters:"
+	abuseHistoryPrefix   = "abuse:history:"
+	// Sorted set of the reported codes of every workspace, scored by their
+	// latest report. Codes are unique across workspaces.
+	abuseQueue = "abuse:queue"
+
+	fieldReports    = "reports"
+	fieldDisabled   = "disabled"
+	fieldDisabledAt = "disabled_at"
+	fieldDisabledBy = "disabled_by"
+)
+
+// AbuseReport is a report filed against a link by one of its visitors.
+type AbuseReport struct {
+	Reason  string
+	Details string
+	Email   string
+	// Keyed hash of the reporter address, it only tells reporters apart.
+	Reporter  string
+	CreatedAt time.Time
+}
+
+// ModerationEntry is one moderator decision about a link, the entries of a
+// link are its audit trail.
+type ModerationEntry struct {
+	Action string
+	Reason string
+	Actor  string
+	At     time.Time
+}
+
+// QueuedReports is a link waiting for a moderator.
+type QueuedReports struct {
+	Code           string
+	LastReportedAt time.Time
+}
+
+// addAbuseReportScript files a report unless its reporter already reported
+// the link, in which case it returns 0. The reporter is only remembered once
+// the report is stored, so a failed report can be retried. KEYS are the
+// reporters, the reports, the link metadata and the queue. ARGV holds the
+// reporter, the stream length, the queue score and the code, followed by the
+// fields of the report.
+var addAbuseReportScript = redis.NewScript(`
+if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then
+	return 0
+end
+
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], '*', unpack(ARGV, 5))
+redis.call('HINCRBY', KEYS[3], 'reports', 1)
+redis.call('ZADD', KEYS[4], ARGV[3], ARGV[4])
+redis.call('SADD', KEYS[1], ARGV[1])
+
+return 1
+`)
+
+// AddAbuseReport files the report and queues the link for moderation. Each
+// reporter counts once per link until the reports are resolved, it reports
+// false for repeated reports, which are not stored.
+func (r *RedisRepository) AddAbuseReport(code string, report AbuseReport, maxReports int64) (bool, error) {
+	keys := []string{
+		r.prefix + abuseReportersPrefix + code,
+		r.prefix + abuseReportsPrefix + code,
+		r.prefix + linkMetaPrefix + code,
+		abuseQueue,
+	}
+
+	added, err := addAbuseReportScript.Run(context.TODO(), r.conn, keys,
+		report.Reporter, maxReports, report.CreatedAt.Unix(), code,
+		"ts", report.CreatedAt.UnixMilli(),
+		"reason", report.Reason,
+		"details", report.Details,
+		"email", report.Email,
+		"reporter", report.Reporter,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return added == 1, nil
+}
+
+// AbuseReports returns the newest reports of the link first.
+func (r *RedisRepository) AbuseReports(code string, limit int64) ([]AbuseReport, error) {
+	entries, err := r.conn.XRevRangeN(context.TODO(), r.prefix+abuseReportsPrefix+code, "+", "-", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]AbuseReport, len(entries))
+
+	for i, entry := range entries {
+		reports[i] = AbuseReport{
+			Reason:    streamString(entry.Values, "reason"),
+			Details:   streamString(entry.Values, "details"),
+			Email:     streamString(entry.Values, "email"),
+			Reporter:  streamString(entry.Values, "reporter"),
+			CreatedAt: streamTime(entry.Values, "ts"),
+		}
+	}
+
+	return reports, nil
+}
+
+// ReportQueue returns the links with unresolved reports, the most recently
+// reported first.
+func (r *RedisRepository) ReportQueue(offset, limit int64) ([]QueuedReports, int64, error) {
+	var (
+		page  *redis.ZSliceCmd
+		total *redis.IntCmd
+	)
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		page = pipe.ZRevRangeWithScores(context.TODO(), abuseQueue, offset, offset+limit-1)
+		total = pipe.ZCard(context.TODO(), abuseQueue)
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	queue := make([]QueuedReports, len(page.Val()))
+
+	for i, z := range page.Val() {
+		code, _ := z.Member.(string)
+		queue[i] = QueuedReports{
+			Code:           code,
+			LastReportedAt: time.Unix(int64(z.Score), 0).UTC(),
+		}
+	}
+
+	return queue, total.Val(), nil
+}
+
+// DisableLink stops the link from redirecting until it is restored. Unlike a
+// quarantine it is never lifted by screening or by editing the link.
+func (r *RedisRepository) DisableLink(code string, entry ModerationEntry) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.TODO(), r.prefix+linkMetaPrefix+code,
+			fieldDisabled, entry.Reason,
+			fieldDisabledAt, entry.At.Unix(),
+			fieldDisabledBy, entry.Actor,
+		)
+		r.resolveReports(pipe, code, entry)
+
+		return nil
+	})
+
+	return err
+}
+
+// RestoreLink lets a disabled link redirect again. Restoring a link that is
+// not disabled only resolves its reports.
+func (r *RedisRepository) RestoreLink(code string, entry ModerationEntry) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HDel(context.TODO(), r.prefix+linkMetaPrefix+code, fieldDisabled, fieldDisabledAt, fieldDisabledBy)
+		r.resolveReports(pipe, code, entry)
+
+		return nil
+	})
+
+	return err
+}
+
+// ModerationHistory returns the audit trail of the link, oldest first.
+func (r *RedisRepository) ModerationHistory(code string) ([]ModerationEntry, error) {
+	entries, err := r.conn.XRange(context.TODO(), r.prefix+abuseHistoryPrefix+code, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ModerationEntry, len(entries))
+
+	for i, entry := range entries {
+		history[i] = ModerationEntry{
+			Action: streamString(entry.Values, "action"),
+			Reason: streamString(entry.Values, "reason"),
+			Actor:  streamString(entry.Values, "actor"),
+			At:     streamTime(entry.Values, "ts"),
+		}
+	}
+
+	return history, nil
+}
+
+// resolveReports records the decision and takes the link off the queue. The
+// reporters and the count of unresolved reports are reset so the link can be
+// reported again later, the reports themselves are kept as evidence.
+func (r *RedisRepository) resolveReports(pipe redis.Pipeliner, code string, entry ModerationEntry) {
+	pipe.XAdd(context.TODO(), &redis.XAddArgs{
+		Stream: r.prefix + abuseHistoryPrefix + code,
+		Values: []interface{}{
+			"ts", entry.At.UnixMilli(),
+			"action", entry.Action,
+			"reason", entry.Reason,
+			"actor", entry.Actor,
+		},
+	})
+	pipe.ZRem(context.TODO(), abuseQueue, code)
+	pipe.Del(context.TODO(), r.prefix+abuseReportersPrefix+code)
+	pipe.HDel(context.TODO(), r.prefix+linkMetaPrefix+code, fieldReports)
+}
+
+func streamString(values map[string]interface{}, field string) string {
+	value, _ := values[field].(string)
+
+	return value
+}
+
+func streamTime(values map[string]interface{}, field string) time.Time {
+	millis, err := strconv.ParseInt(streamString(values, field), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(millis).UTC()
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url-shortener/internal/redistest"
+)
+
+func TestAddAbuseReport(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	repo := NewRedisRepository(conn)
+
+	if err := repo.Store(Link{Hash: "abc123", URL: "https://example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := func(reporter string) (bool, error) {
+		return repo.AddAbuseReport("abc123", AbuseReport{Reason: "phishing", Reporter: reporter, CreatedAt: time.Now()}, 100)
+	}
+
+	reports := func() int64 {
+		t.Helper()
+
+		link, err := repo.RetrieveLink("abc123")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return link.Reports
+	}
+
+	// A report that fails to be stored does not count its reporter.
+	if err := conn.Set(context.TODO(), abuseReportsPrefix+"abc123", "not a stream", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := report("r1"); err == nil {
+		t.Fatal("report stored in a key of another type")
+	}
+
+	if err := conn.Del(context.TODO(), abuseReportsPrefix+"abc123").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		reporter    string
+		want        bool
+		wantReports int64
+	}{
+		{name: "retried report", reporter: "r1", want: true, wantReports: 1},
+		{name: "repeated report", reporter: "r1", wantReports: 1},
+		{name: "other reporter", reporter: "r2", want: true, wantReports: 2},
+	}
+
+	for _, step := range steps {
+		added, err := report(step.reporter)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if added != step.want {
+			t.Errorf("%s: added = %v, want %v", step.name, added, step.want)
+		}
+
+		if got := reports(); got != step.wantReports {
+			t.Errorf("%s: reports = %d, want %d", step.name, got, step.wantReports)
+		}
+	}
+
+	stored, err := repo.AbuseReports("abc123", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 2 || stored[0].Reporter != "r2" || stored[1].Reporter != "r1" {
+		t.Errorf("reports = %+v, want the ones of r2 and r1", stored)
+	}
+
+	// Resolving the reports resets the count and lets reporters report again.
+	if err = repo.RestoreLink("abc123", ModerationEntry{Action: "restore", Actor: "admin", At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reports(); got != 0 {
+		t.Errorf("reports = %d after resolving them, want 0", got)
+	}
+
+	if _, total, _ := repo.ReportQueue(0, 10); total != 0 {
+		t.Errorf("%d links queued after resolving their reports", total)
+	}
+
+	if added, _ := report("r1"); !added || reports() != 1 {
+		t.Errorf("report after resolving: added = %v, reports = %d", added, reports())
+	}
+
+	if stored, _ = repo.AbuseReports("abc123", 100); len(stored) != 3 {
+		t.Errorf("%d reports kept, want the resolved ones as evidence", len(stored))
+	}
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	membersKey      = "members"
+	linkSharePrefix = "shares:"
+)
+
+// SetMember gives the user the role in the repository workspace.
+func (r *RedisRepository) SetMember(user, role string) error {
+	return r.conn.HSet(context.TODO(), r.prefix+membersKey, user, role).Err()
+}
+
+// RemoveMember reports whether the user was a member.
+func (r *RedisRepository) RemoveMember(user string) (bool, error) {
+	removed, err := r.conn.HDel(context.TODO(), r.prefix+membersKey, user).Result()
+
+	return removed > 0, err
+}
+
+// MemberRole returns the role of the user, empty when they are not a member.
+func (r *RedisRepository) MemberRole(user string) (string, error) {
+	role, err := r.conn.HGet(context.TODO(), r.prefix+membersKey, user).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// Members returns the role of every member by user.
+func (r *RedisRepository) Members() (map[string]string, error) {
+	return r.conn.HGetAll(context.TODO(), r.prefix+membersKey).Result()
+}
+
+// ShareLink gives the user the role on a single link.
+func (r *RedisRepository) ShareLink(code, user, role string) error {
+	return r.conn.HSet(context.TODO(), r.prefix+linkSharePrefix+code, user, role).Err()
+}
+
+// UnshareLink reports whether the link was shared with the user.
+func (r *RedisRepository) UnshareLink(code, user string) (bool, error) {
+	removed, err := r.conn.HDel(context.TODO(), r.prefix+linkSharePrefix+code, user).Result()
+
+	return removed > 0, err
+}
+
+// LinkShareRole returns the role the link was shared with the user with,
+// empty when it was not.
+func (r *RedisRepository) LinkShareRole(code, user string) (string, error) {
+	role, err := r.conn.HGet(context.TODO(), r.prefix+linkSharePrefix+code, user).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// LinkShares returns the role of every user the link was shared with.
+func (r *RedisRepository) LinkShares(code string) (map[string]string, error) {
+	return r.conn.HGetAll(context.TODO(), r.prefix+linkSharePrefix+code).Result()
+}
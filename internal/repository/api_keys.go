@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	apiKeyPrefix     = "apikey:"
+	apiKeyHashPrefix = "apikey:hash:"
+	apiKeysSet       = "apikeys"
+
+	fieldName       = "name"
+	fieldWorkspace  = "workspace"
+	fieldKeyHash    = "hash"
+	fieldScopes     = "scopes"
+	fieldLastUsedAt = "last_used_at"
+	fieldRevokedAt  = "revoked_at"
+)
+
+// APIKey is an API key as stored, only the hash of the secret is kept.
+type APIKey struct {
+	ID         string
+	Workspace  string
+	Name       string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+// StoreAPIKey saves a key of the repository workspace. Keys are stored
+// outside of the workspace keyspace so any of them can be looked up by hash.
+func (r *RedisRepository) StoreAPIKey(key APIKey) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.TODO(), apiKeyPrefix+key.ID,
+			fieldWorkspace, r.workspace,
+			fieldName, key.Name,
+			fieldKeyHash, key.Hash,
+			fieldScopes, strings.Join(key.Scopes, ","),
+			fieldCreatedAt, key.CreatedAt.Unix(),
+		)
+		pipe.Set(context.TODO(), apiKeyHashPrefix+key.Hash, key.ID, 0)
+		pipe.SAdd(context.TODO(), r.prefix+apiKeysSet, key.ID)
+
+		return nil
+	})
+
+	return err
+}
+
+// APIKey returns the key with the id, or a zero APIKey when there is none.
+func (r *RedisRepository) APIKey(id string) (APIKey, error) {
+	fields, err := r.conn.HGetAll(context.TODO(), apiKeyPrefix+id).Result()
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return parseAPIKey(id, fields), nil
+}
+
+// APIKeyByHash looks a key up by the hash of its secret. Revoked keys are
+// not found.
+func (r *RedisRepository) APIKeyByHash(hash string) (APIKey, error) {
+	id, err := r.conn.Get(context.TODO(), apiKeyHashPrefix+hash).Result()
+	if err == redis.Nil {
+		return APIKey{}, nil
+	}
+
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return r.APIKey(id)
+}
+
+// APIKeys lists the keys of the repository workspace.
+func (r *RedisRepository) APIKeys() ([]APIKey, error) {
+	ids, err := r.conn.SMembers(context.TODO(), r.prefix+apiKeysSet).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+
+	_, err = r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(context.TODO(), apiKeyPrefix+id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKey, 0, len(ids))
+
+	for i, id := range ids {
+		if key := parseAPIKey(id, cmds[i].Val()); key.ID != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks the key revoked and removes its hash, so it stops
+// authenticating at once but stays listed.
+func (r *RedisRepository) RevokeAPIKey(key APIKey, at time.Time) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.TODO(), apiKeyPrefix+key.ID, fieldRevokedAt, at.Unix())
+		pipe.Del(context.TODO(), apiKeyHashPrefix+key.Hash)
+
+		return nil
+	})
+
+	return err
+}
+
+func (r *RedisRepository) TouchAPIKey(id string, at time.Time) error {
+	return r.conn.HSet(context.TODO(), apiKeyPrefix+id, fieldLastUsedAt, at.Unix()).Err()
+}
+
+func parseAPIKey(id string, fields map[string]string) APIKey {
+	if len(fields) == 0 {
+		return APIKey{}
+	}
+
+	key := APIKey{
+		ID:         id,
+		Workspace:  fields[fieldWorkspace],
+		Name:       fields[fieldName],
+		Hash:       fields[fieldKeyHash],
+		CreatedAt:  parseUnix(fields[fieldCreatedAt]),
+		LastUsedAt: parseUnix(fields[fieldLastUsedAt]),
+		RevokedAt:  parseUnix(fields[fieldRevokedAt]),
+	}
+
+	if key.Workspace == "" {
+		key.Workspace = DefaultWorkspace
+	}
+
+	if fields[fieldScopes] != "" {
+		key.Scopes = strings.Split(fields[fieldScopes], ",")
+	}
+
+	return key
+}
+
+func parseUnix(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(seconds, 0).UTC()
+}
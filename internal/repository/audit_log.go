@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const auditStream = "audit"
+
+// AuditRecord is an entry of the audit log of a workspace. The states before
+// and after the change are kept as the JSON the service encoded them to.
+type AuditRecord struct {
+	ID        string
+	Time      time.Time
+	Actor     string
+	Action    string
+	Code      string
+	Target    string
+	Before    string
+	After     string
+	RequestID string
+}
+
+// AppendAudit adds the record to the audit log of the repository workspace,
+// records older than the retention are trimmed, zero keeps them all.
+func (r *RedisRepository) AppendAudit(record AuditRecord, retention time.Duration) error {
+	args := &redis.XAddArgs{
+		Stream: r.prefix + auditStream,
+		Values: []interface{}{
+			"ts", record.Time.UnixMilli(),
+			"actor", record.Actor,
+			"action", record.Action,
+			"code", record.Code,
+			"target", record.Target,
+			"before", record.Before,
+			"after", record.After,
+			"request_id", record.RequestID,
+		},
+	}
+
+	if retention > 0 {
+		args.MinID = strconv.FormatInt(record.Time.Add(-retention).UnixMilli(), 10)
+		args.Approx = true
+	}
+
+	return r.conn.XAdd(context.TODO(), args).Err()
+}
+
+// AuditRecords returns up to count records between the stream ids, newest
+// first. The range is inclusive, "-" and "+" leave it open.
+func (r *RedisRepository) AuditRecords(start, end string, count int64) ([]AuditRecord, error) {
+	entries, err := r.conn.XRevRangeN(context.TODO(), r.prefix+auditStream, end, start, count).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]AuditRecord, len(entries))
+
+	for i, entry := range entries {
+		records[i] = AuditRecord{
+			ID:        entry.ID,
+			Time:      streamTime(entry.Values, "ts"),
+			Actor:     streamString(entry.Values, "actor"),
+			Action:    streamString(entry.Values, "action"),
+			Code:      streamString(entry.Values, "code"),
+			Target:    streamString(entry.Values, "target"),
+			Before:    streamString(entry.Values, "before"),
+			After:     streamString(entry.Values, "after"),
+			RequestID: streamString(entry.Values, "request_id"),
+		}
+	}
+
+	return records, nil
+}
+
+// PrecedingStreamID returns the greatest stream id lower than the id, so a
+// range ending there excludes it. It is empty when the id does not parse or
+// nothing precedes it.
+func PrecedingStreamID(id string) string {
+	millisPart, seqPart, _ := strings.Cut(id, "-")
+
+	millis, err := strconv.ParseUint(millisPart, 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		seq = 0
+	}
+
+	switch {
+	case seq > 0:
+		return strconv.FormatUint(millis, 10) + "-" + strconv.FormatUint(seq-1, 10)
+	case millis > 0:
+		return strconv.FormatUint(millis-1, 10) + "-" + strconv.FormatUint(^uint64(0), 10)
+	default:
+		return ""
+	}
+}
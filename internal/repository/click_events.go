@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// ClickEvent is a single followed short link.
+type ClickEvent struct {
+	Timestamp time.Time
+	Workspace string
+	Code      string
+	Referrer  string
+	UserAgent string
+	IP        string
+	Country   string
+	// Crawlers and unfurlers are redirected but left out of the link stats.
+	Bot bool
+	// The visitor sent DNT or Sec-GPC.
+	DoNotTrack bool
+
+	// Derived by the analytics pipeline before the event is stored.
+	ReferrerDomain string
+	Device         string
+	Browser        string
+	VisitorID      string
+}
+
+// AppendClickEvents writes the batch to the stream in one round trip, the
+// stream is capped approximately at maxLen entries.
+func (r *RedisRepository) AppendClickEvents(stream string, maxLen int64, events []ClickEvent) error {
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for _, event := range events {
+			pipe.XAdd(context.TODO(), &redis.XAddArgs{
+				Stream: stream,
+				MaxLen: maxLen,
+				Approx: true,
+				Values: []interface{}{
+					"ts", event.Timestamp.UnixMilli(),
+					"workspace", event.Workspace,
+					"code", event.Code,
+					"referrer", event.Referrer,
+					"ua", event.UserAgent,
+					"ip", event.IP,
+					"country", event.Country,
+					"referrer_domain", event.ReferrerDomain,
+					"device", event.Device,
+					"browser", event.Browser,
+					"visitor", event.VisitorID,
+					"bot", event.Bot,
+				},
+			})
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// TrimClickEvents removes the stream entries added before the given time.
+func (r *RedisRepository) TrimClickEvents(stream string, before time.Time) (int64, error) {
+	return r.conn.XTrimMinID(context.TODO(), stream, strconv.FormatInt(before.UnixMilli(), 10)).Result()
+}
+
+// GroupByWorkspace splits a batch into the events of each workspace.
+func GroupByWorkspace(events []ClickEvent) map[string][]ClickEvent {
+	groups := map[string][]ClickEvent{}
+
+	for _, event := range events {
+		workspace := event.Workspace
+		if workspace == "" {
+			workspace = DefaultWorkspace
+		}
+
+		groups[workspace] = append(groups[workspace], event)
+	}
+
+	return groups
+}
+
+// eventWorkspace reads the workspace of a stream entry, entries written
+// before workspaces existed belong to the default one.
+func eventWorkspace(values map[string]interface{}) string {
+	if workspace, _ := values["workspace"].(string); workspace != "" {
+		return workspace
+	}
+
+	return DefaultWorkspace
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	scanCount      = 1000
+	streamPageSize = 1000
+)
+
+// EraseLinkStats deletes every counter of the link: minute, hourly and daily
+// buckets, the minutes pending rollup and its leaderboard entries.
+func (r *RedisRepository) EraseLinkStats(code string) error {
+	pattern := escapePattern(r.prefix) + statsPrefix + escapePattern(code) + ":*"
+	if err := r.deleteMatching(pattern); err != nil {
+		return err
+	}
+
+	var pending []interface{}
+
+	pendingKeys := r.prefix + pendingRollups
+
+	iter := r.conn.SScan(context.TODO(), pendingKeys, 0, pattern, scanCount).Iterator()
+	for iter.Next(context.TODO()) {
+		pending = append(pending, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		if err := r.conn.SRem(context.TODO(), pendingKeys, pending...).Err(); err != nil {
+			return err
+		}
+	}
+
+	leaderboards := r.conn.Scan(context.TODO(), 0, escapePattern(r.prefix)+leaderboardPrefix+"*", scanCount).Iterator()
+	for leaderboards.Next(context.TODO()) {
+		if err := r.conn.ZRem(context.TODO(), leaderboards.Val(), code).Err(); err != nil {
+			return err
+		}
+	}
+
+	return leaderboards.Err()
+}
+
+// EraseVisitors deletes the unique visitor estimates of the link.
+func (r *RedisRepository) EraseVisitors(code string) error {
+	return r.deleteMatching(escapePattern(r.prefix) + visitorsPrefix + escapePattern(code) + ":*")
+}
+
+// EraseClickEvents deletes the raw events of the workspace link from the
+// stream.
+func (r *RedisRepository) EraseClickEvents(stream, code string) (int64, error) {
+	var (
+		start   = "-"
+		deleted int64
+	)
+
+	for {
+		messages, err := r.conn.XRangeN(context.TODO(), stream, start, "+", streamPageSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		var ids []string
+
+		for _, message := range messages {
+			if message.Values["code"] == code && eventWorkspace(message.Values) == r.workspace {
+				ids = append(ids, message.ID)
+			}
+		}
+
+		if len(ids) > 0 {
+			n, err := r.conn.XDel(context.TODO(), stream, ids...).Result()
+			if err != nil {
+				return deleted, err
+			}
+
+			deleted += n
+		}
+
+		if len(messages) < streamPageSize {
+			return deleted, nil
+		}
+
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+func (r *RedisRepository) deleteMatching(pattern string) error {
+	iter := r.conn.Scan(context.TODO(), 0, pattern, scanCount).Iterator()
+
+	var keys []string
+
+	for iter.Next(context.TODO()) {
+		keys = append(keys, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(context.TODO(), key)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// escapePattern quotes the glob characters of a value used in a SCAN match.
+func escapePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(value)
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+type LeaderboardWindow string
+
+const (
+	WindowHour LeaderboardWindow = "hour"
+	WindowDay  LeaderboardWindow = "day"
+	WindowWeek LeaderboardWindow = "week"
+
+	leaderboardPrefix = "top:"
+)
+
+// leaderboardGranularity is a series of sorted sets, one per bucket, that
+// expire on their own once no window can reach them anymore.
+type leaderboardGranularity struct {
+	name   string
+	layout string
+	step   time.Duration
+	ttl    time.Duration
+}
+
+var (
+	minuteLeaderboard = leaderboardGranularity{name: "m", layout: "200601021504", step: time.Minute, ttl: 2 * time.Hour}
+	hourLeaderboard   = leaderboardGranularity{name: "h", layout: "2006010215", step: time.Hour, ttl: 2 * 24 * time.Hour}
+	dayLeaderboard    = leaderboardGranularity{name: "d", layout: "20060102", step: 24 * time.Hour, ttl: 9 * 24 * time.Hour}
+)
+
+// Each window is the union of the latest buckets of one granularity.
+var leaderboardWindows = map[LeaderboardWindow]struct {
+	granularity leaderboardGranularity
+	buckets     int
+}{
+	WindowHour: {granularity: minuteLeaderboard, buckets: 60},
+	WindowDay:  {granularity: hourLeaderboard, buckets: 24},
+	WindowWeek: {granularity: dayLeaderboard, buckets: 7},
+}
+
+type LeaderboardEntry struct {
+	Code        string
+	Destination string
+	Clicks      int64
+}
+
+func ValidLeaderboardWindow(window LeaderboardWindow) bool {
+	_, ok := leaderboardWindows[window]
+
+	return ok
+}
+
+func (g leaderboardGranularity) key(t time.Time) string {
+	return leaderboardPrefix + g.name + ":" + t.UTC().Truncate(g.step).Format(g.layout)
+}
+
+// IncrementLeaderboards counts the human clicks in every leaderboard bucket.
+func (r *RedisRepository) IncrementLeaderboards(events []ClickEvent) error {
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		touched := map[string]time.Duration{}
+
+		for _, event := range events {
+			if event.Bot {
+				continue
+			}
+
+			for _, g := range []leaderboardGranularity{minuteLeaderboard, hourLeaderboard, dayLeaderboard} {
+				key := r.prefix + g.key(event.Timestamp)
+				pipe.ZIncrBy(context.TODO(), key, 1, event.Code)
+				touched[key] = g.ttl
+			}
+		}
+
+		for key, ttl := range touched {
+			pipe.Expire(context.TODO(), key, ttl)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// TopLinks returns the most clicked links of the window ending now.
+func (r *RedisRepository) TopLinks(window LeaderboardWindow, limit int64) ([]LeaderboardEntry, error) {
+	w := leaderboardWindows[window]
+
+	now := time.Now()
+	keys := make([]string, w.buckets)
+
+	for i := range keys {
+		keys[i] = r.prefix + w.granularity.key(now.Add(-time.Duration(i)*w.granularity.step))
+	}
+
+	dest := r.prefix + leaderboardPrefix + "tmp:" + string(window) + ":" + strconv.FormatInt(now.UnixNano(), 10)
+
+	var top *redis.ZSliceCmd
+
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(context.TODO(), dest, &redis.ZStore{Keys: keys})
+		top = pipe.ZRevRangeWithScores(context.TODO(), dest, 0, limit-1)
+		pipe.Del(context.TODO(), dest)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := top.Val()
+	if len(members) == 0 {
+		return []LeaderboardEntry{}, nil
+	}
+
+	codes := make([]string, len(members))
+	keys = make([]string, len(members))
+
+	for i, member := range members {
+		codes[i], _ = member.Member.(string)
+		keys[i] = r.prefix + codes[i]
+	}
+
+	destinations, err := r.conn.MGet(context.TODO(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LeaderboardEntry, len(members))
+
+	for i, member := range members {
+		destination, _ := destinations[i].(string)
+
+		entries[i] = LeaderboardEntry{
+			Code:        codes[i],
+			Destination: destination,
+			Clicks:      int64(member.Score),
+		}
+	}
+
+	return entries, nil
+}
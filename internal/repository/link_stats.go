@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+type StatsInterval string
+
+const (
+	IntervalHour StatsInterval = "hour"
+	IntervalDay  StatsInterval = "day"
+	// Clicks land in minute buckets first and are rolled up from there.
+	intervalMinute StatsInterval = "minute"
+
+	statsPrefix     = "stats:"
+	visitorsPrefix  = "hll:"
+	pendingRollups  = "stats:pending"
+	pendingSuffix   = ":pending"
+	hourlyRetention = 90 * 24 * time.Hour
+
+	FieldTotal    = "total"
+	FieldBots     = "bots"
+	FieldReferrer = "referrer:"
+	FieldCountry  = "country:"
+	FieldDevice   = "device:"
+	FieldBrowser  = "browser:"
+)
+
+var bucketLayouts = map[StatsInterval]string{
+	intervalMinute: "200601021504",
+	IntervalHour:   "2006010215",
+	IntervalDay:    "20060102",
+}
+
+// StatsBucket is the pre-aggregated clicks of a link for one interval.
+type StatsBucket struct {
+	Start    time.Time
+	Counters map[string]int64
+}
+
+func BucketStart(t time.Time, interval StatsInterval) time.Time {
+	switch interval {
+	case IntervalDay:
+		return t.UTC().Truncate(24 * time.Hour)
+	case intervalMinute:
+		return t.UTC().Truncate(time.Minute)
+	default:
+		return t.UTC().Truncate(time.Hour)
+	}
+}
+
+func (r *RedisRepository) statsKey(code string, interval StatsInterval, start time.Time) string {
+	return r.prefix + statsPrefix + code + ":" + string(interval[0]) + ":" + start.UTC().Format(bucketLayouts[interval])
+}
+
+// pendingKey names the set of minute buckets of a link not rolled up yet.
+func (r *RedisRepository) pendingKey(code string) string {
+	return r.prefix + statsPrefix + code + pendingSuffix
+}
+
+// VisitorsKey names the unique visitors estimate of a link for one interval
+// inside a workspace keyspace.
+func VisitorsKey(code string, interval StatsInterval, start time.Time) string {
+	return visitorsPrefix + code + ":" + string(interval[0]) + ":" + start.UTC().Format(bucketLayouts[interval])
+}
+
+// IncrementLinkStats adds the clicks to the minute counters of their links
+// and marks those for the rollup. Bot clicks only count towards FieldBots.
+func (r *RedisRepository) IncrementLinkStats(events []ClickEvent) error {
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pending := map[string]string{}
+
+		for _, event := range events {
+			key := r.statsKey(event.Code, intervalMinute, BucketStart(event.Timestamp, intervalMinute))
+			pending[key] = event.Code
+
+			if event.Bot {
+				pipe.HIncrBy(context.TODO(), key, FieldBots, 1)
+
+				continue
+			}
+
+			pipe.HIncrBy(context.TODO(), key, FieldTotal, 1)
+			pipe.HIncrBy(context.TODO(), key, FieldReferrer+event.ReferrerDomain, 1)
+			pipe.HIncrBy(context.TODO(), key, FieldCountry+event.Country, 1)
+			pipe.HIncrBy(context.TODO(), key, FieldDevice+event.Device, 1)
+			pipe.HIncrBy(context.TODO(), key, FieldBrowser+event.Browser, 1)
+		}
+
+		for key, code := range pending {
+			pipe.SAdd(context.TODO(), r.prefix+pendingRollups, key)
+			pipe.SAdd(context.TODO(), r.pendingKey(code), key)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// LinkStats reads the buckets starting at the given times. Minute buckets
+// that the rollup has not reached yet are folded in, so fresh clicks show up
+// right away however far behind the rollup is.
+func (r *RedisRepository) LinkStats(code string, interval StatsInterval, starts []time.Time) ([]StatsBucket, error) {
+	minuteKeys, err := r.conn.SMembers(context.TODO(), r.pendingKey(code)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make([]*redis.MapStringStringCmd, len(starts))
+	recent := make([]*redis.MapStringStringCmd, len(minuteKeys))
+
+	// MULTI keeps the rollup script from moving a minute bucket between reads.
+	// A minute rolled up since the set was read comes back empty and is
+	// counted in its hour and day buckets instead.
+	_, err = r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for i, start := range starts {
+			counters[i] = pipe.HGetAll(context.TODO(), r.statsKey(code, interval, start))
+		}
+
+		for i, key := range minuteKeys {
+			recent[i] = pipe.HGetAll(context.TODO(), key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]StatsBucket, len(starts))
+	index := make(map[time.Time]int, len(starts))
+
+	for i, start := range starts {
+		buckets[i] = StatsBucket{
+			Start:    start,
+			Counters: parseCounters(counters[i].Val()),
+		}
+		index[start] = i
+	}
+
+	for i, cmd := range recent {
+		_, minute, ok := r.parseMinuteKey(minuteKeys[i])
+		if !ok {
+			continue
+		}
+
+		bucket, ok := index[BucketStart(minute, interval)]
+		if !ok {
+			continue
+		}
+
+		for field, value := range parseCounters(cmd.Val()) {
+			buckets[bucket].Counters[field] += value
+		}
+	}
+
+	return buckets, nil
+}
+
+// rollupScript moves one minute bucket into its hour and day buckets.
+//
+// KEYS: minute bucket, hour bucket, day bucket, pending set, pending set of
+// the link.
+// ARGV: hour bucket ttl in seconds.
+var rollupScript = redis.NewScript(`
+local fields = redis.call('HGETALL', KEYS[1])
+for i = 1, #fields, 2 do
+	redis.call('HINCRBY', KEYS[2], fields[i], fields[i + 1])
+	redis.call('HINCRBY', KEYS[3], fields[i], fields[i + 1])
+end
+if #fields > 0 and tonumber(ARGV[1]) > 0 then
+	redis.call('EXPIRE', KEYS[2], ARGV[1])
+end
+redis.call('DEL', KEYS[1])
+redis.call('SREM', KEYS[4], KEYS[1])
+redis.call('SREM', KEYS[5], KEYS[1])
+return #fields / 2
+`)
+
+// RollupLinkStats folds every pending minute bucket older than before into
+// the hourly and daily series. Hourly buckets expire after hourlyTTL, daily
+// ones are kept. Each bucket is moved atomically, so concurrent rollups from
+// several instances never count a click twice. It returns the number of
+// minute buckets rolled up.
+func (r *RedisRepository) RollupLinkStats(before time.Time, hourlyTTL time.Duration) (int, error) {
+	if hourlyTTL <= 0 {
+		hourlyTTL = hourlyRetention
+	}
+
+	pending := r.prefix + pendingRollups
+
+	keys, err := r.conn.SMembers(context.TODO(), pending).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	rolled := 0
+
+	for _, key := range keys {
+		code, minute, ok := r.parseMinuteKey(key)
+		if !ok {
+			r.conn.SRem(context.TODO(), pending, key)
+
+			continue
+		}
+
+		if !minute.Before(before) {
+			continue
+		}
+
+		err = rollupScript.Run(context.TODO(), r.conn, []string{
+			key,
+			r.statsKey(code, IntervalHour, BucketStart(minute, IntervalHour)),
+			r.statsKey(code, IntervalDay, BucketStart(minute, IntervalDay)),
+			pending,
+			r.pendingKey(code),
+		}, int64(hourlyTTL/time.Second)).Err()
+		if err != nil {
+			return rolled, err
+		}
+
+		rolled++
+	}
+
+	return rolled, nil
+}
+
+// parseMinuteKey splits <prefix>stats:<code>:m:<minute>.
+func (r *RedisRepository) parseMinuteKey(key string) (string, time.Time, bool) {
+	marker := ":" + string(intervalMinute[0]) + ":"
+
+	rest := strings.TrimPrefix(key, r.prefix+statsPrefix)
+	i := strings.LastIndex(rest, marker)
+
+	if i < 0 || rest == key {
+		return "", time.Time{}, false
+	}
+
+	minute, err := time.Parse(bucketLayouts[intervalMinute], rest[i+len(marker):])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return rest[:i], minute, true
+}
+
+func parseCounters(fields map[string]string) map[string]int64 {
+	counters := make(map[string]int64, len(fields))
+
+	for field, value := range fields {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		counters[field] = n
+	}
+
+	return counters
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+const saltPrefix = "privacy:salt:"
+
+// SharedSalt returns the salt of the period, storing the candidate if no
+// instance has done so yet.
+func (r *RedisRepository) SharedSalt(period, candidate string, ttl time.Duration) (string, error) {
+	key := saltPrefix + period
+
+	if err := r.conn.SetNX(context.TODO(), key, candidate, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return r.conn.Get(context.TODO(), key).Result()
+}
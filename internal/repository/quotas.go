@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	quotaLimitsPrefix = "quota:limits:"
+	quotaUsagePrefix  = "quota:usage:"
+
+	fieldDaily = "daily"
+	fieldTotal = "total"
+
+	// Daily counters outlive their day a little so late releases still find
+	// them.
+	quotaDayTTL = 48 * time.Hour
+)
+
+// takeQuotaScript counts one link against every pair of daily and total
+// counters, unless one of them reached its limit. ARGV holds the limit of
+// each key, zero for none, followed by the TTL of the daily counters in
+// seconds. It returns 1 when the link was counted, 0 when not, followed by
+// the value of every counter.
+var takeQuotaScript = redis.NewScript(`
+local ttl = tonumber(ARGV[#KEYS + 1])
+local used = {}
+local allowed = 1
+
+for i, key in ipairs(KEYS) do
+	used[i] = tonumber(redis.call('GET', key) or '0')
+	local limit = tonumber(ARGV[i])
+	if limit > 0 and used[i] >= limit then
+		allowed = 0
+	end
+end
+
+if allowed == 1 then
+	for i, key in ipairs(KEYS) do
+		used[i] = redis.call('INCR', key)
+		if i % 2 == 1 then
+			redis.call('EXPIRE', key, ttl)
+		end
+	end
+end
+
+table.insert(used, 1, allowed)
+
+return used
+`)
+
+// QuotaLimits are the limits stored for a workspace or an API key, nil
+// fields are not set.
+type QuotaLimits struct {
+	Daily *int64
+	Total *int64
+}
+
+// QuotaOwner is what created links are counted against, a workspace or an
+// API key, with its limits. Zero limits are unlimited.
+type QuotaOwner struct {
+	Scope string
+	ID    string
+	Daily int64
+	Total int64
+}
+
+// QuotaUsage is the number of links an owner created on a day and overall.
+type QuotaUsage struct {
+	Daily int64
+	Total int64
+}
+
+func (r *RedisRepository) QuotaLimits(scope, id string) (QuotaLimits, error) {
+	fields, err := r.conn.HGetAll(context.TODO(), quotaLimitsPrefix+scope+":"+id).Result()
+	if err != nil {
+		return QuotaLimits{}, err
+	}
+
+	return QuotaLimits{
+		Daily: parseQuotaLimit(fields, fieldDaily),
+		Total: parseQuotaLimit(fields, fieldTotal),
+	}, nil
+}
+
+// SetQuotaLimits replaces the limits of the owner, nil fields are removed.
+func (r *RedisRepository) SetQuotaLimits(scope, id string, limits QuotaLimits) error {
+	key := quotaLimitsPrefix + scope + ":" + id
+
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.Del(context.TODO(), key)
+
+		if limits.Daily != nil {
+			pipe.HSet(context.TODO(), key, fieldDaily, *limits.Daily)
+		}
+
+		if limits.Total != nil {
+			pipe.HSet(context.TODO(), key, fieldTotal, *limits.Total)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// TakeQuota counts a link created on the day against every owner, or
+// against none when one of them is at a limit. It returns whether the link
+// was counted and the usage of each owner, after counting it.
+func (r *RedisRepository) TakeQuota(owners []QuotaOwner, day time.Time) (bool, []QuotaUsage, error) {
+	keys := make([]string, 0, 2*len(owners))
+	args := make([]interface{}, 0, 2*len(owners)+1)
+
+	for _, owner := range owners {
+		daily, total := quotaUsageKeys(owner, day)
+		keys = append(keys, daily, total)
+		args = append(args, owner.Daily, owner.Total)
+	}
+
+	args = append(args, int64(quotaDayTTL.Seconds()))
+
+	values, err := takeQuotaScript.Run(context.TODO(), r.conn, keys, args...).Int64Slice()
+	if err != nil {
+		return false, nil, err
+	}
+
+	usage := make([]QuotaUsage, len(owners))
+	for i := range owners {
+		usage[i] = QuotaUsage{Daily: values[2*i+1], Total: values[2*i+2]}
+	}
+
+	return values[0] == 1, usage, nil
+}
+
+// ReleaseQuota gives back a link counted by TakeQuota on the day.
+func (r *RedisRepository) ReleaseQuota(owners []QuotaOwner, day time.Time) error {
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for _, owner := range owners {
+			daily, total := quotaUsageKeys(owner, day)
+			pipe.Decr(context.TODO(), daily)
+			pipe.Decr(context.TODO(), total)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// QuotaUsage returns the links each owner created on the day and overall.
+func (r *RedisRepository) QuotaUsage(owners []QuotaOwner, day time.Time) ([]QuotaUsage, error) {
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, 2*len(owners))
+
+	for _, owner := range owners {
+		daily, total := quotaUsageKeys(owner, day)
+		keys = append(keys, daily, total)
+	}
+
+	values, err := r.conn.MGet(context.TODO(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]QuotaUsage, len(owners))
+	for i := range owners {
+		usage[i] = QuotaUsage{Daily: parseCount(values[2*i]), Total: parseCount(values[2*i+1])}
+	}
+
+	return usage, nil
+}
+
+func quotaUsageKeys(owner QuotaOwner, day time.Time) (string, string) {
+	prefix := quotaUsagePrefix + owner.Scope + ":" + owner.ID + ":"
+
+	return prefix + day.UTC().Format("20060102"), prefix + fieldTotal
+}
+
+func parseQuotaLimit(fields map[string]string, field string) *int64 {
+	value, ok := fields[field]
+	if !ok {
+		return nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &limit
+}
+
+func parseCount(value interface{}) int64 {
+	s, _ := value.(string)
+	count, _ := strconv.ParseInt(s, 10, 64)
+
+	return count
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"url-shortener/internal/redistest"
+)
+
+// TestTakeQuota counts links against a workspace limited to three links
+// overall and one of its API keys limited to two a day.
+func TestTakeQuota(t *testing.T) {
+	conn, mr := redistest.NewClient(t)
+	repo := NewRedisRepository(conn)
+
+	owners := []QuotaOwner{
+		{Scope: "workspace", ID: "acme", Total: 3},
+		{Scope: "api_key", ID: "k1", Daily: 2},
+	}
+	day1 := time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+
+	steps := []struct {
+		name        string
+		day         time.Time
+		release     bool
+		wantAllowed bool
+		wantUsage   []QuotaUsage
+	}{
+		{
+			name:        "first link",
+			day:         day1,
+			wantAllowed: true,
+			wantUsage:   []QuotaUsage{{Daily: 1, Total: 1}, {Daily: 1, Total: 1}},
+		},
+		{
+			name:        "second link",
+			day:         day1,
+			wantAllowed: true,
+			wantUsage:   []QuotaUsage{{Daily: 2, Total: 2}, {Daily: 2, Total: 2}},
+		},
+		{
+			name:      "daily limit of the key counts against nobody",
+			day:       day1,
+			wantUsage: []QuotaUsage{{Daily: 2, Total: 2}, {Daily: 2, Total: 2}},
+		},
+		{
+			name:        "next day",
+			day:         day2,
+			wantAllowed: true,
+			wantUsage:   []QuotaUsage{{Daily: 1, Total: 3}, {Daily: 1, Total: 3}},
+		},
+		{
+			name:      "total limit of the workspace",
+			day:       day2,
+			wantUsage: []QuotaUsage{{Daily: 1, Total: 3}, {Daily: 1, Total: 3}},
+		},
+		{
+			name:      "released link",
+			day:       day2,
+			release:   true,
+			wantUsage: []QuotaUsage{{Daily: 0, Total: 2}, {Daily: 0, Total: 2}},
+		},
+		{
+			name:        "room again after the release",
+			day:         day2,
+			wantAllowed: true,
+			wantUsage:   []QuotaUsage{{Daily: 1, Total: 3}, {Daily: 1, Total: 3}},
+		},
+	}
+
+	for _, step := range steps {
+		if step.release {
+			if err := repo.ReleaseQuota(owners, step.day); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		} else {
+			allowed, usage, err := repo.TakeQuota(owners, step.day)
+			if err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+
+			if allowed != step.wantAllowed {
+				t.Errorf("%s: allowed = %v, want %v", step.name, allowed, step.wantAllowed)
+			}
+
+			assertUsage(t, step.name+" returned", usage, step.wantUsage)
+		}
+
+		stored, err := repo.QuotaUsage(owners, step.day)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		assertUsage(t, step.name+" stored", stored, step.wantUsage)
+	}
+
+	daily, total := quotaUsageKeys(owners[1], day1)
+
+	if ttl := mr.TTL(daily); ttl != quotaDayTTL {
+		t.Errorf("daily counter ttl = %v, want %v", ttl, quotaDayTTL)
+	}
+
+	if ttl := mr.TTL(total); ttl != 0 {
+		t.Errorf("total counter ttl = %v, want none", ttl)
+	}
+}
+
+func TestQuotaLimits(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	repo := NewRedisRepository(conn)
+
+	daily := int64(10)
+	if err := repo.SetQuotaLimits("api_key", "k1", QuotaLimits{Daily: &daily}); err != nil {
+		t.Fatal(err)
+	}
+
+	limits, err := repo.QuotaLimits("api_key", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limits.Daily == nil || *limits.Daily != daily || limits.Total != nil {
+		t.Fatalf("limits = %+v, want daily %d and no total", limits, daily)
+	}
+
+	if err = repo.SetQuotaLimits("api_key", "k1", QuotaLimits{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if limits, err = repo.QuotaLimits("api_key", "k1"); err != nil || limits.Daily != nil || limits.Total != nil {
+		t.Fatalf("limits = %+v, %v, want none", limits, err)
+	}
+}
+
+func assertUsage(t *testing.T, name string, got, want []QuotaUsage) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: usage = %+v, want %+v", name, got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: usage of %d = %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
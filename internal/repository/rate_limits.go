@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const rateLimitPrefix = "ratelimit:"
+
+// gcraScript spends one request of a budget with the generic cell rate
+// algorithm. The key holds the theoretical arrival time in microseconds, a
+// request is allowed while it stays within the capacity of now. It returns
+// whether the request was allowed and how far the arrival time is ahead of
+// now.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+
+local tat = now
+local stored = redis.call('GET', KEYS[1])
+if stored then
+	tat = math.max(tonumber(stored), now)
+end
+
+local newTat = tat + interval
+if newTat - now > capacity then
+	return {0, tat - now}
+end
+
+redis.call('SET', KEYS[1], newTat, 'PX', math.ceil((newTat - now) / 1000))
+
+return {1, newTat - now}
+`)
+
+// TakeRateLimit spends one request of the key's budget, every request
+// pushes the arrival time interval further and capacity is how far ahead of
+// now it may get. Budgets are shared by all workspaces.
+func (r *RedisRepository) TakeRateLimit(
+	key string,
+	interval, capacity time.Duration,
+	now time.Time) (bool, time.Duration, error) {
+	values, err := gcraScript.Run(context.TODO(), r.conn, []string{rateLimitPrefix + key},
+		now.UnixMicro(), interval.Microseconds(), capacity.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return values[0] == 1, time.Duration(values[1]) * time.Microsecond, nil
+}
@@ -2,21 +2,110 @@ package repository
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v9"
 )
 
+const (
+	// DefaultWorkspace owns the links created before workspaces existed and
+	// keeps their unprefixed keys.
+	DefaultWorkspace = "default"
+
+	workspaceKeyPrefix = "ws:"
+	linkMetaPrefix     = "meta:"
+	linksIndex         = "links"
+	linksPageSize      = 1000
+	// Hash of every short code to its workspace, used on the shared domains.
+	linkWorkspaces = "links:workspace"
+
+	fieldCreatedAt    = "created_at"
+	fieldInterstitial = "interstitial"
+	fieldOwner        = "owner"
+	fieldQuarantine   = "quarantine"
+	fieldQuarantineAt = "quarantined_at"
+	fieldManageToken  = "manage_token"
+)
+
+// RedisRepository reads and writes the keyspace of one workspace, see
+// InWorkspace. API keys, workspaces and privacy salts are shared by all.
 type RedisRepository struct {
-	conn *redis.Client
+	conn      *redis.Client
+	workspace string
+	prefix    string
+}
+
+// Link is a short link together with the metadata stored beside it.
+type Link struct {
+	Workspace    string
+	Hash         string
+	URL          string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	Interstitial bool
+	// Subject of the principal that created the link, empty for anonymous
+	// links and links created before ownership was recorded.
+	Owner string
+	// Why the link was quarantined, empty while it redirects.
+	Quarantine    string
+	QuarantinedAt time.Time
+	// Why a moderator disabled the link, empty while it redirects.
+	Disabled   string
+	DisabledAt time.Time
+	DisabledBy string
+	// Abuse reports filed against the link since they were last resolved.
+	Reports int64
+	// Hash of the token that manages an anonymous link, empty once the link
+	// is claimed.
+	ManageToken string
 }
 
+// NewRedisRepository returns the repository of the default workspace.
 func NewRedisRepository(conn *redis.Client) *RedisRepository {
-	return &RedisRepository{conn: conn}
+	return &RedisRepository{conn: conn, workspace: DefaultWorkspace}
 }
 
-func (r *RedisRepository) Store(shortUrl, url string) error {
+// InWorkspace returns a repository whose links and stats live under the
+// keys of the workspace.
+func (r *RedisRepository) InWorkspace(workspace string) *RedisRepository {
+	if workspace == "" || workspace == DefaultWorkspace {
+		return &RedisRepository{conn: r.conn, workspace: DefaultWorkspace}
+	}
+
+	return &RedisRepository{
+		conn:      r.conn,
+		workspace: workspace,
+		prefix:    workspaceKeyPrefix + workspace + ":",
+	}
+}
+
+func (r *RedisRepository) Workspace() string {
+	return r.workspace
+}
 
-	err := r.conn.TxPipeline().Set(context.TODO(), shortUrl, url, 0).Err()
+func (r *RedisRepository) Store(link Link) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.Set(context.TODO(), r.prefix+link.Hash, link.URL, 0)
+		pipe.HSet(context.TODO(), r.prefix+linkMetaPrefix+link.Hash,
+			fieldCreatedAt, link.CreatedAt.Unix(),
+			fieldInterstitial, link.Interstitial,
+			fieldOwner, link.Owner,
+		)
+
+		if link.ManageToken != "" {
+			pipe.HSet(context.TODO(), r.prefix+linkMetaPrefix+link.Hash, fieldManageToken, link.ManageToken)
+		}
+
+		pipe.ZAdd(context.TODO(), r.prefix+linksIndex, redis.Z{
+			Score:  float64(link.CreatedAt.Unix()),
+			Member: link.Hash,
+		})
+		pipe.HSet(context.TODO(), linkWorkspaces, link.Hash, r.workspace)
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
@@ -24,6 +113,250 @@ func (r *RedisRepository) Store(shortUrl, url string) error {
 	return nil
 }
 
+// UpdateLink changes the destination and the interstitial flag of a stored
+// link, its expiry is kept. Links updated without a quarantine are released.
+func (r *RedisRepository) UpdateLink(link Link) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.SetArgs(context.TODO(), r.prefix+link.Hash, link.URL, redis.SetArgs{KeepTTL: true, Mode: "XX"})
+		pipe.HSet(context.TODO(), r.prefix+linkMetaPrefix+link.Hash, fieldInterstitial, link.Interstitial)
+
+		if link.Quarantine == "" {
+			pipe.HDel(context.TODO(), r.prefix+linkMetaPrefix+link.Hash, fieldQuarantine, fieldQuarantineAt)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// QuarantineLink stops the link from redirecting, the reason is shown to
+// its owners.
+func (r *RedisRepository) QuarantineLink(code, reason string, at time.Time) error {
+	return r.conn.HSet(context.TODO(), r.prefix+linkMetaPrefix+code,
+		fieldQuarantine, reason,
+		fieldQuarantineAt, at.Unix(),
+	).Err()
+}
+
+func (r *RedisRepository) ReleaseLink(code string) error {
+	return r.conn.HDel(context.TODO(), r.prefix+linkMetaPrefix+code, fieldQuarantine, fieldQuarantineAt).Err()
+}
+
+// ClaimLink makes the owner own the link, its manage token stops working.
+func (r *RedisRepository) ClaimLink(code, owner string) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.TODO(), r.prefix+linkMetaPrefix+code, fieldOwner, owner)
+		pipe.HDel(context.TODO(), r.prefix+linkMetaPrefix+code, fieldManageToken)
+
+		return nil
+	})
+
+	return err
+}
+
+// DeleteLink removes the link and its shares and takes it off the report
+// queue. The code stays registered to the workspace so it is never handed
+// out again for another destination, its reports and moderation history are
+// kept.
+func (r *RedisRepository) DeleteLink(code string) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.Del(context.TODO(), r.prefix+code, r.prefix+linkMetaPrefix+code, r.prefix+linkSharePrefix+code)
+		pipe.ZRem(context.TODO(), r.prefix+linksIndex, code)
+		pipe.ZRem(context.TODO(), abuseQueue, code)
+
+		return nil
+	})
+
+	return err
+}
+
 func (r *RedisRepository) Retrieve(shortUrl string) string {
-	return r.conn.TxPipeline().Get(context.TODO(), shortUrl).Val()
+	return r.conn.Get(context.TODO(), r.prefix+shortUrl).Val()
+}
+
+// CodeExists reports whether any workspace uses the short code, or it is
+// reserved as a honeypot.
+func (r *RedisRepository) CodeExists(code string) (bool, error) {
+	var (
+		indexed  *redis.BoolCmd
+		legacy   *redis.IntCmd
+		honeypot *redis.BoolCmd
+	)
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		indexed = pipe.HExists(context.TODO(), linkWorkspaces, code)
+		legacy = pipe.Exists(context.TODO(), code)
+		honeypot = pipe.SIsMember(context.TODO(), honeypotCodes, code)
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return indexed.Val() || legacy.Val() > 0 || honeypot.Val(), nil
+}
+
+// LinkWorkspace returns the workspace of the short code, links stored before
+// workspaces existed belong to the default one.
+func (r *RedisRepository) LinkWorkspace(code string) (string, error) {
+	workspace, err := r.conn.HGet(context.TODO(), linkWorkspaces, code).Result()
+	if err == redis.Nil {
+		return DefaultWorkspace, nil
+	}
+
+	return workspace, err
+}
+
+// Links returns the newest links of the workspace. Links stored before
+// workspaces existed are only listed once IndexLegacyLinks has run.
+func (r *RedisRepository) Links(offset, limit int64) ([]Link, error) {
+	codes, err := r.conn.ZRevRange(context.TODO(), r.prefix+linksIndex, offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(codes))
+
+	for _, code := range codes {
+		link, errLink := r.RetrieveLink(code)
+		if errLink != nil {
+			return nil, errLink
+		}
+
+		if link.URL != "" {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+// LinkCount returns how many links the workspace index holds, expired ones
+// included.
+func (r *RedisRepository) LinkCount() (int64, error) {
+	return r.conn.ZCard(context.TODO(), r.prefix+linksIndex).Result()
+}
+
+// IndexLegacyLinks adds the links stored before workspaces existed to the
+// index of the default workspace, so they are listed and screened like the
+// others. They are the only unprefixed string keys. Their creation time is
+// unknown, so they are indexed as the oldest links. It returns how many
+// links were added, running it again adds none.
+func (r *RedisRepository) IndexLegacyLinks() (int64, error) {
+	if r.workspace != DefaultWorkspace {
+		return 0, nil
+	}
+
+	var (
+		added int64
+		batch []redis.Z
+	)
+
+	index := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		n, err := r.conn.ZAddNX(context.TODO(), linksIndex, batch...).Result()
+		added += n
+		batch = batch[:0]
+
+		return err
+	}
+
+	iter := r.conn.ScanType(context.TODO(), 0, "*", scanCount, "string").Iterator()
+	for iter.Next(context.TODO()) {
+		if strings.Contains(iter.Val(), ":") {
+			continue
+		}
+
+		batch = append(batch, redis.Z{Score: 0, Member: iter.Val()})
+
+		if len(batch) == scanCount {
+			if err := index(); err != nil {
+				return added, err
+			}
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return added, err
+	}
+
+	return added, index()
+}
+
+// LinksOwnedBy returns every link of the workspace created by the owner,
+// expired ones included.
+func (r *RedisRepository) LinksOwnedBy(owner string) ([]Link, error) {
+	var owned []Link
+
+	for offset := int64(0); ; offset += linksPageSize {
+		codes, err := r.conn.ZRange(context.TODO(), r.prefix+linksIndex, offset, offset+linksPageSize-1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, code := range codes {
+			link, errLink := r.RetrieveLink(code)
+			if errLink != nil {
+				return nil, errLink
+			}
+
+			if link.Owner == owner {
+				owned = append(owned, link)
+			}
+		}
+
+		if len(codes) < linksPageSize {
+			return owned, nil
+		}
+	}
+}
+
+// RetrieveLink returns the link and its metadata. Links stored before
+// metadata was introduced come back with zero values for it.
+func (r *RedisRepository) RetrieveLink(shortUrl string) (Link, error) {
+	var (
+		url  *redis.StringCmd
+		meta *redis.MapStringStringCmd
+		ttl  *redis.DurationCmd
+	)
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		url = pipe.Get(context.TODO(), r.prefix+shortUrl)
+		meta = pipe.HGetAll(context.TODO(), r.prefix+linkMetaPrefix+shortUrl)
+		ttl = pipe.TTL(context.TODO(), r.prefix+shortUrl)
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return Link{}, err
+	}
+
+	link := Link{Workspace: r.workspace, Hash: shortUrl, URL: url.Val()}
+
+	fields := meta.Val()
+	if createdAt, errParse := strconv.ParseInt(fields[fieldCreatedAt], 10, 64); errParse == nil {
+		link.CreatedAt = time.Unix(createdAt, 0).UTC()
+	}
+
+	link.Interstitial = fields[fieldInterstitial] == "1"
+	link.Owner = fields[fieldOwner]
+	link.Quarantine = fields[fieldQuarantine]
+	link.QuarantinedAt = parseUnix(fields[fieldQuarantineAt])
+	link.Disabled = fields[fieldDisabled]
+	link.DisabledAt = parseUnix(fields[fieldDisabledAt])
+	link.DisabledBy = fields[fieldDisabledBy]
+	link.Reports, _ = strconv.ParseInt(fields[fieldReports], 10, 64)
+	link.ManageToken = fields[fieldManageToken]
+
+	// TTL reports negative values for keys without an expiry.
+	if expiresIn := ttl.Val(); expiresIn > 0 {
+		link.ExpiresAt = time.Now().Add(expiresIn).UTC().Truncate(time.Second)
+	}
+
+	return link, nil
 }
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	scanPrefix      = "scan:"
+	scanBlockPrefix = "scan:block:"
+	scanSlowPrefix  = "scan:slow:"
+	// Codes never handed out to links, requesting one gives a scanner away.
+	honeypotCodes = "scan:honeypots"
+
+	fieldHits   = "hits"
+	fieldMisses = "misses"
+)
+
+// ScanVerdict is what observing a request decided about its client.
+type ScanVerdict int
+
+const (
+	ScanNone ScanVerdict = iota
+	// The client started being slowed down.
+	ScanSlowed
+	// The client started being blocked.
+	ScanBlocked
+)
+
+// ScanThresholds judge a client by its share of misses over a sliding
+// window, once it made MinRequests requests in it.
+type ScanThresholds struct {
+	Window        time.Duration
+	MinRequests   int
+	SlowRatio     float64
+	BlockRatio    float64
+	BlockDuration time.Duration
+}
+
+// observeScanScript counts a hit or a miss in the bucket of the current
+// window and judges the client over the sliding window, the previous bucket
+// weighted by how much of it the window still covers. KEYS are the current
+// and previous buckets and the block and slow flags, ARGV the counted field,
+// the weight of the previous bucket, the window and block duration in
+// milliseconds, the minimum requests and the slow and block ratios. It
+// returns 2 when the client was just blocked, 1 when it was just slowed.
+var observeScanScript = redis.NewScript(`
+local window = tonumber(ARGV[3])
+
+redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+redis.call('PEXPIRE', KEYS[1], 2 * window)
+
+local current = redis.call('HMGET', KEYS[1], 'hits', 'misses')
+local previous = redis.call('HMGET', KEYS[2], 'hits', 'misses')
+local weight = tonumber(ARGV[2])
+
+local hits = (tonumber(current[1]) or 0) + weight * (tonumber(previous[1]) or 0)
+local misses = (tonumber(current[2]) or 0) + weight * (tonumber(previous[2]) or 0)
+local total = hits + misses
+
+if total < tonumber(ARGV[5]) then
+	return 0
+end
+
+local ratio = misses / total
+
+if ratio >= tonumber(ARGV[7]) then
+	redis.call('SET', KEYS[3], 'miss_ratio', 'PX', ARGV[4])
+	return 2
+end
+
+if ratio >= tonumber(ARGV[6]) then
+	if redis.call('SET', KEYS[4], 1, 'PX', window, 'NX') then
+		return 1
+	end
+	redis.call('PEXPIRE', KEYS[4], window)
+end
+
+return 0
+`)
+
+// ObserveScan counts a request of the client for a code that was found or
+// missed and judges whether the client is scanning.
+func (r *RedisRepository) ObserveScan(
+	client string,
+	miss bool,
+	thresholds ScanThresholds,
+	now time.Time) (ScanVerdict, error) {
+	window := thresholds.Window.Milliseconds()
+	bucket := now.UnixMilli() / window
+	weight := 1 - float64(now.UnixMilli()%window)/float64(window)
+
+	field := fieldHits
+	if miss {
+		field = fieldMisses
+	}
+
+	keys := []string{
+		scanPrefix + client + ":" + strconv.FormatInt(bucket, 10),
+		scanPrefix + client + ":" + strconv.FormatInt(bucket-1, 10),
+		scanBlockPrefix + client,
+		scanSlowPrefix + client,
+	}
+
+	verdict, err := observeScanScript.Run(context.TODO(), r.conn, keys,
+		field, strconv.FormatFloat(weight, 'f', 4, 64), window, thresholds.BlockDuration.Milliseconds(),
+		thresholds.MinRequests,
+		strconv.FormatFloat(thresholds.SlowRatio, 'f', 4, 64),
+		strconv.FormatFloat(thresholds.BlockRatio, 'f', 4, 64),
+	).Int()
+	if err != nil {
+		return ScanNone, err
+	}
+
+	return ScanVerdict(verdict), nil
+}
+
+// ScanState returns how much longer the client is blocked and whether it is
+// slowed down.
+func (r *RedisRepository) ScanState(client string) (time.Duration, bool, error) {
+	var (
+		blocked *redis.DurationCmd
+		slowed  *redis.IntCmd
+	)
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		blocked = pipe.PTTL(context.TODO(), scanBlockPrefix+client)
+		slowed = pipe.Exists(context.TODO(), scanSlowPrefix+client)
+
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	// PTTL reports negative values for missing keys.
+	blockedFor := blocked.Val()
+	if blockedFor < 0 {
+		blockedFor = 0
+	}
+
+	return blockedFor, slowed.Val() > 0, nil
+}
+
+// BlockScanner refuses the client for the duration, the reason is kept for
+// operators.
+func (r *RedisRepository) BlockScanner(client, reason string, duration time.Duration) error {
+	return r.conn.Set(context.TODO(), scanBlockPrefix+client, reason, duration).Err()
+}
+
+func (r *RedisRepository) HoneypotCodes() ([]string, error) {
+	return r.conn.SMembers(context.TODO(), honeypotCodes).Result()
+}
+
+// AddHoneypotCodes reserves the codes as honeypots, CodeExists reports them
+// so they are never handed out to links.
+func (r *RedisRepository) AddHoneypotCodes(codes ...string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(codes))
+	for i, code := range codes {
+		members[i] = code
+	}
+
+	return r.conn.SAdd(context.TODO(), honeypotCodes, members...).Err()
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+)
+
+const secretPrefix = "secret:"
+
+// Secret returns the secret stored under the name, storing the candidate if
+// no instance has done so yet. Secrets never expire.
+func (r *RedisRepository) Secret(name, candidate string) (string, error) {
+	key := secretPrefix + name
+
+	if err := r.conn.SetNX(context.TODO(), key, candidate, 0).Err(); err != nil {
+		return "", err
+	}
+
+	return r.conn.Get(context.TODO(), key).Result()
+}
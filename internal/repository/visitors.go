@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// AddVisitors records the visitors of the clicks in the hourly and daily
+// HyperLogLogs of their links. Each one expires the retention of its
+// interval after its bucket starts, no retention keeps it.
+func (r *RedisRepository) AddVisitors(events []ClickEvent, retention map[StatsInterval]time.Duration) error {
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		expires := map[string]time.Time{}
+
+		for _, event := range events {
+			if event.VisitorID == "" || event.Bot {
+				continue
+			}
+
+			for _, interval := range []StatsInterval{IntervalHour, IntervalDay} {
+				start := BucketStart(event.Timestamp, interval)
+				key := r.prefix + VisitorsKey(event.Code, interval, start)
+				pipe.PFAdd(context.TODO(), key, event.VisitorID)
+
+				if ttl := retention[interval]; ttl > 0 {
+					expires[key] = start.Add(ttl)
+				}
+			}
+		}
+
+		for key, at := range expires {
+			pipe.ExpireAt(context.TODO(), key, at)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// CountVisitors estimates the unique visitors of every bucket and of all of
+// them together.
+func (r *RedisRepository) CountVisitors(code string, interval StatsInterval, starts []time.Time) ([]int64, int64, error) {
+	var (
+		counts = make([]*redis.IntCmd, len(starts))
+		union  *redis.IntCmd
+	)
+
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = r.prefix + VisitorsKey(code, interval, start)
+	}
+
+	_, err := r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			counts[i] = pipe.PFCount(context.TODO(), key)
+		}
+
+		union = pipe.PFCount(context.TODO(), keys...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	perBucket := make([]int64, len(starts))
+	for i, count := range counts {
+		perBucket[i] = count.Val()
+	}
+
+	return perBucket, union.Val(), nil
+}
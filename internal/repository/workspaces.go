@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	workspacePrefix  = "workspace:"
+	workspacesSet    = "workspaces"
+	workspaceDomains = "workspace:domains"
+
+	fieldDomains = "domains"
+)
+
+// Workspace isolates the links, keys and stats of one team.
+type Workspace struct {
+	ID        string
+	Name      string
+	Domains   []string
+	CreatedAt time.Time
+}
+
+func (r *RedisRepository) StoreWorkspace(workspace Workspace) error {
+	_, err := r.conn.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.TODO(), workspacePrefix+workspace.ID,
+			fieldName, workspace.Name,
+			fieldDomains, strings.Join(workspace.Domains, ","),
+			fieldCreatedAt, workspace.CreatedAt.Unix(),
+		)
+		pipe.SAdd(context.TODO(), workspacesSet, workspace.ID)
+
+		return nil
+	})
+
+	return err
+}
+
+// WorkspaceByID returns the workspace, or a zero Workspace when there is
+// none.
+func (r *RedisRepository) WorkspaceByID(id string) (Workspace, error) {
+	fields, err := r.conn.HGetAll(context.TODO(), workspacePrefix+id).Result()
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	return parseWorkspace(id, fields), nil
+}
+
+func (r *RedisRepository) Workspaces() ([]Workspace, error) {
+	ids, err := r.conn.SMembers(context.TODO(), workspacesSet).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+
+	_, err = r.conn.Pipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(context.TODO(), workspacePrefix+id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := make([]Workspace, 0, len(ids))
+
+	for i, id := range ids {
+		if workspace := parseWorkspace(id, cmds[i].Val()); workspace.ID != "" {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	return workspaces, nil
+}
+
+// ClaimDomain makes the workspace the owner of the short domain. It returns
+// false when another workspace owns it already.
+func (r *RedisRepository) ClaimDomain(domain, workspace string) (bool, error) {
+	if err := r.conn.HSetNX(context.TODO(), workspaceDomains, domain, workspace).Err(); err != nil {
+		return false, err
+	}
+
+	owner, err := r.conn.HGet(context.TODO(), workspaceDomains, domain).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return owner == workspace, nil
+}
+
+// WorkspaceDomains maps every dedicated short domain to its workspace.
+func (r *RedisRepository) WorkspaceDomains() (map[string]string, error) {
+	return r.conn.HGetAll(context.TODO(), workspaceDomains).Result()
+}
+
+func parseWorkspace(id string, fields map[string]string) Workspace {
+	if len(fields) == 0 {
+		return Workspace{}
+	}
+
+	workspace := Workspace{
+		ID:        id,
+		Name:      fields[fieldName],
+		CreatedAt: parseUnix(fields[fieldCreatedAt]),
+	}
+
+	if fields[fieldDomains] != "" {
+		workspace.Domains = strings.Split(fields[fieldDomains], ",")
+	}
+
+	return workspace
+}
@@ -0,0 +1,266 @@
+package scandetect
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultWindow        = time.Minute
+	DefaultBlockDuration = 15 * time.Minute
+)
+
+var ErrScanConfig = errors.New("invalid scan detection config")
+
+type Action string
+
+const (
+	// ActionAllow lets the request through untouched.
+	ActionAllow Action = "allow"
+	// ActionSlow delays the request by SlowDelay.
+	ActionSlow Action = "slow"
+	// ActionBlock refuses the request.
+	ActionBlock Action = "block"
+	// ActionHoneypot marks a client caught requesting a honeypot code, it is
+	// blocked from then on.
+	ActionHoneypot Action = "honeypot"
+)
+
+type Config struct {
+	Enabled bool
+	// Clients are judged by their share of missed codes over this sliding
+	// window, once they made MinRequests requests in it.
+	Window      time.Duration
+	MinRequests int
+	// Share of misses from which a client is slowed down, and blocked. A
+	// client is slowed down before it is blocked.
+	SlowRatio  float64
+	BlockRatio float64
+	// Added to every request of a slowed down client.
+	SlowDelay time.Duration
+	// How long scanners stay blocked.
+	BlockDuration time.Duration
+	// Codes reserved as honeypots. Random ones are added once and kept until
+	// there are HoneypotCount in all.
+	HoneypotCodes []string
+	HoneypotCount int
+}
+
+// Decision is what to do with a request of a client.
+type Decision struct {
+	Action Action
+	// How long a blocked client stays blocked.
+	RetryAfter time.Duration
+}
+
+// Detector spots clients sweeping short codes. Their hits and misses are
+// counted in Redis, shared by all instances, and clients missing too often
+// are slowed down, then blocked. Clients requesting a honeypot code are
+// blocked right away. While Redis is unreachable every client is let
+// through.
+type Detector struct {
+	cfg       Config
+	repo      *repository.RedisRepository
+	logger    *logger.Logger
+	honeypots atomic.Value
+
+	mu       sync.Mutex
+	degraded bool
+}
+
+// NewDetector defaults an unset window and block duration. Both are counted
+// in milliseconds in Redis, shorter ones are refused, and so are ratios
+// outside (0, 1].
+func NewDetector(cfg Config, redisRepo *repository.RedisRepository, logger *logger.Logger) (*Detector, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+
+	if cfg.BlockDuration <= 0 {
+		cfg.BlockDuration = DefaultBlockDuration
+	}
+
+	d := &Detector{
+		cfg:    cfg,
+		repo:   redisRepo,
+		logger: logger,
+	}
+	d.honeypots.Store(map[string]struct{}{})
+
+	if !cfg.Enabled {
+		return d, nil
+	}
+
+	if cfg.Window < time.Millisecond || cfg.BlockDuration < time.Millisecond {
+		return nil, fmt.Errorf("%w: window and block duration must be at least a millisecond", ErrScanConfig)
+	}
+
+	for _, ratio := range []float64{cfg.SlowRatio, cfg.BlockRatio} {
+		if ratio <= 0 || ratio > 1 {
+			return nil, fmt.Errorf("%w: ratio %v is not in (0, 1]", ErrScanConfig, ratio)
+		}
+	}
+
+	if cfg.SlowRatio > cfg.BlockRatio {
+		return nil, fmt.Errorf("%w: slow ratio %v above block ratio %v", ErrScanConfig, cfg.SlowRatio, cfg.BlockRatio)
+	}
+
+	return d, nil
+}
+
+// Seed reserves the configured honeypot codes and generates random ones
+// until there are HoneypotCount in all, then loads them. Configured codes
+// already used by links are skipped.
+func (d *Detector) Seed(generate func() string) error {
+	if !d.cfg.Enabled {
+		return nil
+	}
+
+	existing, err := d.repo.HoneypotCodes()
+	if err != nil {
+		return err
+	}
+
+	honeypots := make(map[string]struct{}, len(existing))
+	for _, code := range existing {
+		honeypots[code] = struct{}{}
+	}
+
+	var added []string
+
+	for _, code := range d.cfg.HoneypotCodes {
+		if _, ok := honeypots[code]; ok {
+			continue
+		}
+
+		used, errExists := d.repo.CodeExists(code)
+		if errExists != nil {
+			return errExists
+		}
+
+		if used {
+			d.logger.LogInfo("honeypot code is used by a link, skipping it", code)
+
+			continue
+		}
+
+		honeypots[code] = struct{}{}
+		added = append(added, code)
+	}
+
+	for len(honeypots) < d.cfg.HoneypotCount {
+		code := generate()
+		if _, ok := honeypots[code]; ok {
+			continue
+		}
+
+		honeypots[code] = struct{}{}
+		added = append(added, code)
+	}
+
+	if err = d.repo.AddHoneypotCodes(added...); err != nil {
+		return err
+	}
+
+	d.honeypots.Store(honeypots)
+	d.logger.LogInfo("honeypot codes seeded", len(honeypots))
+
+	return nil
+}
+
+// Check decides what to do with a request of the client before it is
+// served.
+func (d *Detector) Check(client string) Decision {
+	if !d.cfg.Enabled {
+		return Decision{Action: ActionAllow}
+	}
+
+	blockedFor, slowed, err := d.repo.ScanState(client)
+	d.setDegraded(err)
+
+	switch {
+	case err != nil:
+		return Decision{Action: ActionAllow}
+	case blockedFor > 0:
+		return Decision{Action: ActionBlock, RetryAfter: blockedFor}
+	case slowed:
+		return Decision{Action: ActionSlow}
+	default:
+		return Decision{Action: ActionAllow}
+	}
+}
+
+// Observe counts a served request of the client for the code, miss tells
+// whether the code was not found. It returns the action taken against the
+// client when it was just caught, ActionAllow otherwise.
+func (d *Detector) Observe(client, code string, miss bool) Action {
+	if !d.cfg.Enabled {
+		return ActionAllow
+	}
+
+	if d.IsHoneypot(code) {
+		err := d.repo.BlockScanner(client, string(ActionHoneypot), d.cfg.BlockDuration)
+		d.setDegraded(err)
+
+		d.logger.LogInfo("honeypot code requested", client, code)
+
+		return ActionHoneypot
+	}
+
+	verdict, err := d.repo.ObserveScan(client, miss, repository.ScanThresholds{
+		Window:        d.cfg.Window,
+		MinRequests:   d.cfg.MinRequests,
+		SlowRatio:     d.cfg.SlowRatio,
+		BlockRatio:    d.cfg.BlockRatio,
+		BlockDuration: d.cfg.BlockDuration,
+	}, time.Now())
+	d.setDegraded(err)
+
+	switch verdict {
+	case repository.ScanBlocked:
+		d.logger.LogInfo("scanner blocked", client)
+
+		return ActionBlock
+	case repository.ScanSlowed:
+		d.logger.LogInfo("scanner slowed down", client)
+
+		return ActionSlow
+	default:
+		return ActionAllow
+	}
+}
+
+func (d *Detector) Enabled() bool {
+	return d.cfg.Enabled
+}
+
+func (d *Detector) IsHoneypot(code string) bool {
+	_, ok := d.honeypots.Load().(map[string]struct{})[code]
+
+	return ok
+}
+
+func (d *Detector) SlowDelay() time.Duration {
+	return d.cfg.SlowDelay
+}
+
+// setDegraded logs when the detector stops and starts judging clients,
+// rather than on every request.
+func (d *Detector) setDegraded(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case err != nil && !d.degraded:
+		d.degraded = true
+		d.logger.LogError("scan detection store unavailable, letting every client through", err)
+	case err == nil && d.degraded:
+		d.degraded = false
+		d.logger.LogInfo("scan detection store available again")
+	}
+}
@@ -0,0 +1,184 @@
+package scandetect
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// testConfig slows clients down from half of their requests missing and
+// blocks them from 80%, once they made four requests. The window is long
+// enough for a test never to straddle two of them in practice.
+func testConfig() Config {
+	return Config{
+		Enabled:       true,
+		Window:        time.Hour,
+		MinRequests:   4,
+		SlowRatio:     0.5,
+		BlockRatio:    0.8,
+		SlowDelay:     time.Second,
+		BlockDuration: 15 * time.Minute,
+	}
+}
+
+func newTestDetector(t *testing.T, cfg Config, redisRepo *repository.RedisRepository) *Detector {
+	t.Helper()
+
+	detector, err := NewDetector(cfg, redisRepo, logger.NewLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return detector
+}
+
+func TestNewDetector(t *testing.T) {
+	cfg := testConfig()
+	cfg.Window = 0
+	cfg.BlockDuration = -time.Minute
+
+	detector := newTestDetector(t, cfg, nil)
+	if detector.cfg.Window != DefaultWindow || detector.cfg.BlockDuration != DefaultBlockDuration {
+		t.Errorf("window = %v, block duration = %v, want the defaults", detector.cfg.Window, detector.cfg.BlockDuration)
+	}
+
+	// A disabled detector takes any config.
+	newTestDetector(t, Config{SlowRatio: 2}, nil)
+
+	tests := map[string]func(cfg *Config){
+		"window under a millisecond":         func(cfg *Config) { cfg.Window = time.Microsecond },
+		"block duration under a millisecond": func(cfg *Config) { cfg.BlockDuration = time.Microsecond },
+		"no slow ratio":                      func(cfg *Config) { cfg.SlowRatio = 0 },
+		"negative slow ratio":                func(cfg *Config) { cfg.SlowRatio = -0.5 },
+		"block ratio above one":              func(cfg *Config) { cfg.BlockRatio = 1.5 },
+		"slow ratio above block ratio":       func(cfg *Config) { cfg.SlowRatio, cfg.BlockRatio = 0.9, 0.6 },
+	}
+
+	for name, change := range tests {
+		cfg := testConfig()
+		change(&cfg)
+
+		if _, err := NewDetector(cfg, nil, nil); !errors.Is(err, ErrScanConfig) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrScanConfig)
+		}
+	}
+}
+
+func TestDetectorObserve(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+	detector := newTestDetector(t, testConfig(), redisRepo)
+
+	const client = "203.0.113.7"
+
+	steps := []struct {
+		name       string
+		miss       bool
+		wantAction Action
+		wantCheck  Action
+	}{
+		{name: "hit", wantAction: ActionAllow, wantCheck: ActionAllow},
+		{name: "second hit", wantAction: ActionAllow, wantCheck: ActionAllow},
+		{name: "miss under the minimum requests", miss: true, wantAction: ActionAllow, wantCheck: ActionAllow},
+		{name: "half missed", miss: true, wantAction: ActionSlow, wantCheck: ActionSlow},
+		{name: "still slowed", miss: true, wantAction: ActionAllow, wantCheck: ActionSlow},
+		{name: "two thirds missed", miss: true, wantAction: ActionAllow, wantCheck: ActionSlow},
+		{name: "five out of seven missed", miss: true, wantAction: ActionAllow, wantCheck: ActionSlow},
+		{name: "three quarters missed", miss: true, wantAction: ActionAllow, wantCheck: ActionSlow},
+		{name: "just under the block ratio", miss: true, wantAction: ActionAllow, wantCheck: ActionSlow},
+		{name: "80% missed", miss: true, wantAction: ActionBlock, wantCheck: ActionBlock},
+	}
+
+	for _, step := range steps {
+		if action := detector.Observe(client, "abc123", step.miss); action != step.wantAction {
+			t.Fatalf("%s: action = %s, want %s", step.name, action, step.wantAction)
+		}
+
+		if decision := detector.Check(client); decision.Action != step.wantCheck {
+			t.Fatalf("%s: check = %s, want %s", step.name, decision.Action, step.wantCheck)
+		}
+	}
+
+	if decision := detector.Check(client); decision.RetryAfter <= 14*time.Minute || decision.RetryAfter > 15*time.Minute {
+		t.Errorf("retry after = %v, want the block duration", decision.RetryAfter)
+	}
+
+	if decision := detector.Check("198.51.100.1"); decision.Action != ActionAllow {
+		t.Errorf("another client got %s", decision.Action)
+	}
+}
+
+func TestDetectorHoneypots(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+
+	if err := redisRepo.Store(repository.Link{Hash: "used01", URL: "https://example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	cfg.HoneypotCodes = []string{"trap01", "used01"}
+	cfg.HoneypotCount = 3
+
+	generated := []string{"trap01", "rand01", "rand01", "rand02"}
+	generate := func() string {
+		code := generated[0]
+		generated = generated[1:]
+
+		return code
+	}
+
+	detector := newTestDetector(t, cfg, redisRepo)
+	if err := detector.Seed(generate); err != nil {
+		t.Fatal(err)
+	}
+
+	for code, want := range map[string]bool{"trap01": true, "rand01": true, "rand02": true, "used01": false} {
+		if got := detector.IsHoneypot(code); got != want {
+			t.Errorf("IsHoneypot(%s) = %v, want %v", code, got, want)
+		}
+	}
+
+	// Another instance loads the honeypots seeded by the first one.
+	other := newTestDetector(t, cfg, redisRepo)
+	if err := other.Seed(func() string { t.Fatal("generated a code again"); return "" }); err != nil {
+		t.Fatal(err)
+	}
+
+	if !other.IsHoneypot("rand02") {
+		t.Error("seeded honeypot not loaded")
+	}
+
+	if action := detector.Observe("203.0.113.7", "rand01", true); action != ActionHoneypot {
+		t.Fatalf("action = %s, want %s", action, ActionHoneypot)
+	}
+
+	if decision := other.Check("203.0.113.7"); decision.Action != ActionBlock {
+		t.Errorf("check = %s, want %s", decision.Action, ActionBlock)
+	}
+}
+
+func TestDetectorLetsClientsThroughWhenRedisIsDown(t *testing.T) {
+	conn, mr := redistest.NewClient(t)
+	redisRepo := repository.NewRedisRepository(conn)
+	detector := newTestDetector(t, testConfig(), redisRepo)
+
+	if err := redisRepo.BlockScanner("203.0.113.7", string(ActionHoneypot), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	mr.Close()
+
+	if decision := detector.Check("203.0.113.7"); decision.Action != ActionAllow {
+		t.Errorf("check = %s, want %s", decision.Action, ActionAllow)
+	}
+
+	if action := detector.Observe("203.0.113.7", "abc123", true); action != ActionAllow {
+		t.Errorf("action = %s, want %s", action, ActionAllow)
+	}
+}
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+type Role string
+
+const (
+	// RoleViewer can read the links of the workspace and their stats.
+	RoleViewer Role = "viewer"
+	// RoleEditor can also create links, and change the links it owns or
+	// that were shared with it as an editor.
+	RoleEditor Role = "editor"
+	// RoleOwner can do everything to every link and manages the members.
+	RoleOwner Role = "owner"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+type Action string
+
+const (
+	ActionViewLink    Action = "view link"
+	ActionEditLink    Action = "edit link"
+	ActionDeleteLink  Action = "delete link"
+	ActionShareLink   Action = "share link"
+	ActionListLinks   Action = "list links"
+	ActionCreateLink  Action = "create link"
+	ActionManageTeam  Action = "manage members"
+	ActionModerate    Action = "moderate links"
+	ActionViewAudit   Action = "view audit log"
+	ActionManageQuota Action = "manage quotas"
+	// ActionStreamEvents follows the live clicks of a link, or of every link
+	// in the workspace.
+	ActionStreamEvents Action = "stream events"
+	// ActionEraseAnalytics deletes what was recorded about the visitors of a
+	// link.
+	ActionEraseAnalytics Action = "erase analytics"
+)
+
+// requiredRoles is the role an action needs, on the link for link actions
+// and in the workspace for the others.
+var requiredRoles = map[Action]Role{
+	ActionViewLink:       RoleViewer,
+	ActionEditLink:       RoleEditor,
+	ActionDeleteLink:     RoleOwner,
+	ActionShareLink:      RoleOwner,
+	ActionListLinks:      RoleViewer,
+	ActionCreateLink:     RoleEditor,
+	ActionManageTeam:     RoleOwner,
+	ActionModerate:       RoleOwner,
+	ActionViewAudit:      RoleOwner,
+	ActionManageQuota:    RoleOwner,
+	ActionStreamEvents:   RoleViewer,
+	ActionEraseAnalytics: RoleEditor,
+}
+
+// PermissionError tells which role an action needed, it matches
+// ErrForbidden.
+type PermissionError struct {
+	Action   Action `json:"action"`
+	Role     Role   `json:"role"`
+	Required Role   `json:"required"`
+}
+
+func (e *PermissionError) Error() string {
+	role := e.Role
+	if role == "" {
+		role = "no role"
+	}
+
+	return fmt.Sprintf("%s: %s needs %s, has %s", ErrForbidden, e.Action, e.Required, role)
+}
+
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+type Member struct {
+	User string `json:"user"`
+	Role Role   `json:"role"`
+}
+
+type SetMemberRequest struct {
+	Role Role `json:"role"`
+}
+
+// AccessService enforces the roles of the principals inside their workspace.
+//
+// API keys are workspace credentials and act as owners, their scopes already
+// bound what they can reach. SSO users take the role of their membership
+// when they have one, otherwise one derived from their scopes: admin makes
+// an owner, create or manage an editor and read a viewer. On a link an
+// editor also gets the owner role when it created the link, and anyone the
+// role the link was shared with them with. Manage tokens own their link and
+// nothing else. Service tokens act as owners of the default workspace, but
+// only for the actions they were set for.
+type AccessService struct {
+	repo   *repository.RedisRepository
+	audit  *AuditLog
+	logger *logger.Logger
+}
+
+func NewAccessService(redisRepo *repository.RedisRepository, audit *AuditLog, logger *logger.Logger) *AccessService {
+	return &AccessService{
+		repo:   redisRepo,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// WorkspaceRole returns the role of the principal in its workspace, empty
+// when it has none.
+func (svc *AccessService) WorkspaceRole(principal Principal) (Role, error) {
+	switch principal.Kind {
+	case PrincipalAPIKey, PrincipalServiceToken:
+		return RoleOwner, nil
+	case PrincipalUser:
+		role, err := svc.repo.InWorkspace(principal.Workspace).MemberRole(principal.ID)
+		if err != nil {
+			return "", err
+		}
+
+		if role != "" {
+			return Role(role), nil
+		}
+
+		return scopesRole(principal), nil
+	default:
+		return "", nil
+	}
+}
+
+// Authorize checks an action on the workspace of the principal.
+func (svc *AccessService) Authorize(principal Principal, action Action) error {
+	if !principal.Allows(action) {
+		return &PermissionError{Action: action, Required: requiredRoles[action]}
+	}
+
+	role, err := svc.WorkspaceRole(principal)
+	if err != nil {
+		return err
+	}
+
+	return checkRole(action, role)
+}
+
+// AuthorizeLink checks an action on a stored link. Links that do not exist
+// any more, such as deleted ones whose analytics are erased, only grant the
+// workspace role.
+func (svc *AccessService) AuthorizeLink(principal Principal, link repository.Link, action Action) error {
+	if !principal.Allows(action) {
+		return &PermissionError{Action: action, Required: requiredRoles[action]}
+	}
+
+	role, err := svc.LinkRole(principal, link)
+	if err != nil {
+		return err
+	}
+
+	return checkRole(action, role)
+}
+
+// AuthorizeCode checks an action on the link with the code in the
+// workspace of the principal.
+func (svc *AccessService) AuthorizeCode(principal Principal, code string, action Action) error {
+	_, _, err := svc.link(principal, code, action)
+
+	return err
+}
+
+// LinkRole returns the role of the principal on the link. Workspace editors
+// and viewers only view the links they neither own nor were shared.
+func (svc *AccessService) LinkRole(principal Principal, link repository.Link) (Role, error) {
+	if principal.Kind == PrincipalManageToken {
+		if link.URL != "" && link.Hash == principal.ID {
+			return RoleOwner, nil
+		}
+
+		return "", nil
+	}
+
+	workspaceRole, err := svc.WorkspaceRole(principal)
+	if err != nil || workspaceRole == RoleOwner {
+		return workspaceRole, err
+	}
+
+	role := workspaceRole
+	if role != "" {
+		role = RoleViewer
+	}
+
+	if link.URL == "" || principal.Kind != PrincipalUser {
+		return role, nil
+	}
+
+	// Demoting a user to viewer takes back the links they created.
+	if workspaceRole == RoleEditor && link.Owner == principal.Subject() {
+		return RoleOwner, nil
+	}
+
+	shared, err := svc.repo.InWorkspace(principal.Workspace).LinkShareRole(link.Hash, principal.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return higherRole(role, Role(shared)), nil
+}
+
+func (svc *AccessService) Members(principal Principal) ([]Member, error) {
+	if err := svc.Authorize(principal, ActionManageTeam); err != nil {
+		return nil, err
+	}
+
+	stored, err := svc.repo.InWorkspace(principal.Workspace).Members()
+	if err != nil {
+		return nil, err
+	}
+
+	return toMembers(stored), nil
+}
+
+func (svc *AccessService) SetMember(principal Principal, user string, req SetMemberRequest) (Member, error) {
+	if err := svc.Authorize(principal, ActionManageTeam); err != nil {
+		return Member{}, err
+	}
+
+	if user == "" {
+		return Member{}, fmt.Errorf("%w: user is required", ErrInvalidRequest)
+	}
+
+	if _, ok := roleRanks[req.Role]; !ok {
+		return Member{}, fmt.Errorf("%w: unknown role %q", ErrInvalidRequest, req.Role)
+	}
+
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	previous, err := repo.MemberRole(user)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if err = repo.SetMember(user, string(req.Role)); err != nil {
+		return Member{}, err
+	}
+
+	svc.logger.LogInfo("workspace member set", repo.Workspace(), user, string(req.Role))
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditMemberSet,
+		Target: user,
+		Before: roleState(Role(previous)),
+		After:  roleState(req.Role),
+	})
+
+	return Member{User: user, Role: req.Role}, nil
+}
+
+func (svc *AccessService) RemoveMember(principal Principal, user string) error {
+	if err := svc.Authorize(principal, ActionManageTeam); err != nil {
+		return err
+	}
+
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	previous, err := repo.MemberRole(user)
+	if err != nil {
+		return err
+	}
+
+	removed, err := repo.RemoveMember(user)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return ErrMemberNotFound
+	}
+
+	svc.logger.LogInfo("workspace member removed", repo.Workspace(), user)
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditMemberRemove,
+		Target: user,
+		Before: roleState(Role(previous)),
+	})
+
+	return nil
+}
+
+// Shares lists the users the link was shared with.
+func (svc *AccessService) Shares(principal Principal, code string) ([]Member, error) {
+	repo, link, err := svc.link(principal, code, ActionViewLink)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := repo.LinkShares(link.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toMembers(stored), nil
+}
+
+// Share gives a user the viewer or editor role on one link.
+func (svc *AccessService) Share(principal Principal, code, user string, req SetMemberRequest) (Member, error) {
+	repo, link, err := svc.link(principal, code, ActionShareLink)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if user == "" {
+		return Member{}, fmt.Errorf("%w: user is required", ErrInvalidRequest)
+	}
+
+	if req.Role != RoleViewer && req.Role != RoleEditor {
+		return Member{}, fmt.Errorf("%w: links are shared with the viewer or editor role", ErrInvalidRequest)
+	}
+
+	previous, err := repo.LinkShareRole(link.Hash, user)
+	if err != nil {
+		return Member{}, err
+	}
+
+	if err = repo.ShareLink(link.Hash, user, string(req.Role)); err != nil {
+		return Member{}, err
+	}
+
+	svc.logger.LogInfo("link shared", repo.Workspace(), link.Hash, user, string(req.Role))
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditLinkShare,
+		Code:   link.Hash,
+		Target: user,
+		Before: roleState(Role(previous)),
+		After:  roleState(req.Role),
+	})
+
+	return Member{User: user, Role: req.Role}, nil
+}
+
+func (svc *AccessService) Unshare(principal Principal, code, user string) error {
+	repo, link, err := svc.link(principal, code, ActionShareLink)
+	if err != nil {
+		return err
+	}
+
+	previous, err := repo.LinkShareRole(link.Hash, user)
+	if err != nil {
+		return err
+	}
+
+	removed, err := repo.UnshareLink(link.Hash, user)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return ErrMemberNotFound
+	}
+
+	svc.logger.LogInfo("link unshared", repo.Workspace(), link.Hash, user)
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditLinkUnshare,
+		Code:   link.Hash,
+		Target: user,
+		Before: roleState(Role(previous)),
+	})
+
+	return nil
+}
+
+// link loads a link of the principal's workspace and checks the action on
+// it.
+func (svc *AccessService) link(
+	principal Principal,
+	code string,
+	action Action) (*repository.RedisRepository, repository.Link, error) {
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	link, err := repo.RetrieveLink(code)
+	if err != nil {
+		return nil, repository.Link{}, err
+	}
+
+	if link.URL == "" {
+		return nil, repository.Link{}, ErrLinkNotFound
+	}
+
+	if err = svc.AuthorizeLink(principal, link, action); err != nil {
+		return nil, repository.Link{}, err
+	}
+
+	return repo, link, nil
+}
+
+func checkRole(action Action, role Role) error {
+	required := requiredRoles[action]
+	if roleRanks[role] < roleRanks[required] {
+		return &PermissionError{Action: action, Role: role, Required: required}
+	}
+
+	return nil
+}
+
+func scopesRole(principal Principal) Role {
+	switch {
+	case principal.HasScope(ScopeAdmin):
+		return RoleOwner
+	case principal.HasScope(ScopeCreate), principal.HasScope(ScopeManage):
+		return RoleEditor
+	case principal.HasScope(ScopeRead):
+		return RoleViewer
+	default:
+		return ""
+	}
+}
+
+func higherRole(a, b Role) Role {
+	if roleRanks[b] > roleRanks[a] {
+		return b
+	}
+
+	return a
+}
+
+func toMembers(stored map[string]string) []Member {
+	members := make([]Member, 0, len(stored))
+	for user, role := range stored {
+		members = append(members, Member{User: user, Role: Role(role)})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].User < members[j].User
+	})
+
+	return members
+}
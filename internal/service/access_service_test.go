@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+)
+
+var (
+	linkActions = []Action{
+		ActionViewLink, ActionEditLink, ActionDeleteLink, ActionShareLink, ActionStreamEvents, ActionEraseAnalytics,
+	}
+	workspaceActions = []Action{
+		ActionListLinks, ActionCreateLink, ActionStreamEvents, ActionEraseAnalytics,
+		ActionManageTeam, ActionModerate, ActionViewAudit, ActionManageQuota,
+	}
+)
+
+func newTestAccessService(t *testing.T) *AccessService {
+	t.Helper()
+
+	conn, _ := redistest.NewClient(t)
+	repo := repository.NewRedisRepository(conn)
+
+	members := map[string]Role{"olivia": RoleOwner, "alice": RoleEditor, "bob": RoleEditor, "victor": RoleViewer}
+	for user, role := range members {
+		if err := repo.SetMember(user, string(role)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Victor is a viewer of the workspace and an editor of the link, dave
+	// only knows the link.
+	shares := map[string]Role{"victor": RoleEditor, "dave": RoleViewer}
+	for user, role := range shares {
+		if err := repo.ShareLink("abc123", user, string(role)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewAccessService(repo, nil, nil)
+}
+
+func testUser(id string, scopes ...Scope) Principal {
+	return Principal{Kind: PrincipalUser, ID: id, Workspace: DefaultWorkspace, Scopes: scopes}
+}
+
+// allowedActions returns the actions of the list authorize lets through,
+// failing on errors other than ErrForbidden.
+func allowedActions(t *testing.T, actions []Action, authorize func(Action) error) []Action {
+	t.Helper()
+
+	var allowed []Action
+
+	for _, action := range actions {
+		err := authorize(action)
+		if err == nil {
+			allowed = append(allowed, action)
+
+			continue
+		}
+
+		var permissionErr *PermissionError
+		if !errors.As(err, &permissionErr) || !errors.Is(err, ErrForbidden) {
+			t.Fatalf("%s: err = %v, want a permission error", action, err)
+		}
+	}
+
+	return allowed
+}
+
+func sameActions(a, b []Action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCheckRole(t *testing.T) {
+	// The lowest role each action needs.
+	lowest := map[Action]Role{
+		ActionViewLink:       RoleViewer,
+		ActionListLinks:      RoleViewer,
+		ActionStreamEvents:   RoleViewer,
+		ActionEditLink:       RoleEditor,
+		ActionCreateLink:     RoleEditor,
+		ActionEraseAnalytics: RoleEditor,
+		ActionDeleteLink:     RoleOwner,
+		ActionShareLink:      RoleOwner,
+		ActionManageTeam:     RoleOwner,
+		ActionModerate:       RoleOwner,
+		ActionViewAudit:      RoleOwner,
+		ActionManageQuota:    RoleOwner,
+	}
+
+	if len(lowest) != len(requiredRoles) {
+		t.Fatalf("%d actions tested, %d defined", len(lowest), len(requiredRoles))
+	}
+
+	for action, required := range lowest {
+		for _, role := range []Role{"", RoleViewer, RoleEditor, RoleOwner} {
+			err := checkRole(action, role)
+
+			if want := roleRanks[role] >= roleRanks[required]; (err == nil) != want {
+				t.Errorf("%s as %q: err = %v, want allowed %v", action, role, err, want)
+			}
+
+			var permissionErr *PermissionError
+			if err != nil && (!errors.As(err, &permissionErr) || permissionErr.Required != required) {
+				t.Errorf("%s as %q: err = %v, want a permission error needing %s", action, role, err, required)
+			}
+		}
+	}
+}
+
+func TestWorkspaceRole(t *testing.T) {
+	access := newTestAccessService(t)
+
+	tests := []struct {
+		name      string
+		principal Principal
+		want      Role
+	}{
+		{name: "api key", principal: Principal{Kind: PrincipalAPIKey, ID: "k1", Scopes: []Scope{ScopeRead}}, want: RoleOwner},
+		{name: "service token", principal: Principal{Kind: PrincipalServiceToken, ID: ServiceTokenStream}, want: RoleOwner},
+		{name: "owner member", principal: testUser("olivia"), want: RoleOwner},
+		{name: "membership over scopes", principal: testUser("alice", ScopeAdmin), want: RoleEditor},
+		{name: "viewer member", principal: testUser("victor", ScopeManage), want: RoleViewer},
+		{name: "admin scope", principal: testUser("eve", ScopeAdmin), want: RoleOwner},
+		{name: "create scope", principal: testUser("eve", ScopeCreate), want: RoleEditor},
+		{name: "manage scope", principal: testUser("eve", ScopeManage), want: RoleEditor},
+		{name: "read scope", principal: testUser("eve", ScopeRead), want: RoleViewer},
+		{name: "no scope", principal: testUser("eve")},
+		{name: "manage token", principal: Principal{Kind: PrincipalManageToken, ID: "abc123"}},
+		{name: "system", principal: Principal{Kind: PrincipalSystem}},
+	}
+
+	for _, tt := range tests {
+		tt.principal.Workspace = DefaultWorkspace
+
+		role, err := access.WorkspaceRole(tt.principal)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if role != tt.want {
+			t.Errorf("%s: role = %q, want %q", tt.name, role, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	access := newTestAccessService(t)
+
+	viewerActions := []Action{ActionListLinks, ActionStreamEvents}
+	editorActions := []Action{ActionListLinks, ActionCreateLink, ActionStreamEvents, ActionEraseAnalytics}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		want      []Action
+	}{
+		{name: "api key", principal: Principal{Kind: PrincipalAPIKey, ID: "k1"}, want: workspaceActions},
+		{name: "owner", principal: testUser("olivia"), want: workspaceActions},
+		{name: "editor", principal: testUser("alice"), want: editorActions},
+		{name: "viewer", principal: testUser("victor"), want: viewerActions},
+		{name: "read scope", principal: testUser("eve", ScopeRead), want: viewerActions},
+		{name: "no role", principal: testUser("eve")},
+		{name: "manage token", principal: Principal{Kind: PrincipalManageToken, ID: "abc123"}},
+		{
+			name: "stream token",
+			principal: Principal{
+				Kind: PrincipalServiceToken, ID: ServiceTokenStream, Actions: []Action{ActionStreamEvents},
+			},
+			want: []Action{ActionStreamEvents},
+		},
+		{
+			name: "erasure token",
+			principal: Principal{
+				Kind: PrincipalServiceToken, ID: ServiceTokenErasure, Actions: []Action{ActionEraseAnalytics},
+			},
+			want: []Action{ActionEraseAnalytics},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.principal.Workspace = DefaultWorkspace
+
+		got := allowedActions(t, workspaceActions, func(action Action) error {
+			return access.Authorize(tt.principal, action)
+		})
+
+		if !sameActions(got, tt.want) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeLink(t *testing.T) {
+	access := newTestAccessService(t)
+
+	link := repository.Link{Hash: "abc123", URL: "https://example.com", Owner: "user:alice"}
+	erased := repository.Link{Hash: "abc123", Owner: "user:alice"}
+
+	viewerActions := []Action{ActionViewLink, ActionStreamEvents}
+	editorActions := []Action{ActionViewLink, ActionEditLink, ActionStreamEvents, ActionEraseAnalytics}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		link      repository.Link
+		want      []Action
+		wantRole  Role
+	}{
+		{name: "api key", principal: Principal{Kind: PrincipalAPIKey, ID: "k1"}, link: link, want: linkActions, wantRole: RoleOwner},
+		{name: "workspace owner", principal: testUser("olivia"), link: link, want: linkActions, wantRole: RoleOwner},
+		{name: "editor owning the link", principal: testUser("alice"), link: link, want: linkActions, wantRole: RoleOwner},
+		{name: "other editor", principal: testUser("bob"), link: link, want: viewerActions, wantRole: RoleViewer},
+		{name: "viewer shared as editor", principal: testUser("victor"), link: link, want: editorActions, wantRole: RoleEditor},
+		{name: "outsider shared as viewer", principal: testUser("dave"), link: link, want: viewerActions, wantRole: RoleViewer},
+		{name: "outsider", principal: testUser("eve"), link: link},
+		{name: "read scope", principal: testUser("eve", ScopeRead), link: link, want: viewerActions, wantRole: RoleViewer},
+		{name: "owner of an erased link", principal: testUser("alice"), link: erased, want: viewerActions, wantRole: RoleViewer},
+		{name: "workspace owner on an erased link", principal: testUser("olivia"), link: erased, want: linkActions, wantRole: RoleOwner},
+		{
+			name:      "manage token of the link",
+			principal: Principal{Kind: PrincipalManageToken, ID: "abc123"},
+			link:      link,
+			want:      linkActions,
+			wantRole:  RoleOwner,
+		},
+		{name: "manage token of another link", principal: Principal{Kind: PrincipalManageToken, ID: "xyz789"}, link: link},
+		{name: "manage token of an erased link", principal: Principal{Kind: PrincipalManageToken, ID: "abc123"}, link: erased},
+		{
+			name: "stream token",
+			principal: Principal{
+				Kind: PrincipalServiceToken, ID: ServiceTokenStream, Actions: []Action{ActionStreamEvents},
+			},
+			link:     link,
+			want:     []Action{ActionStreamEvents},
+			wantRole: RoleOwner,
+		},
+		{
+			name: "erasure token",
+			principal: Principal{
+				Kind: PrincipalServiceToken, ID: ServiceTokenErasure, Actions: []Action{ActionEraseAnalytics},
+			},
+			link:     erased,
+			want:     []Action{ActionEraseAnalytics},
+			wantRole: RoleOwner,
+		},
+	}
+
+	for _, tt := range tests {
+		tt.principal.Workspace = DefaultWorkspace
+
+		role, err := access.LinkRole(tt.principal, tt.link)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if role != tt.wantRole {
+			t.Errorf("%s: role = %q, want %q", tt.name, role, tt.wantRole)
+		}
+
+		got := allowedActions(t, linkActions, func(action Action) error {
+			return access.AuthorizeLink(tt.principal, tt.link, action)
+		})
+
+		if !sameActions(got, tt.want) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
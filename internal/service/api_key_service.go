@@ -0,0 +1,253 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	apiKeyTokenPrefix = "usk_"
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+
+	// Last-used timestamps are written at most this often per key.
+	DefaultAPIKeyTouchInterval = time.Minute
+)
+
+type Scope string
+
+const (
+	// ScopeCreate allows creating short links.
+	ScopeCreate Scope = "create"
+	// ScopeRead allows reading link stats and click streams.
+	ScopeRead Scope = "read"
+	// ScopeManage allows changing and erasing links and their data.
+	ScopeManage Scope = "manage"
+	// ScopeAdmin allows everything, including managing API keys.
+	ScopeAdmin Scope = "admin"
+)
+
+var validScopes = map[Scope]struct{}{
+	ScopeCreate: {},
+	ScopeRead:   {},
+	ScopeManage: {},
+	ScopeAdmin:  {},
+}
+
+type APIKeyService struct {
+	repo   *repository.RedisRepository
+	audit  *AuditLog
+	logger *logger.Logger
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Workspace  string     `json:"workspace"`
+	Name       string     `json:"name"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreatedAPIKey carries the secret key, it is only ever shown once.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+func NewAPIKeyService(redisRepo *repository.RedisRepository, audit *AuditLog, logger *logger.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo:   redisRepo,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// Create issues a key acting inside the workspace of the principal.
+func (svc *APIKeyService) Create(principal Principal, req CreateAPIKeyRequest) (CreatedAPIKey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return CreatedAPIKey{}, fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+
+	if len(req.Scopes) == 0 {
+		return CreatedAPIKey{}, fmt.Errorf("%w: at least one scope is required", ErrInvalidRequest)
+	}
+
+	scopes := make([]string, len(req.Scopes))
+
+	for i, scope := range req.Scopes {
+		if _, ok := validScopes[scope]; !ok {
+			return CreatedAPIKey{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+
+		scopes[i] = string(scope)
+	}
+
+	id, err := randomString(apiKeyIDBytes, hex.EncodeToString)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	secret, err := randomString(apiKeySecretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	token := apiKeyTokenPrefix + id + "_" + secret
+
+	stored := repository.APIKey{
+		ID:        id,
+		Name:      req.Name,
+		Hash:      hashAPIKey(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	repo := svc.repo.InWorkspace(principal.Workspace)
+	stored.Workspace = repo.Workspace()
+
+	if err = repo.StoreAPIKey(stored); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	svc.logger.LogInfo("api key created", id, stored.Workspace, req.Name)
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: stored.Workspace,
+		Action:    AuditAPIKeyCreate,
+		Target:    id,
+		After:     map[string]interface{}{"name": req.Name, "scopes": scopes},
+	})
+
+	return CreatedAPIKey{APIKey: toAPIKey(stored), Key: token}, nil
+}
+
+func (svc *APIKeyService) List(workspace string) ([]APIKey, error) {
+	stored, err := svc.repo.InWorkspace(workspace).APIKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKey, len(stored))
+	for i, key := range stored {
+		keys[i] = toAPIKey(key)
+	}
+
+	return keys, nil
+}
+
+func (svc *APIKeyService) Revoke(principal Principal, id string) error {
+	key, err := svc.repo.APIKey(id)
+	if err != nil {
+		return err
+	}
+
+	if key.ID == "" || key.Workspace != svc.repo.InWorkspace(principal.Workspace).Workspace() {
+		return ErrAPIKeyNotFound
+	}
+
+	if !key.RevokedAt.IsZero() {
+		return nil
+	}
+
+	if err = svc.repo.RevokeAPIKey(key, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	svc.logger.LogInfo("api key revoked", id)
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: key.Workspace,
+		Action:    AuditAPIKeyRevoke,
+		Target:    id,
+		Before:    map[string]interface{}{"name": key.Name, "scopes": key.Scopes},
+	})
+
+	return nil
+}
+
+// Accepts reports whether the bearer token looks like an API key.
+func (svc *APIKeyService) Accepts(token string) bool {
+	return strings.HasPrefix(token, apiKeyTokenPrefix)
+}
+
+// Authenticate returns the principal of the key the token belongs to and
+// records its use.
+func (svc *APIKeyService) Authenticate(token string) (Principal, error) {
+	key, err := svc.repo.APIKeyByHash(hashAPIKey(token))
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if key.ID == "" || !key.RevokedAt.IsZero() {
+		return Principal{}, ErrUnauthorized
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(key.LastUsedAt) >= DefaultAPIKeyTouchInterval {
+		if err = svc.repo.TouchAPIKey(key.ID, now); err != nil {
+			svc.logger.LogError("touch api key", err)
+		}
+	}
+
+	return Principal{
+		Kind:      PrincipalAPIKey,
+		ID:        key.ID,
+		Workspace: key.Workspace,
+		Scopes:    toAPIKey(key).Scopes,
+	}, nil
+}
+
+// Keys are long random strings, so a plain SHA-256 is enough to keep them
+// safe at rest and lets them be looked up by hash.
+func hashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return encode(buf), nil
+}
+
+func toAPIKey(key repository.APIKey) APIKey {
+	apiKey := APIKey{
+		ID:        key.ID,
+		Workspace: key.Workspace,
+		Name:      key.Name,
+		Scopes:    make([]Scope, len(key.Scopes)),
+		CreatedAt: key.CreatedAt,
+	}
+
+	for i, scope := range key.Scopes {
+		apiKey.Scopes[i] = Scope(scope)
+	}
+
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt := key.LastUsedAt
+		apiKey.LastUsedAt = &lastUsedAt
+	}
+
+	if !key.RevokedAt.IsZero() {
+		revokedAt := key.RevokedAt
+		apiKey.RevokedAt = &revokedAt
+	}
+
+	return apiKey
+}
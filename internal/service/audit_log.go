@@ -0,0 +1,153 @@
+package service
+
+import (
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+
+	json "github.com/json-iterator/go"
+)
+
+type AuditAction string
+
+const (
+	AuditLinkCreate      AuditAction = "link.create"
+	AuditLinkUpdate      AuditAction = "link.update"
+	AuditLinkDelete      AuditAction = "link.delete"
+	AuditLinkShare       AuditAction = "link.share"
+	AuditLinkUnshare     AuditAction = "link.unshare"
+	AuditLinkQuarantine  AuditAction = "link.quarantine"
+	AuditLinkRelease     AuditAction = "link.release"
+	AuditLinkDisable     AuditAction = "link.disable"
+	AuditLinkRestore     AuditAction = "link.restore"
+	AuditLinkDismiss     AuditAction = "link.dismiss"
+	AuditLinkErase       AuditAction = "link.erase_analytics"
+	AuditLinkClaim       AuditAction = "link.claim"
+	AuditOwnerErase      AuditAction = "owner.erase_analytics"
+	AuditMemberSet       AuditAction = "member.set"
+	AuditMemberRemove    AuditAction = "member.remove"
+	AuditAPIKeyCreate    AuditAction = "api_key.create"
+	AuditAPIKeyRevoke    AuditAction = "api_key.revoke"
+	AuditWorkspaceCreate AuditAction = "workspace.create"
+	AuditWorkspaceDomain AuditAction = "workspace.add_domain"
+	AuditQuotaSet        AuditAction = "quota.set"
+)
+
+const anonymousActor = "anonymous"
+
+type AuditConfig struct {
+	// Entries older than this are dropped as new ones are appended, zero
+	// keeps them forever.
+	Retention time.Duration
+}
+
+// AuditEntry describes one change: who made it, in which request, and the
+// fields of the changed object before and after it.
+type AuditEntry struct {
+	ID        string      `json:"id"`
+	Time      time.Time   `json:"time"`
+	Workspace string      `json:"workspace"`
+	Actor     string      `json:"actor"`
+	Action    AuditAction `json:"action"`
+	Code      string      `json:"code,omitempty"`
+	// The member, API key or domain the action was about.
+	Target    string                 `json:"target,omitempty"`
+	Before    map[string]interface{} `json:"before,omitempty"`
+	After     map[string]interface{} `json:"after,omitempty"`
+	RequestID string                 `json:"requestId,omitempty"`
+}
+
+// AuditLog appends the changes made through the services to the append-only
+// log of the workspace they happened in. Entries are written after the
+// change, a failed write is logged but does not undo it.
+type AuditLog struct {
+	cfg    AuditConfig
+	repo   *repository.RedisRepository
+	logger *logger.Logger
+}
+
+func NewAuditLog(cfg AuditConfig, redisRepo *repository.RedisRepository, logger *logger.Logger) *AuditLog {
+	return &AuditLog{
+		cfg:    cfg,
+		repo:   redisRepo,
+		logger: logger,
+	}
+}
+
+// Record appends the entry on behalf of the principal. It goes to the
+// workspace of the entry, or of the principal when the entry names none.
+func (l *AuditLog) Record(principal Principal, entry AuditEntry) {
+	if entry.Workspace == "" {
+		entry.Workspace = principal.Workspace
+	}
+
+	actor := principal.Subject()
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	before, err := encodeAuditState(entry.Before)
+	if err != nil {
+		l.logger.LogError("encode audit entry", err)
+	}
+
+	after, err := encodeAuditState(entry.After)
+	if err != nil {
+		l.logger.LogError("encode audit entry", err)
+	}
+
+	err = l.repo.InWorkspace(entry.Workspace).AppendAudit(repository.AuditRecord{
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		Action:    string(entry.Action),
+		Code:      entry.Code,
+		Target:    entry.Target,
+		Before:    before,
+		After:     after,
+		RequestID: principal.RequestID,
+	}, l.cfg.Retention)
+	if err != nil {
+		l.logger.LogError("append audit entry", err)
+	}
+}
+
+func encodeAuditState(state map[string]interface{}) (string, error) {
+	if len(state) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(state)
+
+	return string(data), err
+}
+
+func decodeAuditState(data string) map[string]interface{} {
+	if data == "" {
+		return nil
+	}
+
+	var state map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil
+	}
+
+	return state
+}
+
+// linkState is what the audit log keeps of a link.
+func linkState(link repository.Link) map[string]interface{} {
+	return map[string]interface{}{
+		"url":          link.URL,
+		"interstitial": link.Interstitial,
+		"owner":        link.Owner,
+	}
+}
+
+// roleState is the role of a member or share, nil when there is none.
+func roleState(role Role) map[string]interface{} {
+	if role == "" {
+		return nil
+	}
+
+	return map[string]interface{}{"role": role}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+
+	// Records read from the log at once while filtering.
+	auditScanSize = 500
+)
+
+// AuditQuery filters the audit log of the principal's workspace, zero
+// fields match everything. Cursor continues a previous page.
+type AuditQuery struct {
+	Principal Principal
+	Code      string
+	Actor     string
+	Action    AuditAction
+	Since     time.Time
+	Until     time.Time
+	Cursor    string
+	Limit     int64
+}
+
+// AuditPage holds entries newest first, Next is the cursor of the following
+// page and empty on the last one.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Next    string       `json:"next,omitempty"`
+}
+
+// AuditService lets workspace owners search the audit log.
+type AuditService struct {
+	repo   *repository.RedisRepository
+	access *AccessService
+	logger *logger.Logger
+}
+
+func NewAuditService(
+	redisRepo *repository.RedisRepository,
+	access *AccessService,
+	logger *logger.Logger) *AuditService {
+	return &AuditService{
+		repo:   redisRepo,
+		access: access,
+		logger: logger,
+	}
+}
+
+// Query returns a page of the entries matching the filters, it needs the
+// owner role in the workspace.
+func (svc *AuditService) Query(query AuditQuery) (AuditPage, error) {
+	if err := svc.access.Authorize(query.Principal, ActionViewAudit); err != nil {
+		return AuditPage{}, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	start, end := "-", "+"
+
+	if !query.Since.IsZero() {
+		start = strconv.FormatInt(query.Since.UnixMilli(), 10)
+	}
+
+	if !query.Until.IsZero() {
+		end = strconv.FormatInt(query.Until.UnixMilli(), 10)
+	}
+
+	if query.Cursor != "" {
+		end = repository.PrecedingStreamID(query.Cursor)
+		if end == "" {
+			return AuditPage{}, fmt.Errorf("%w: invalid cursor", ErrInvalidRequest)
+		}
+	}
+
+	repo := svc.repo.InWorkspace(query.Principal.Workspace)
+	page := AuditPage{Entries: []AuditEntry{}}
+
+	for {
+		records, err := repo.AuditRecords(start, end, auditScanSize)
+		if err != nil {
+			return AuditPage{}, err
+		}
+
+		for _, record := range records {
+			if !query.matches(record) {
+				continue
+			}
+
+			page.Entries = append(page.Entries, toAuditEntry(repo.Workspace(), record))
+
+			if int64(len(page.Entries)) == limit {
+				page.Next = record.ID
+
+				return page, nil
+			}
+		}
+
+		if len(records) < auditScanSize {
+			return page, nil
+		}
+
+		if end = repository.PrecedingStreamID(records[len(records)-1].ID); end == "" {
+			return page, nil
+		}
+	}
+}
+
+func (query AuditQuery) matches(record repository.AuditRecord) bool {
+	return (query.Code == "" || record.Code == query.Code) &&
+		(query.Actor == "" || record.Actor == query.Actor) &&
+		(query.Action == "" || record.Action == string(query.Action))
+}
+
+func toAuditEntry(workspace string, record repository.AuditRecord) AuditEntry {
+	return AuditEntry{
+		ID:        record.ID,
+		Time:      record.Time,
+		Workspace: workspace,
+		Actor:     record.Actor,
+		Action:    AuditAction(record.Action),
+		Code:      record.Code,
+		Target:    record.Target,
+		Before:    decodeAuditState(record.Before),
+		After:     decodeAuditState(record.After),
+		RequestID: record.RequestID,
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	currentSecret = "current-secret-0123456789"
+	retiredSecret = "retired-secret-0123456789"
+)
+
+func newTestCodeSigner(t *testing.T, cfg SigningConfig) *CodeSigner {
+	t.Helper()
+
+	signer, err := NewCodeSigner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func TestCodeSignerVerify(t *testing.T) {
+	now := time.Now()
+
+	signer := newTestCodeSigner(t, SigningConfig{Enabled: true, Keys: []SigningKey{{Secret: currentSecret}}})
+	required := newTestCodeSigner(t, SigningConfig{
+		Enabled:          true,
+		Keys:             []SigningKey{{Secret: currentSecret}},
+		RequireSignature: true,
+	})
+	rotated := newTestCodeSigner(t, SigningConfig{
+		Enabled: true,
+		Keys: []SigningKey{
+			{Secret: currentSecret},
+			{Secret: retiredSecret, RetiredAt: now.Add(-time.Hour)},
+		},
+		GracePeriod: 24 * time.Hour,
+	})
+	expired := newTestCodeSigner(t, SigningConfig{
+		Enabled: true,
+		Keys: []SigningKey{
+			{Secret: currentSecret},
+			{Secret: retiredSecret, RetiredAt: now.Add(-25 * time.Hour)},
+		},
+		GracePeriod: 24 * time.Hour,
+	})
+	retired := newTestCodeSigner(t, SigningConfig{Enabled: true, Keys: []SigningKey{{Secret: retiredSecret}}})
+
+	signed := signer.Sign("abc123")
+	signedByRetired := retired.Sign("abc123")
+	signature := signed[strings.LastIndex(signed, signatureSeparator)+1:]
+
+	tests := []struct {
+		name     string
+		signer   *CodeSigner
+		value    string
+		wantCode string
+		wantOK   bool
+	}{
+		{name: "signed code", signer: signer, value: signed, wantCode: "abc123", wantOK: true},
+		{name: "tampered signature", signer: signer, value: signed[:len(signed)-1] + flip(signed[len(signed)-1])},
+		{name: "signature of another code", signer: signer, value: "abc124" + signatureSeparator + signature},
+		{name: "short signature", signer: signer, value: signed[:len(signed)-1]},
+		{name: "signature without a code", signer: signer, value: signatureSeparator + signature},
+		{name: "unsigned code", signer: signer, value: "abc123", wantCode: "abc123", wantOK: true},
+		{name: "unsigned code when required", signer: required, value: "abc123"},
+		{name: "signed code when required", signer: required, value: signed, wantCode: "abc123", wantOK: true},
+		{name: "current key after rotation", signer: rotated, value: signed, wantCode: "abc123", wantOK: true},
+		{name: "retired key in its grace period", signer: rotated, value: signedByRetired, wantCode: "abc123", wantOK: true},
+		{name: "retired key after its grace period", signer: expired, value: signedByRetired},
+		{name: "unknown key", signer: signer, value: signedByRetired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := tt.signer.Verify(tt.value)
+
+			if ok != tt.wantOK || ok && code != tt.wantCode {
+				t.Errorf("Verify(%q) = %q, %v, want %q, %v", tt.value, code, ok, tt.wantCode, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCodeSignerSign(t *testing.T) {
+	signer := newTestCodeSigner(t, SigningConfig{Enabled: true, Keys: []SigningKey{{Secret: currentSecret}}})
+
+	signed := signer.Sign("abc123")
+	if !strings.HasPrefix(signed, "abc123"+signatureSeparator) || len(signed) != len("abc123.")+DefaultSignatureLength {
+		t.Fatalf("signed = %q, want the code and %d characters of signature", signed, DefaultSignatureLength)
+	}
+
+	if again := signer.Sign("abc123"); again != signed {
+		t.Errorf("signatures differ: %q and %q", signed, again)
+	}
+
+	if other := signer.Sign("abc124"); other[len("abc124."):] == signed[len("abc123."):] {
+		t.Error("two codes got the same signature")
+	}
+
+	long := newTestCodeSigner(t, SigningConfig{
+		Enabled:         true,
+		Keys:            []SigningKey{{Secret: currentSecret}},
+		SignatureLength: 100,
+	})
+
+	if signed = long.Sign("abc123"); len(signed) != len("abc123.")+43 {
+		t.Errorf("signed = %q, want the signature cut to the 43 characters of the whole HMAC", signed)
+	}
+}
+
+func TestCodeSignerDisabled(t *testing.T) {
+	signer := newTestCodeSigner(t, SigningConfig{RequireSignature: true})
+
+	if signed := signer.Sign("abc123"); signed != "abc123" {
+		t.Errorf("signed = %q, want the code unchanged", signed)
+	}
+
+	if code, ok := signer.Verify("abc.123"); !ok || code != "abc.123" {
+		t.Errorf("Verify = %q, %v, want the value unchanged", code, ok)
+	}
+}
+
+func TestNewCodeSignerRejectsBadConfig(t *testing.T) {
+	tests := map[string]SigningConfig{
+		"no keys":      {Enabled: true},
+		"short secret": {Enabled: true, Keys: []SigningKey{{Secret: currentSecret}, {Secret: "short"}}},
+		"retired signing key": {
+			Enabled: true,
+			Keys:    []SigningKey{{Secret: currentSecret, RetiredAt: time.Now()}},
+		},
+	}
+
+	for name, cfg := range tests {
+		if _, err := NewCodeSigner(cfg); !errors.Is(err, ErrSigningConfig) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrSigningConfig)
+		}
+	}
+}
+
+// flip swaps a base64url character for another one.
+func flip(c byte) string {
+	if c == 'A' {
+		return "B"
+	}
+
+	return "A"
+}
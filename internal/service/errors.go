@@ -0,0 +1,16 @@
+package service
+
+import "errors"
+
+var (
+	ErrLinkNotFound       = errors.New("link not found")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrWorkspaceNotFound  = errors.New("workspace not found")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrConflict           = errors.New("already exists")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrDestinationBlocked = errors.New("destination blocked")
+	ErrQuotaExceeded      = errors.New("quota exceeded")
+)
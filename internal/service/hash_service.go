@@ -25,10 +25,22 @@ const (
 
 type alphabet map[int64]string
 
+// Generate returns a code no link uses, for reserving it.
+func (svc *HashService) Generate() string {
+	return svc.getHash()
+}
+
+// getHash returns a code no workspace uses yet, codes are unique across
+// workspaces so the shared domains can resolve any of them.
 func (svc *HashService) getHash() string {
 	hash := svc.generateHash()
 
-	if svc.repo.Retrieve(hash) == hash {
+	exists, err := svc.repo.CodeExists(hash)
+	if err != nil {
+		svc.logger.LogError("check short code", err)
+	}
+
+	if exists {
 		return svc.getHash()
 	}
 
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/pkg/jwt"
+)
+
+const (
+	DefaultUserClaim      = "sub"
+	DefaultRolesClaim     = "roles"
+	DefaultWorkspaceClaim = "workspace"
+)
+
+type JWTConfig struct {
+	// Checked when not empty.
+	Issuer   string
+	Audience string
+	// Claim holding the user id.
+	UserClaim string
+	// Claim holding the roles, either a list or a space separated string.
+	RolesClaim string
+	// Claim naming the workspace, users without one act in the default
+	// workspace. Tokens naming a workspace that does not exist are rejected.
+	WorkspaceClaim string
+	// Scopes granted by each role. When empty, roles named after a scope
+	// grant that scope.
+	RoleScopes map[string][]Scope
+	Leeway     time.Duration
+}
+
+// JWTAuthenticator accepts the RS256 and ES256 tokens issued by the SSO and
+// maps their roles to scopes.
+type JWTAuthenticator struct {
+	cfg        JWTConfig
+	keys       *jwt.KeySet
+	workspaces *WorkspaceService
+	logger     *logger.Logger
+}
+
+func NewJWTAuthenticator(
+	cfg JWTConfig,
+	keys *jwt.KeySet,
+	workspaces *WorkspaceService,
+	logger *logger.Logger) *JWTAuthenticator {
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = DefaultUserClaim
+	}
+
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = DefaultRolesClaim
+	}
+
+	if cfg.WorkspaceClaim == "" {
+		cfg.WorkspaceClaim = DefaultWorkspaceClaim
+	}
+
+	return &JWTAuthenticator{
+		cfg:        cfg,
+		keys:       keys,
+		workspaces: workspaces,
+		logger:     logger,
+	}
+}
+
+// Accepts reports whether the bearer token looks like a JWT.
+func (a *JWTAuthenticator) Accepts(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (a *JWTAuthenticator) Authenticate(token string) (Principal, error) {
+	claims, err := jwt.Verify(token, a.keys.Key, jwt.VerifyOptions{
+		Issuer:   a.cfg.Issuer,
+		Audience: a.cfg.Audience,
+		Leeway:   a.cfg.Leeway,
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %s", ErrUnauthorized, err)
+	}
+
+	user := claims.String(a.cfg.UserClaim)
+	if user == "" {
+		return Principal{}, fmt.Errorf("%w: no %q claim", ErrUnauthorized, a.cfg.UserClaim)
+	}
+
+	workspace := claims.String(a.cfg.WorkspaceClaim)
+	if workspace == "" {
+		workspace = DefaultWorkspace
+	}
+
+	exists, err := a.workspaces.Exists(workspace)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if !exists {
+		return Principal{}, fmt.Errorf("%w: unknown workspace %q", ErrUnauthorized, workspace)
+	}
+
+	roles := claims.Strings(a.cfg.RolesClaim)
+
+	return Principal{
+		Kind:      PrincipalUser,
+		ID:        user,
+		Workspace: workspace,
+		Roles:     roles,
+		Scopes:    a.scopes(roles),
+	}, nil
+}
+
+func (a *JWTAuthenticator) scopes(roles []string) []Scope {
+	var scopes []Scope
+
+	for _, role := range roles {
+		if len(a.cfg.RoleScopes) > 0 {
+			scopes = append(scopes, a.cfg.RoleScopes[role]...)
+
+			continue
+		}
+
+		if _, ok := validScopes[Scope(role)]; ok {
+			scopes = append(scopes, Scope(role))
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+	"url-shortener/pkg/jwt"
+
+	json "github.com/json-iterator/go"
+	"go.uber.org/zap"
+)
+
+// es256Token signs the claims with the key, naming it kid "k1".
+func es256Token(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := segment(map[string]string{"alg": "ES256", "typ": "JWT", "kid": "k1"}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthenticatorRejectsUnknownWorkspaces(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "EC",
+		"kid": "k1",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, jwks, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := redistest.NewClient(t)
+	repo := repository.NewRedisRepository(conn)
+	log := logger.NewLogger(zap.NewNop())
+	workspaces := NewWorkspaceService(repo, NewAuditLog(AuditConfig{}, repo, log), log)
+
+	_, err = workspaces.Create(Principal{Kind: PrincipalAPIKey, Workspace: DefaultWorkspace}, CreateWorkspaceRequest{
+		ID:   "acme",
+		Name: "Acme",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := NewJWTAuthenticator(JWTConfig{}, jwt.NewKeySet(jwt.KeySetConfig{Source: path}), workspaces, log)
+
+	tests := []struct {
+		name          string
+		workspace     string
+		wantWorkspace string
+		wantErr       error
+	}{
+		{name: "no workspace claim", wantWorkspace: DefaultWorkspace},
+		{name: "default workspace", workspace: DefaultWorkspace, wantWorkspace: DefaultWorkspace},
+		{name: "created workspace", workspace: "acme", wantWorkspace: "acme"},
+		{name: "unknown workspace", workspace: "ghost", wantErr: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+			if tt.workspace != "" {
+				claims["workspace"] = tt.workspace
+			}
+
+			principal, err := authenticator.Authenticate(es256Token(t, key, claims))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if principal.Workspace != tt.wantWorkspace {
+				t.Errorf("workspace = %q, want %q", principal.Workspace, tt.wantWorkspace)
+			}
+		})
+	}
+}
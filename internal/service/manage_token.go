@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	manageTokenPrefix      = "usm_"
+	manageTokenSecretBytes = 32
+)
+
+// ManageTokenAuthenticator lets whoever created an anonymous link manage it
+// with the token returned on creation. The token acts as the owner of that
+// one link and has no role in the workspace, so it reaches nothing else.
+type ManageTokenAuthenticator struct {
+	repo   *repository.RedisRepository
+	logger *logger.Logger
+}
+
+func NewManageTokenAuthenticator(redisRepo *repository.RedisRepository, logger *logger.Logger) *ManageTokenAuthenticator {
+	return &ManageTokenAuthenticator{
+		repo:   redisRepo,
+		logger: logger,
+	}
+}
+
+// Accepts reports whether the bearer token looks like a manage token.
+func (a *ManageTokenAuthenticator) Accepts(token string) bool {
+	return strings.HasPrefix(token, manageTokenPrefix)
+}
+
+// Authenticate returns the principal of the link the token manages. Tokens
+// of claimed or deleted links are rejected.
+func (a *ManageTokenAuthenticator) Authenticate(token string) (Principal, error) {
+	code := manageTokenCode(token)
+	if code == "" {
+		return Principal{}, ErrUnauthorized
+	}
+
+	workspace, err := a.repo.LinkWorkspace(code)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	link, err := a.repo.InWorkspace(workspace).RetrieveLink(code)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if link.URL == "" || !checkManageToken(link, token) {
+		return Principal{}, ErrUnauthorized
+	}
+
+	return Principal{
+		Kind:      PrincipalManageToken,
+		ID:        code,
+		Workspace: link.Workspace,
+		Scopes:    []Scope{ScopeRead, ScopeManage},
+	}, nil
+}
+
+// newManageToken returns a token for the link and the hash it is stored as.
+// The code is part of the token so it can be checked without an index.
+func newManageToken(code string) (string, string, error) {
+	secret, err := randomString(manageTokenSecretBytes, hex.EncodeToString)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := manageTokenPrefix + code + "_" + secret
+
+	return token, hashAPIKey(token), nil
+}
+
+func manageTokenCode(token string) string {
+	rest := strings.TrimPrefix(token, manageTokenPrefix)
+
+	index := strings.LastIndexByte(rest, '_')
+	if index <= 0 {
+		return ""
+	}
+
+	return rest[:index]
+}
+
+func checkManageToken(link repository.Link, token string) bool {
+	if link.ManageToken == "" || manageTokenCode(token) != link.Hash {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(token)), []byte(link.ManageToken)) == 1
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// storeAnonymousLink stores a link the way an anonymous create does and
+// returns its manage token.
+func storeAnonymousLink(t *testing.T, repo *repository.RedisRepository, workspace, code string) string {
+	token, hash, err := newManageToken(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.InWorkspace(workspace).Store(repository.Link{
+		Hash:        code,
+		URL:         "https://example.com/" + code,
+		CreatedAt:   time.Now(),
+		ManageToken: hash,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestManageTokenAuthenticator(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	repo := repository.NewRedisRepository(conn)
+	authenticator := NewManageTokenAuthenticator(repo, logger.NewLogger(zap.NewNop()))
+
+	token := storeAnonymousLink(t, repo, DefaultWorkspace, "abc123")
+	acmeToken := storeAnonymousLink(t, repo, "acme", "a_b_c")
+	otherToken := storeAnonymousLink(t, repo, DefaultWorkspace, "xyz789")
+	claimedToken := storeAnonymousLink(t, repo, DefaultWorkspace, "claimd")
+	deletedToken := storeAnonymousLink(t, repo, DefaultWorkspace, "gone01")
+
+	if err := repo.ClaimLink("claimd", "user:alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteLink("gone01"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		wantCode      string
+		wantWorkspace string
+		wantErr       error
+	}{
+		{name: "valid token", token: token, wantCode: "abc123", wantWorkspace: DefaultWorkspace},
+		{name: "link of another workspace", token: acmeToken, wantCode: "a_b_c", wantWorkspace: "acme"},
+		{name: "wrong secret", token: token[:len(token)-1] + "0", wantErr: ErrUnauthorized},
+		{
+			name:    "secret of another link",
+			token:   manageTokenPrefix + "abc123_" + otherToken[len(otherToken)-2*manageTokenSecretBytes:],
+			wantErr: ErrUnauthorized,
+		},
+		{name: "claimed link", token: claimedToken, wantErr: ErrUnauthorized},
+		{name: "deleted link", token: deletedToken, wantErr: ErrUnauthorized},
+		{name: "unknown link", token: manageTokenPrefix + "nope00_" + "00", wantErr: ErrUnauthorized},
+		{name: "no code", token: manageTokenPrefix + "_secret", wantErr: ErrUnauthorized},
+		{name: "no secret separator", token: manageTokenPrefix + "abc123", wantErr: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !authenticator.Accepts(tt.token) {
+				t.Fatalf("token %q not accepted", tt.token)
+			}
+
+			principal, err := authenticator.Authenticate(tt.token)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if principal.Kind != PrincipalManageToken || principal.ID != tt.wantCode ||
+				principal.Workspace != tt.wantWorkspace {
+				t.Errorf("principal = %+v, want the manage token of %s in %s", principal, tt.wantCode, tt.wantWorkspace)
+			}
+
+			if !principal.HasScope(ScopeManage) || !principal.HasScope(ScopeRead) || principal.HasScope(ScopeCreate) {
+				t.Errorf("scopes = %v, want read and manage", principal.Scopes)
+			}
+		})
+	}
+}
+
+func TestManageTokenAccepts(t *testing.T) {
+	authenticator := NewManageTokenAuthenticator(nil, nil)
+
+	for token, want := range map[string]bool{
+		"usm_abc123_00ff":   true,
+		"usk_0123_secret":   false,
+		"eyJhbGciOi.e30.xx": false,
+		"":                  false,
+	} {
+		if got := authenticator.Accepts(token); got != want {
+			t.Errorf("Accepts(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+// TestManageTokenRole checks that a manage token owns its link and nothing
+// else.
+func TestManageTokenRole(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	repo := repository.NewRedisRepository(conn)
+	access := NewAccessService(repo, nil, nil)
+
+	storeAnonymousLink(t, repo, DefaultWorkspace, "abc123")
+	storeAnonymousLink(t, repo, DefaultWorkspace, "xyz789")
+
+	principal := Principal{Kind: PrincipalManageToken, ID: "abc123", Workspace: DefaultWorkspace}
+
+	own, err := repo.RetrieveLink("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := repo.RetrieveLink("xyz789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = access.AuthorizeLink(principal, own, ActionDeleteLink); err != nil {
+		t.Errorf("delete own link: %v", err)
+	}
+
+	if err = access.AuthorizeLink(principal, other, ActionViewLink); !errors.Is(err, ErrForbidden) {
+		t.Errorf("view another link: err = %v, want %v", err, ErrForbidden)
+	}
+
+	if err = access.Authorize(principal, ActionListLinks); !errors.Is(err, ErrForbidden) {
+		t.Errorf("list links: err = %v, want %v", err, ErrForbidden)
+	}
+}
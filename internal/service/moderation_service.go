@@ -0,0 +1,460 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultMaxReportsPerLink = 100
+	DefaultMaxReportDetails  = 1000
+
+	reportQueuePageSize = 50
+	reporterIDLength    = 16
+	maxEmailLength      = 254
+	maxModerationReason = 500
+)
+
+type ReportReason string
+
+const (
+	ReportSpam     ReportReason = "spam"
+	ReportPhishing ReportReason = "phishing"
+	ReportMalware  ReportReason = "malware"
+	ReportOther    ReportReason = "other"
+)
+
+// ReportReasons lists the reasons in the order the report form offers them.
+var ReportReasons = []ReportReason{ReportSpam, ReportPhishing, ReportMalware, ReportOther}
+
+const (
+	ModerationDisable = "disable"
+	ModerationRestore = "restore"
+	// ModerationDismiss resolves the reports of a link that was not disabled.
+	ModerationDismiss = "dismiss"
+)
+
+var moderationAuditActions = map[string]AuditAction{
+	ModerationDisable: AuditLinkDisable,
+	ModerationRestore: AuditLinkRestore,
+	ModerationDismiss: AuditLinkDismiss,
+}
+
+type ModerationConfig struct {
+	// Secret keying the reporter address hashes.
+	ReporterSecret string
+	// Reports kept per link, older ones are dropped.
+	MaxReportsPerLink int64
+	// Longest free text accepted with a report, in characters.
+	MaxDetailsLength int
+}
+
+type ReportRequest struct {
+	Reason  ReportReason `json:"reason"`
+	Details string       `json:"details"`
+	Email   string       `json:"email"`
+}
+
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+}
+
+type AbuseReport struct {
+	Reason    ReportReason `json:"reason"`
+	Details   string       `json:"details,omitempty"`
+	Email     string       `json:"email,omitempty"`
+	Reporter  string       `json:"reporter"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type ModerationEntry struct {
+	Action string    `json:"action"`
+	Reason string    `json:"reason"`
+	Actor  string    `json:"actor"`
+	At     time.Time `json:"at"`
+}
+
+// QueuedLink is a link with unresolved reports.
+type QueuedLink struct {
+	Code           string    `json:"code"`
+	Workspace      string    `json:"workspace"`
+	Destination    string    `json:"destination"`
+	Reports        int64     `json:"reports"`
+	LastReportedAt time.Time `json:"lastReportedAt"`
+}
+
+type ReportQueue struct {
+	Links []QueuedLink `json:"links"`
+	Total int64        `json:"total"`
+}
+
+type ReportQueueRequest struct {
+	Principal Principal
+	Offset    int64
+	Limit     int64
+}
+
+// LinkModeration is the moderation state of a link with its reports and
+// audit trail.
+type LinkModeration struct {
+	Code        string            `json:"code"`
+	Workspace   string            `json:"workspace"`
+	Destination string            `json:"destination"`
+	Disabled    string            `json:"disabled,omitempty"`
+	DisabledAt  *time.Time        `json:"disabledAt,omitempty"`
+	DisabledBy  string            `json:"disabledBy,omitempty"`
+	Quarantine  string            `json:"quarantine,omitempty"`
+	Reports     []AbuseReport     `json:"reports"`
+	History     []ModerationEntry `json:"history"`
+}
+
+// ModerationService takes abuse reports from visitors and lets the platform
+// moderators disable and restore the reported links. Moderators are owners
+// of the default workspace, they act on the links of every workspace.
+type ModerationService struct {
+	cfg        ModerationConfig
+	repo       *repository.RedisRepository
+	workspaces *WorkspaceService
+	access     *AccessService
+	audit      *AuditLog
+	logger     *logger.Logger
+}
+
+func NewModerationService(
+	cfg ModerationConfig,
+	redisRepo *repository.RedisRepository,
+	workspaces *WorkspaceService,
+	access *AccessService,
+	audit *AuditLog,
+	logger *logger.Logger) *ModerationService {
+	if cfg.MaxReportsPerLink <= 0 {
+		cfg.MaxReportsPerLink = DefaultMaxReportsPerLink
+	}
+
+	if cfg.MaxDetailsLength <= 0 {
+		cfg.MaxDetailsLength = DefaultMaxReportDetails
+	}
+
+	return &ModerationService{
+		cfg:        cfg,
+		repo:       redisRepo,
+		workspaces: workspaces,
+		access:     access,
+		audit:      audit,
+		logger:     logger,
+	}
+}
+
+// Reportable checks that the link requested on the host exists, before the
+// report form is shown.
+func (svc *ModerationService) Reportable(host, code string) error {
+	_, _, err := svc.resolve(host, code)
+
+	return err
+}
+
+// MaxDetailsLength is the longest report details accepted.
+func (svc *ModerationService) MaxDetailsLength() int {
+	return svc.cfg.MaxDetailsLength
+}
+
+// Report files a report against the link requested on the host. Repeated
+// reports from the same address and reports against disabled links are
+// accepted but not stored.
+func (svc *ModerationService) Report(host, code, reporterIP string, req ReportRequest) error {
+	if err := svc.validateReport(&req); err != nil {
+		return err
+	}
+
+	repo, link, err := svc.resolve(host, code)
+	if err != nil {
+		return err
+	}
+
+	if link.Disabled != "" {
+		return nil
+	}
+
+	added, err := repo.AddAbuseReport(code, repository.AbuseReport{
+		Reason:    string(req.Reason),
+		Details:   req.Details,
+		Email:     req.Email,
+		Reporter:  svc.reporterID(reporterIP),
+		CreatedAt: time.Now().UTC(),
+	}, svc.cfg.MaxReportsPerLink)
+	if err != nil {
+		return err
+	}
+
+	if added {
+		svc.logger.LogInfo("link reported", repo.Workspace(), code, string(req.Reason))
+	}
+
+	return nil
+}
+
+// Queue lists the reported links waiting for a decision, the most recently
+// reported first.
+func (svc *ModerationService) Queue(req ReportQueueRequest) (ReportQueue, error) {
+	if err := svc.authorize(req.Principal); err != nil {
+		return ReportQueue{}, err
+	}
+
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	if req.Limit <= 0 || req.Limit > reportQueuePageSize {
+		req.Limit = reportQueuePageSize
+	}
+
+	queued, total, err := svc.repo.ReportQueue(req.Offset, req.Limit)
+	if err != nil {
+		return ReportQueue{}, err
+	}
+
+	queue := ReportQueue{Links: make([]QueuedLink, 0, len(queued)), Total: total}
+
+	for _, entry := range queued {
+		_, link, errLink := svc.lookup(entry.Code)
+		if errLink != nil && !errors.Is(errLink, ErrLinkNotFound) {
+			return ReportQueue{}, errLink
+		}
+
+		queue.Links = append(queue.Links, QueuedLink{
+			Code:           entry.Code,
+			Workspace:      link.Workspace,
+			Destination:    link.URL,
+			Reports:        link.Reports,
+			LastReportedAt: entry.LastReportedAt,
+		})
+	}
+
+	return queue, nil
+}
+
+// Link returns the reports and the moderation history of a link of any
+// workspace.
+func (svc *ModerationService) Link(principal Principal, code string) (LinkModeration, error) {
+	if err := svc.authorize(principal); err != nil {
+		return LinkModeration{}, err
+	}
+
+	repo, link, err := svc.lookup(code)
+	if err != nil {
+		return LinkModeration{}, err
+	}
+
+	return svc.moderation(repo, link)
+}
+
+// Disable stops a link from redirecting, visitors get the disabled link page
+// instead. The reason is recorded in the audit trail.
+func (svc *ModerationService) Disable(principal Principal, code string, req ModerationRequest) (LinkModeration, error) {
+	return svc.decide(principal, code, req, ModerationDisable)
+}
+
+// Restore lets a disabled link redirect again, on a link that is not
+// disabled it dismisses the reports.
+func (svc *ModerationService) Restore(principal Principal, code string, req ModerationRequest) (LinkModeration, error) {
+	return svc.decide(principal, code, req, ModerationRestore)
+}
+
+func (svc *ModerationService) decide(
+	principal Principal,
+	code string,
+	req ModerationRequest,
+	action string) (LinkModeration, error) {
+	if err := svc.authorize(principal); err != nil {
+		return LinkModeration{}, err
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return LinkModeration{}, fmt.Errorf("%w: reason is required", ErrInvalidRequest)
+	}
+
+	if utf8.RuneCountInString(req.Reason) > maxModerationReason {
+		return LinkModeration{}, fmt.Errorf("%w: reason is longer than %d characters", ErrInvalidRequest, maxModerationReason)
+	}
+
+	repo, link, err := svc.lookup(code)
+	if err != nil {
+		return LinkModeration{}, err
+	}
+
+	entry := repository.ModerationEntry{
+		Action: action,
+		Reason: req.Reason,
+		Actor:  principal.Subject(),
+		At:     time.Now().UTC(),
+	}
+
+	switch {
+	case action == ModerationDisable:
+		err = repo.DisableLink(code, entry)
+	case link.Disabled == "":
+		entry.Action = ModerationDismiss
+		err = repo.RestoreLink(code, entry)
+	default:
+		err = repo.RestoreLink(code, entry)
+	}
+
+	if err != nil {
+		return LinkModeration{}, err
+	}
+
+	svc.logger.LogInfo("link moderated", repo.Workspace(), code, entry.Action, entry.Actor)
+
+	before := link.Disabled
+
+	if link, err = repo.RetrieveLink(code); err != nil {
+		return LinkModeration{}, err
+	}
+
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: repo.Workspace(),
+		Action:    moderationAuditActions[entry.Action],
+		Code:      code,
+		Before:    map[string]interface{}{"disabled": before},
+		After:     map[string]interface{}{"disabled": link.Disabled, "reason": entry.Reason},
+	})
+
+	return svc.moderation(repo, link)
+}
+
+// authorize lets the owners of the default workspace moderate.
+func (svc *ModerationService) authorize(principal Principal) error {
+	if principal.Workspace != DefaultWorkspace {
+		return &PermissionError{Action: ActionModerate, Required: RoleOwner}
+	}
+
+	return svc.access.Authorize(principal, ActionModerate)
+}
+
+func (svc *ModerationService) moderation(repo *repository.RedisRepository, link repository.Link) (LinkModeration, error) {
+	reports, err := repo.AbuseReports(link.Hash, svc.cfg.MaxReportsPerLink)
+	if err != nil {
+		return LinkModeration{}, err
+	}
+
+	history, err := repo.ModerationHistory(link.Hash)
+	if err != nil {
+		return LinkModeration{}, err
+	}
+
+	moderation := LinkModeration{
+		Code:        link.Hash,
+		Workspace:   link.Workspace,
+		Destination: link.URL,
+		Disabled:    link.Disabled,
+		DisabledBy:  link.DisabledBy,
+		Quarantine:  link.Quarantine,
+		Reports:     make([]AbuseReport, len(reports)),
+		History:     make([]ModerationEntry, len(history)),
+	}
+
+	if !link.DisabledAt.IsZero() {
+		moderation.DisabledAt = &link.DisabledAt
+	}
+
+	for i, report := range reports {
+		moderation.Reports[i] = AbuseReport{
+			Reason:    ReportReason(report.Reason),
+			Details:   report.Details,
+			Email:     report.Email,
+			Reporter:  report.Reporter,
+			CreatedAt: report.CreatedAt,
+		}
+	}
+
+	for i, entry := range history {
+		moderation.History[i] = ModerationEntry(entry)
+	}
+
+	return moderation, nil
+}
+
+func (svc *ModerationService) validateReport(req *ReportRequest) error {
+	valid := false
+
+	for _, reason := range ReportReasons {
+		valid = valid || req.Reason == reason
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: unknown reason %q", ErrInvalidRequest, req.Reason)
+	}
+
+	req.Details = strings.TrimSpace(req.Details)
+	if utf8.RuneCountInString(req.Details) > svc.cfg.MaxDetailsLength {
+		return fmt.Errorf("%w: details are longer than %d characters", ErrInvalidRequest, svc.cfg.MaxDetailsLength)
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		return nil
+	}
+
+	if len(req.Email) > maxEmailLength {
+		return fmt.Errorf("%w: email is too long", ErrInvalidRequest)
+	}
+
+	if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+		return fmt.Errorf("%w: invalid email", ErrInvalidRequest)
+	}
+
+	return nil
+}
+
+// resolve finds the link requested on the host the way redirects do.
+func (svc *ModerationService) resolve(host, code string) (*repository.RedisRepository, repository.Link, error) {
+	workspace := svc.workspaces.ResolveHost(host)
+	if workspace == "" {
+		return svc.lookup(code)
+	}
+
+	return svc.retrieve(svc.repo.InWorkspace(workspace), code)
+}
+
+// lookup finds the link in whichever workspace owns the code.
+func (svc *ModerationService) lookup(code string) (*repository.RedisRepository, repository.Link, error) {
+	workspace, err := svc.repo.LinkWorkspace(code)
+	if err != nil {
+		return nil, repository.Link{}, err
+	}
+
+	return svc.retrieve(svc.repo.InWorkspace(workspace), code)
+}
+
+func (svc *ModerationService) retrieve(
+	repo *repository.RedisRepository,
+	code string) (*repository.RedisRepository, repository.Link, error) {
+	link, err := repo.RetrieveLink(code)
+	if err != nil {
+		return nil, repository.Link{}, err
+	}
+
+	if link.URL == "" {
+		return nil, link, ErrLinkNotFound
+	}
+
+	return repo, link, nil
+}
+
+// reporterID tells reporters apart without storing their address.
+func (svc *ModerationService) reporterID(ip string) string {
+	mac := hmac.New(sha256.New, []byte(svc.cfg.ReporterSecret))
+	_, _ = mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil))[:reporterIDLength]
+}
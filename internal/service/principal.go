@@ -0,0 +1,70 @@
+package service
+
+type PrincipalKind string
+
+const (
+	PrincipalAPIKey PrincipalKind = "api_key"
+	PrincipalUser   PrincipalKind = "user"
+	// PrincipalSystem is the service itself, acting from the command line or
+	// a background job.
+	PrincipalSystem PrincipalKind = "system"
+	// PrincipalManageToken holds the manage token of an anonymous link, its
+	// ID is the code of the link.
+	PrincipalManageToken PrincipalKind = "manage_token"
+	// PrincipalServiceToken holds one of the static tokens of the
+	// configuration, its ID names the token.
+	PrincipalServiceToken PrincipalKind = "service_token"
+)
+
+// Principal is who a request was authenticated as: an API key, an SSO user
+// or the manage token of a link, always acting inside one workspace.
+type Principal struct {
+	Kind      PrincipalKind
+	ID        string
+	Workspace string
+	Roles     []string
+	Scopes    []Scope
+	// The only actions the principal may take whatever its role, all of them
+	// when nil.
+	Actions []Action
+	// Request the principal is acting in, recorded in the audit log.
+	RequestID string
+}
+
+// HasScope reports whether the principal was granted the scope, admin
+// grants all.
+func (p Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the principal may take the action at all, its
+// role still decides where.
+func (p Principal) Allows(action Action) bool {
+	if p.Actions == nil {
+		return true
+	}
+
+	for _, allowed := range p.Actions {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subject identifies the principal across kinds, it is what link ownership
+// is recorded as. Anonymous principals have none.
+func (p Principal) Subject() string {
+	if p.ID == "" {
+		return ""
+	}
+
+	return string(p.Kind) + ":" + p.ID
+}
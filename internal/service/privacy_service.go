@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"url-shortener/internal/analytics"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+type PrivacyService struct {
+	repo     *repository.RedisRepository
+	visitors analytics.VisitorCounter
+	sinks    *analytics.Fanout
+	access   *AccessService
+	audit    *AuditLog
+	logger   *logger.Logger
+}
+
+func NewPrivacyService(
+	redisRepo *repository.RedisRepository,
+	visitors analytics.VisitorCounter,
+	sinks *analytics.Fanout,
+	access *AccessService,
+	audit *AuditLog,
+	logger *logger.Logger) *PrivacyService {
+	return &PrivacyService{
+		repo:     redisRepo,
+		visitors: visitors,
+		sinks:    sinks,
+		access:   access,
+		audit:    audit,
+		logger:   logger,
+	}
+}
+
+// OwnerErasure lists the links whose analytics were erased for an owner.
+type OwnerErasure struct {
+	Owner string   `json:"owner"`
+	Codes []string `json:"codes"`
+}
+
+// EraseLinkAnalytics deletes everything recorded about the visitors of the
+// link: counters, unique visitor estimates, leaderboard entries and the raw
+// events kept by the sinks. The link itself stays. It needs the editor role
+// on the link, or the owner role in the workspace once the link is deleted.
+func (svc *PrivacyService) EraseLinkAnalytics(principal Principal, code string) error {
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	link, err := repo.RetrieveLink(code)
+	if err != nil {
+		return err
+	}
+
+	if err = svc.access.AuthorizeLink(principal, link, ActionEraseAnalytics); err != nil {
+		return err
+	}
+
+	if err = svc.erase(repo, code); err != nil {
+		return err
+	}
+
+	svc.audit.Record(principal, AuditEntry{Action: AuditLinkErase, Code: code})
+
+	return nil
+}
+
+// EraseOwnerAnalytics erases the analytics of every link the owner, a
+// principal subject such as "user:alice", created in the workspace. Every
+// link is authorized as in EraseLinkAnalytics before any is erased.
+func (svc *PrivacyService) EraseOwnerAnalytics(principal Principal, owner string) (OwnerErasure, error) {
+	if owner == "" {
+		return OwnerErasure{}, fmt.Errorf("%w: owner is required", ErrInvalidRequest)
+	}
+
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	links, err := repo.LinksOwnedBy(owner)
+	if err != nil {
+		return OwnerErasure{}, err
+	}
+
+	for _, link := range links {
+		if err = svc.access.AuthorizeLink(principal, link, ActionEraseAnalytics); err != nil {
+			return OwnerErasure{}, err
+		}
+	}
+
+	erasure := OwnerErasure{Owner: owner, Codes: make([]string, 0, len(links))}
+
+	for _, link := range links {
+		if err = svc.erase(repo, link.Hash); err != nil {
+			return erasure, err
+		}
+
+		erasure.Codes = append(erasure.Codes, link.Hash)
+	}
+
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditOwnerErase,
+		Target: owner,
+		After:  map[string]interface{}{"codes": erasure.Codes},
+	})
+
+	return erasure, nil
+}
+
+func (svc *PrivacyService) erase(repo *repository.RedisRepository, code string) error {
+	if err := repo.EraseLinkStats(code); err != nil {
+		return err
+	}
+
+	if err := svc.visitors.Erase(repo.Workspace(), code); err != nil {
+		return err
+	}
+
+	if err := svc.sinks.Erase(repo.Workspace(), code); err != nil {
+		return err
+	}
+
+	svc.logger.LogInfo("link analytics erased", repo.Workspace(), code)
+
+	return nil
+}
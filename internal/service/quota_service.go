@@ -0,0 +1,467 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+type QuotaScope string
+
+const (
+	QuotaScopeWorkspace QuotaScope = "workspace"
+	QuotaScopeAPIKey    QuotaScope = "api_key"
+)
+
+type QuotaPeriod string
+
+const (
+	// QuotaDaily counts the links created since midnight UTC.
+	QuotaDaily QuotaPeriod = "daily"
+	// QuotaTotal counts every link ever created, deleting links does not
+	// give them back.
+	QuotaTotal QuotaPeriod = "total"
+)
+
+// QuotaLimits caps the links created per day and overall, zero is
+// unlimited.
+type QuotaLimits struct {
+	Daily int64 `json:"daily"`
+	Total int64 `json:"total"`
+}
+
+type QuotaConfig struct {
+	// Limits of the workspaces and API keys without limits of their own.
+	Workspace QuotaLimits
+	APIKey    QuotaLimits
+	// Limits of single workspaces by id, limits set through the API take
+	// precedence.
+	Workspaces map[string]QuotaLimits
+}
+
+// SetQuotaRequest replaces the limits set through the API, omitted limits
+// fall back to the configured ones.
+type SetQuotaRequest struct {
+	Daily *int64 `json:"daily"`
+	Total *int64 `json:"total"`
+}
+
+// QuotaCounter is the usage of one period, Remaining is omitted when it is
+// unlimited.
+type QuotaCounter struct {
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+type Quota struct {
+	Scope QuotaScope   `json:"scope"`
+	ID    string       `json:"id"`
+	Daily QuotaCounter `json:"daily"`
+	Total QuotaCounter `json:"total"`
+	// When the daily counter starts over.
+	ResetAt time.Time `json:"resetAt"`
+}
+
+// Quotas are all the quotas a link is counted against.
+type Quotas []Quota
+
+// Tightest returns the limited counter of the period with the fewest links
+// remaining, false when the period is unlimited.
+func (q Quotas) Tightest(period QuotaPeriod) (QuotaCounter, bool) {
+	var (
+		tightest QuotaCounter
+		found    bool
+	)
+
+	for _, quota := range q {
+		counter := quota.Daily
+		if period == QuotaTotal {
+			counter = quota.Total
+		}
+
+		if counter.Remaining != nil && (!found || *counter.Remaining < *tightest.Remaining) {
+			tightest, found = counter, true
+		}
+	}
+
+	return tightest, found
+}
+
+// QuotaError tells which quota a link would exceed, it matches
+// ErrQuotaExceeded.
+type QuotaError struct {
+	Scope  QuotaScope  `json:"scope"`
+	ID     string      `json:"id"`
+	Period QuotaPeriod `json:"period"`
+	Limit  int64       `json:"limit"`
+	// When a daily quota has room again.
+	ResetAt *time.Time `json:"resetAt,omitempty"`
+	Quotas  Quotas     `json:"-"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s limit of %d links for %s %s", ErrQuotaExceeded, e.Period, e.Limit, e.Scope, e.ID)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaService caps the links created by every workspace, and by every API
+// key within it. Links are counted atomically against both as they are
+// created.
+type QuotaService struct {
+	cfg    QuotaConfig
+	repo   *repository.RedisRepository
+	access *AccessService
+	audit  *AuditLog
+	logger *logger.Logger
+}
+
+func NewQuotaService(
+	cfg QuotaConfig,
+	redisRepo *repository.RedisRepository,
+	access *AccessService,
+	audit *AuditLog,
+	logger *logger.Logger) *QuotaService {
+	return &QuotaService{
+		cfg:    cfg,
+		repo:   redisRepo,
+		access: access,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// Take counts a new link against the quotas of the principal, its workspace
+// and its API key if it is one. Nothing is counted when one of them is used
+// up, the QuotaError names it.
+func (svc *QuotaService) Take(principal Principal) (Quotas, error) {
+	owners, err := svc.owners(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	taken, usage, err := svc.repo.TakeQuota(owners, now)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := toQuotas(owners, usage, now)
+	if taken {
+		return quotas, nil
+	}
+
+	// A used up total quota is reported first, waiting for tomorrow does not
+	// help with it.
+	for _, period := range []QuotaPeriod{QuotaTotal, QuotaDaily} {
+		for i, owner := range owners {
+			limit, used := owner.Total, usage[i].Total
+			if period == QuotaDaily {
+				limit, used = owner.Daily, usage[i].Daily
+			}
+
+			if limit <= 0 || used < limit {
+				continue
+			}
+
+			quotaErr := &QuotaError{
+				Scope:  QuotaScope(owner.Scope),
+				ID:     owner.ID,
+				Period: period,
+				Limit:  limit,
+				Quotas: quotas,
+			}
+
+			if period == QuotaDaily {
+				quotaErr.ResetAt = &quotas[i].ResetAt
+			}
+
+			return nil, quotaErr
+		}
+	}
+
+	return nil, fmt.Errorf("%w: quota not counted", ErrQuotaExceeded)
+}
+
+// Release gives back a link counted by Take that was not created after all.
+func (svc *QuotaService) Release(quotas Quotas) {
+	if len(quotas) == 0 {
+		return
+	}
+
+	owners := make([]repository.QuotaOwner, len(quotas))
+	for i, quota := range quotas {
+		owners[i] = repository.QuotaOwner{Scope: string(quota.Scope), ID: quota.ID}
+	}
+
+	if err := svc.repo.ReleaseQuota(owners, quotas[0].ResetAt.Add(-24*time.Hour)); err != nil {
+		svc.logger.LogError("release quota", err)
+	}
+}
+
+// Usage returns the quotas the links of the principal count against.
+func (svc *QuotaService) Usage(principal Principal) (Quotas, error) {
+	if err := svc.access.Authorize(principal, ActionListLinks); err != nil {
+		return nil, err
+	}
+
+	owners, err := svc.owners(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.usage(owners)
+}
+
+// Workspace returns the quota of a workspace, only admins of the default
+// workspace see other workspaces.
+func (svc *QuotaService) Workspace(principal Principal, id string) (Quota, error) {
+	owner, err := svc.workspaceOwner(principal, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	quotas, err := svc.usage([]repository.QuotaOwner{owner})
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return quotas[0], nil
+}
+
+// SetWorkspace changes the limits of a workspace, only admins of the default
+// workspace can.
+func (svc *QuotaService) SetWorkspace(principal Principal, id string, req SetQuotaRequest) (Quota, error) {
+	if principal.Workspace != DefaultWorkspace {
+		return Quota{}, ErrForbidden
+	}
+
+	owner, err := svc.workspaceOwner(principal, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return svc.set(principal, owner, req)
+}
+
+// APIKey returns the quota of an API key of the principal's workspace.
+func (svc *QuotaService) APIKey(principal Principal, id string) (Quota, error) {
+	owner, err := svc.apiKeyOwner(principal, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	quotas, err := svc.usage([]repository.QuotaOwner{owner})
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return quotas[0], nil
+}
+
+// SetAPIKey changes the limits of an API key of the principal's workspace.
+func (svc *QuotaService) SetAPIKey(principal Principal, id string, req SetQuotaRequest) (Quota, error) {
+	owner, err := svc.apiKeyOwner(principal, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return svc.set(principal, owner, req)
+}
+
+func (svc *QuotaService) set(principal Principal, owner repository.QuotaOwner, req SetQuotaRequest) (Quota, error) {
+	if (req.Daily != nil && *req.Daily < 0) || (req.Total != nil && *req.Total < 0) {
+		return Quota{}, fmt.Errorf("%w: quota limits must not be negative", ErrInvalidRequest)
+	}
+
+	before, err := svc.repo.QuotaLimits(owner.Scope, owner.ID)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	err = svc.repo.SetQuotaLimits(owner.Scope, owner.ID, repository.QuotaLimits{Daily: req.Daily, Total: req.Total})
+	if err != nil {
+		return Quota{}, err
+	}
+
+	workspace := owner.ID
+	if owner.Scope == string(QuotaScopeAPIKey) {
+		workspace = principal.Workspace
+	}
+
+	svc.logger.LogInfo("quota set", owner.Scope, owner.ID, principal.Subject())
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: workspace,
+		Action:    AuditQuotaSet,
+		Target:    owner.Scope + ":" + owner.ID,
+		Before:    quotaState(before),
+		After:     quotaState(repository.QuotaLimits{Daily: req.Daily, Total: req.Total}),
+	})
+
+	owner, err = svc.resolve(QuotaScope(owner.Scope), owner.ID)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	quotas, err := svc.usage([]repository.QuotaOwner{owner})
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return quotas[0], nil
+}
+
+func (svc *QuotaService) workspaceOwner(principal Principal, id string) (repository.QuotaOwner, error) {
+	if principal.Workspace != DefaultWorkspace && principal.Workspace != id {
+		return repository.QuotaOwner{}, ErrForbidden
+	}
+
+	if err := svc.access.Authorize(principal, ActionManageQuota); err != nil {
+		return repository.QuotaOwner{}, err
+	}
+
+	if id != DefaultWorkspace {
+		workspace, err := svc.repo.WorkspaceByID(id)
+		if err != nil {
+			return repository.QuotaOwner{}, err
+		}
+
+		if workspace.ID == "" {
+			return repository.QuotaOwner{}, ErrWorkspaceNotFound
+		}
+	}
+
+	return svc.resolve(QuotaScopeWorkspace, id)
+}
+
+func (svc *QuotaService) apiKeyOwner(principal Principal, id string) (repository.QuotaOwner, error) {
+	if err := svc.access.Authorize(principal, ActionManageQuota); err != nil {
+		return repository.QuotaOwner{}, err
+	}
+
+	key, err := svc.repo.APIKey(id)
+	if err != nil {
+		return repository.QuotaOwner{}, err
+	}
+
+	if key.ID == "" || key.Workspace != svc.repo.InWorkspace(principal.Workspace).Workspace() {
+		return repository.QuotaOwner{}, ErrAPIKeyNotFound
+	}
+
+	return svc.resolve(QuotaScopeAPIKey, id)
+}
+
+// owners returns what the links of the principal count against, anonymous
+// links count against the default workspace.
+func (svc *QuotaService) owners(principal Principal) ([]repository.QuotaOwner, error) {
+	workspace, err := svc.resolve(QuotaScopeWorkspace, svc.repo.InWorkspace(principal.Workspace).Workspace())
+	if err != nil {
+		return nil, err
+	}
+
+	owners := []repository.QuotaOwner{workspace}
+
+	if principal.Kind == PrincipalAPIKey {
+		key, err := svc.resolve(QuotaScopeAPIKey, principal.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, key)
+	}
+
+	return owners, nil
+}
+
+// resolve returns the owner with its limits, each limit is the one set
+// through the API, else the one configured for the workspace, else the
+// default.
+func (svc *QuotaService) resolve(scope QuotaScope, id string) (repository.QuotaOwner, error) {
+	stored, err := svc.repo.QuotaLimits(string(scope), id)
+	if err != nil {
+		return repository.QuotaOwner{}, err
+	}
+
+	limits := svc.cfg.APIKey
+	if scope == QuotaScopeWorkspace {
+		limits = svc.cfg.Workspace
+		if configured, ok := svc.cfg.Workspaces[id]; ok {
+			limits = configured
+		}
+	}
+
+	if stored.Daily != nil {
+		limits.Daily = *stored.Daily
+	}
+
+	if stored.Total != nil {
+		limits.Total = *stored.Total
+	}
+
+	return repository.QuotaOwner{Scope: string(scope), ID: id, Daily: limits.Daily, Total: limits.Total}, nil
+}
+
+func (svc *QuotaService) usage(owners []repository.QuotaOwner) (Quotas, error) {
+	now := time.Now().UTC()
+
+	usage, err := svc.repo.QuotaUsage(owners, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return toQuotas(owners, usage, now), nil
+}
+
+func toQuotas(owners []repository.QuotaOwner, usage []repository.QuotaUsage, now time.Time) Quotas {
+	year, month, day := now.Date()
+	resetAt := time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+
+	quotas := make(Quotas, len(owners))
+
+	for i, owner := range owners {
+		quotas[i] = Quota{
+			Scope:   QuotaScope(owner.Scope),
+			ID:      owner.ID,
+			Daily:   toQuotaCounter(owner.Daily, usage[i].Daily),
+			Total:   toQuotaCounter(owner.Total, usage[i].Total),
+			ResetAt: resetAt,
+		}
+	}
+
+	return quotas
+}
+
+func toQuotaCounter(limit, used int64) QuotaCounter {
+	counter := QuotaCounter{Limit: limit, Used: used}
+
+	if limit > 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		counter.Remaining = &remaining
+	}
+
+	return counter
+}
+
+// quotaState is what the audit log keeps of the limits set through the API.
+func quotaState(limits repository.QuotaLimits) map[string]interface{} {
+	state := map[string]interface{}{}
+
+	if limits.Daily != nil {
+		state["daily"] = *limits.Daily
+	}
+
+	if limits.Total != nil {
+		state["total"] = *limits.Total
+	}
+
+	return state
+}
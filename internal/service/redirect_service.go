@@ -1,22 +1,175 @@
 package service
 
 import (
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener/internal/analytics"
 	"url-shortener/internal/logger"
 	"url-shortener/internal/repository"
 )
 
 type RedirectService struct {
-	repo   *repository.RedisRepository
-	logger *logger.Logger
+	repo         *repository.RedisRepository
+	workspaces   *WorkspaceService
+	visitors     analytics.VisitorCounter
+	logger       *logger.Logger
+	interstitial InterstitialConfig
 }
 
-func NewRedirectService(redisRepo *repository.RedisRepository, logger *logger.Logger) *RedirectService {
+type InterstitialConfig struct {
+	Enabled        bool
+	TrustedDomains []string
+}
+
+// Destination is where a short link leads and whether the visitor has to be
+// warned before getting there. Quarantined and disabled links must not be
+// followed.
+type Destination struct {
+	Workspace    string
+	URL          string
+	Interstitial bool
+	Quarantined  bool
+	Disabled     bool
+}
+
+const (
+	SafetyTrusted = "trusted"
+	SafetyWarning = "warning"
+	SafetyBlocked = "blocked"
+)
+
+// Preview describes a short link without following it.
+type Preview struct {
+	Hash        string     `json:"hash"`
+	Destination string     `json:"destination"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Safety      string     `json:"safety"`
+	// Estimated unique visitors since midnight UTC.
+	UniqueVisitorsToday int64 `json:"uniqueVisitorsToday"`
+}
+
+func NewRedirectService(
+	redisRepo *repository.RedisRepository,
+	workspaces *WorkspaceService,
+	visitors analytics.VisitorCounter,
+	logger *logger.Logger,
+	interstitial InterstitialConfig) *RedirectService {
 	return &RedirectService{
-		repo:   redisRepo,
-		logger: logger,
+		repo:         redisRepo,
+		workspaces:   workspaces,
+		visitors:     visitors,
+		logger:       logger,
+		interstitial: interstitial,
+	}
+}
+
+// Redirect resolves the short code requested on the host.
+func (svc *RedirectService) Redirect(host, shortURL string) (Destination, error) {
+	link, err := svc.retrieve(host, shortURL)
+	if err != nil {
+		return Destination{}, err
 	}
+
+	return Destination{
+		Workspace:    link.Workspace,
+		URL:          link.URL,
+		Interstitial: svc.interstitialRequired(link),
+		Quarantined:  link.Quarantine != "",
+		Disabled:     link.Disabled != "",
+	}, nil
 }
 
-func (svc *RedirectService) Redirect(shortURL string) string {
-	return svc.repo.Retrieve(shortURL)
+func (svc *RedirectService) Preview(host, shortURL string) (Preview, error) {
+	link, err := svc.retrieve(host, shortURL)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	preview := Preview{
+		Hash:        link.Hash,
+		Destination: link.URL,
+		Safety:      SafetyTrusted,
+	}
+
+	if !link.CreatedAt.IsZero() {
+		preview.CreatedAt = &link.CreatedAt
+	}
+
+	if !link.ExpiresAt.IsZero() {
+		preview.ExpiresAt = &link.ExpiresAt
+	}
+
+	switch {
+	case link.Quarantine != "", link.Disabled != "":
+		// Don't hand out the destination of a phishing link.
+		preview.Safety = SafetyBlocked
+		preview.Destination = ""
+	case svc.interstitialRequired(link):
+		preview.Safety = SafetyWarning
+	}
+
+	today := repository.BucketStart(time.Now(), repository.IntervalDay)
+
+	_, visitors, err := svc.visitors.Count(link.Workspace, link.Hash, repository.IntervalDay, []time.Time{today})
+	if err != nil {
+		svc.logger.LogError("count unique visitors", err)
+	}
+
+	preview.UniqueVisitorsToday = visitors
+
+	return preview, nil
+}
+
+// retrieve looks the code up in the workspace owning the host. Shared
+// domains serve the links of every workspace.
+func (svc *RedirectService) retrieve(host, shortURL string) (repository.Link, error) {
+	workspace := svc.workspaces.ResolveHost(host)
+
+	if workspace == "" {
+		var err error
+
+		workspace, err = svc.repo.LinkWorkspace(shortURL)
+		if err != nil {
+			return repository.Link{}, err
+		}
+	}
+
+	link, err := svc.repo.InWorkspace(workspace).RetrieveLink(shortURL)
+	if err != nil {
+		return repository.Link{}, err
+	}
+
+	if link.URL == "" {
+		return repository.Link{}, ErrLinkNotFound
+	}
+
+	return link, nil
+}
+
+func (svc *RedirectService) interstitialRequired(link repository.Link) bool {
+	return link.Interstitial || svc.untrusted(link.URL)
+}
+
+func (svc *RedirectService) untrusted(destination string) bool {
+	if !svc.interstitial.Enabled {
+		return false
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	for _, domain := range svc.interstitial.TrustedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+
+	return true
 }
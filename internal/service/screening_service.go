@@ -0,0 +1,473 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	DefaultScreeningReloadInterval = time.Minute
+
+	rescreenPageSize = 500
+)
+
+// screeningPrincipal is who the audit log records quarantines by.
+var screeningPrincipal = Principal{Kind: PrincipalSystem, ID: "screening"}
+
+const (
+	// ReasonBlocklisted destinations match an entry of the blocklist.
+	ReasonBlocklisted = "blocklisted"
+	// ReasonRedirectLoop destinations point back at one of our short
+	// domains.
+	ReasonRedirectLoop = "redirect_loop"
+)
+
+type ScreeningConfig struct {
+	// Files with one domain or /regular expression/ per line, # starts a
+	// comment. Domains match their subdomains too, expressions match the
+	// whole URL. Allowlisted destinations skip the blocklist.
+	BlocklistFile  string
+	AllowlistFile  string
+	ReloadInterval time.Duration
+	// Existing links are screened again this often and whenever a list
+	// changes, zero only screens links as they are created and updated.
+	RescreenInterval time.Duration
+	// Hosts the short links are served on, links to them are rejected.
+	ShortDomains []string
+}
+
+// ScreeningError tells why a destination was refused, it matches
+// ErrDestinationBlocked.
+type ScreeningError struct {
+	Reason string `json:"reason"`
+}
+
+func (e *ScreeningError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDestinationBlocked, e.Reason)
+}
+
+func (e *ScreeningError) Is(target error) bool {
+	return target == ErrDestinationBlocked
+}
+
+// ScreeningService keeps the shortener from relaying to malicious or
+// looping destinations. The lists are re-read when they change on disk and
+// existing links are screened again in the background, matching links are
+// quarantined and released once they stop matching.
+type ScreeningService struct {
+	cfg          ScreeningConfig
+	repo         *repository.RedisRepository
+	workspaces   *WorkspaceService
+	audit        *AuditLog
+	logger       *logger.Logger
+	blocklist    *screeningFile
+	allowlist    *screeningFile
+	shortDomains map[string]struct{}
+	mu           sync.Mutex
+	checkedAt    time.Time
+	// A list changed since the links were last screened.
+	changed bool
+	stop    chan struct{}
+}
+
+type screeningFile struct {
+	name    string
+	path    string
+	list    atomic.Value
+	modTime time.Time
+}
+
+type screeningList struct {
+	domains  map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+func NewScreeningService(
+	cfg ScreeningConfig,
+	redisRepo *repository.RedisRepository,
+	workspaces *WorkspaceService,
+	audit *AuditLog,
+	logger *logger.Logger) *ScreeningService {
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultScreeningReloadInterval
+	}
+
+	svc := &ScreeningService{
+		cfg:          cfg,
+		repo:         redisRepo,
+		workspaces:   workspaces,
+		audit:        audit,
+		logger:       logger,
+		blocklist:    newScreeningFile("blocklist", cfg.BlocklistFile),
+		allowlist:    newScreeningFile("allowlist", cfg.AllowlistFile),
+		shortDomains: map[string]struct{}{},
+		stop:         make(chan struct{}),
+	}
+
+	for _, domain := range cfg.ShortDomains {
+		if host := hostOf(domain); host != "" {
+			svc.shortDomains[host] = struct{}{}
+		}
+	}
+
+	svc.reload(time.Now())
+
+	return svc
+}
+
+// Screen checks a destination before it is stored.
+func (svc *ScreeningService) Screen(destination string) error {
+	u, err := url.Parse(destination)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || normalizeHost(u.Hostname()) == "" {
+		return fmt.Errorf("%w: destination must be an absolute http or https URL", ErrInvalidRequest)
+	}
+
+	svc.maybeReload()
+
+	if reason := svc.verdict(u); reason != "" {
+		return &ScreeningError{Reason: reason}
+	}
+
+	return nil
+}
+
+// Run screens the existing links again every RescreenInterval, and soon
+// after one of the lists changes. Links stored before workspaces existed are
+// indexed first and screened right away.
+func (svc *ScreeningService) Run() error {
+	if svc.cfg.RescreenInterval <= 0 {
+		<-svc.stop
+
+		return nil
+	}
+
+	legacy, err := svc.repo.InWorkspace(repository.DefaultWorkspace).IndexLegacyLinks()
+	if err != nil {
+		svc.logger.LogError("index legacy links", err)
+	}
+
+	if legacy > 0 {
+		svc.logger.LogInfo("legacy links indexed", legacy)
+		svc.rescreen(time.Now())
+	}
+
+	ticker := time.NewTicker(svc.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	rescreenedAt := time.Now()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if svc.reload(now) || now.Sub(rescreenedAt) >= svc.cfg.RescreenInterval {
+				svc.rescreen(now)
+				rescreenedAt = now
+			}
+		case <-svc.stop:
+			return nil
+		}
+	}
+}
+
+func (svc *ScreeningService) Stop() {
+	select {
+	case <-svc.stop:
+	default:
+		close(svc.stop)
+	}
+}
+
+// verdict returns why the destination is refused, empty when it is not.
+func (svc *ScreeningService) verdict(u *url.URL) string {
+	host := normalizeHost(u.Hostname())
+
+	if _, ok := svc.shortDomains[host]; ok || svc.workspaces.ResolveHost(host) != "" {
+		return ReasonRedirectLoop
+	}
+
+	// Patterns see the destination with its normalized host.
+	normalized := *u
+	normalized.Host = host
+	if port := u.Port(); port != "" {
+		normalized.Host = net.JoinHostPort(host, port)
+	}
+
+	destination := normalized.String()
+
+	if svc.allowlist.load().matches(host, destination) {
+		return ""
+	}
+
+	if svc.blocklist.load().matches(host, destination) {
+		return ReasonBlocklisted
+	}
+
+	return ""
+}
+
+func (svc *ScreeningService) rescreen(now time.Time) {
+	workspaces, err := svc.repo.Workspaces()
+	if err != nil {
+		svc.logger.LogError("list workspaces", err)
+	}
+
+	repos := []*repository.RedisRepository{svc.repo.InWorkspace(repository.DefaultWorkspace)}
+	for _, workspace := range workspaces {
+		repos = append(repos, svc.repo.InWorkspace(workspace.ID))
+	}
+
+	svc.mu.Lock()
+	svc.changed = false
+	svc.mu.Unlock()
+
+	quarantined, released := 0, 0
+
+	for _, repo := range repos {
+		// Pages come back short when they hold expired links, so page
+		// through the whole index.
+		count, errCount := repo.LinkCount()
+		if errCount != nil {
+			svc.logger.LogError("rescreen links", errCount)
+
+			continue
+		}
+
+		for offset := int64(0); offset < count; offset += rescreenPageSize {
+			links, errLinks := repo.Links(offset, rescreenPageSize)
+			if errLinks != nil {
+				svc.logger.LogError("rescreen links", errLinks)
+
+				break
+			}
+
+			for _, link := range links {
+				q, r := svc.rescreenLink(repo, link, now)
+				quarantined += q
+				released += r
+			}
+		}
+	}
+
+	if quarantined > 0 || released > 0 {
+		svc.logger.LogInfo("links rescreened", quarantined, released)
+	}
+}
+
+// rescreenLink returns how many links it quarantined and released.
+// Destinations that do not parse were accepted before screening existed and
+// are left alone.
+func (svc *ScreeningService) rescreenLink(
+	repo *repository.RedisRepository,
+	link repository.Link,
+	now time.Time) (int, int) {
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return 0, 0
+	}
+
+	reason := svc.verdict(u)
+
+	switch {
+	case reason != "" && link.Quarantine == "":
+		if err = repo.QuarantineLink(link.Hash, reason, now); err != nil {
+			svc.logger.LogError("quarantine link", err)
+
+			return 0, 0
+		}
+
+		svc.logger.LogInfo("link quarantined", repo.Workspace(), link.Hash, reason)
+		svc.audit.Record(screeningPrincipal, AuditEntry{
+			Workspace: repo.Workspace(),
+			Action:    AuditLinkQuarantine,
+			Code:      link.Hash,
+			After:     map[string]interface{}{"quarantine": reason},
+		})
+
+		return 1, 0
+	case reason == "" && link.Quarantine != "":
+		if err = repo.ReleaseLink(link.Hash); err != nil {
+			svc.logger.LogError("release link", err)
+
+			return 0, 0
+		}
+
+		svc.logger.LogInfo("link released", repo.Workspace(), link.Hash)
+		svc.audit.Record(screeningPrincipal, AuditEntry{
+			Workspace: repo.Workspace(),
+			Action:    AuditLinkRelease,
+			Code:      link.Hash,
+			Before:    map[string]interface{}{"quarantine": link.Quarantine},
+		})
+
+		return 0, 1
+	default:
+		return 0, 0
+	}
+}
+
+func (svc *ScreeningService) maybeReload() {
+	now := time.Now()
+
+	svc.mu.Lock()
+	stale := now.Sub(svc.checkedAt) >= svc.cfg.ReloadInterval
+	svc.mu.Unlock()
+
+	if stale {
+		svc.reload(now)
+	}
+}
+
+// reload reports whether a list changed since the last rescreen, including
+// changes picked up while screening new links.
+func (svc *ScreeningService) reload(now time.Time) bool {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	svc.checkedAt = now
+
+	blocklistChanged := svc.blocklist.reload(svc.logger)
+	allowlistChanged := svc.allowlist.reload(svc.logger)
+	svc.changed = svc.changed || blocklistChanged || allowlistChanged
+
+	return svc.changed
+}
+
+func newScreeningFile(name, path string) *screeningFile {
+	f := &screeningFile{name: name, path: path}
+	f.list.Store(screeningList{})
+
+	return f
+}
+
+func (f *screeningFile) load() screeningList {
+	return f.list.Load().(screeningList)
+}
+
+// reload re-reads the file when it changed, a file that fails to load keeps
+// the previous list.
+func (f *screeningFile) reload(logger *logger.Logger) bool {
+	if f.path == "" {
+		return false
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		logger.LogError("stat "+f.name, err)
+
+		return false
+	}
+
+	if !info.ModTime().After(f.modTime) {
+		return false
+	}
+
+	list, err := readScreeningList(f.path)
+	if err != nil {
+		logger.LogError("read "+f.name, err)
+
+		return false
+	}
+
+	f.modTime = info.ModTime()
+	f.list.Store(list)
+	logger.LogInfo(f.name+" loaded", len(list.domains), len(list.patterns))
+
+	return true
+}
+
+func (l screeningList) matches(host, destination string) bool {
+	for domain := host; domain != ""; {
+		if _, ok := l.domains[domain]; ok {
+			return true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+
+		domain = parent
+	}
+
+	for _, pattern := range l.patterns {
+		if pattern.MatchString(destination) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readScreeningList(path string) (screeningList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return screeningList{}, err
+	}
+	defer file.Close()
+
+	list := screeningList{domains: map[string]struct{}{}}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			pattern, errCompile := regexp.Compile(line[1 : len(line)-1])
+			if errCompile != nil {
+				return screeningList{}, fmt.Errorf("pattern %q: %w", line, errCompile)
+			}
+
+			list.patterns = append(list.patterns, pattern)
+
+			continue
+		}
+
+		list.domains[normalizeHost(line)] = struct{}{}
+	}
+
+	return list, scanner.Err()
+}
+
+// hostOf returns the normalized host of a URL or host:port, the base URL may
+// be given either way.
+func hostOf(value string) string {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+
+	return normalizeHost(u.Hostname())
+}
+
+// normalizeHost lowercases the host, drops the trailing dot of fully
+// qualified names and turns internationalized names into their ASCII form,
+// so that every spelling browsers resolve to the same host compares equal.
+// Hosts that are not valid IDNA are only lowercased.
+func normalizeHost(host string) string {
+	host = strings.TrimRight(strings.ToLower(host), ".")
+
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+
+	return host
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/redistest"
+	"url-shortener/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+func writeScreeningList(t *testing.T, lines string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadScreeningList(t *testing.T) {
+	list, err := readScreeningList(writeScreeningList(t, `
+# phishing kits
+Evil.COM
+  spaced.example
+fqdn.example.
+bücher.example
+/^https?://[^/]+/wp-admin//
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, domain := range []string{"evil.com", "spaced.example", "fqdn.example", "xn--bcher-kva.example"} {
+		if _, ok := list.domains[domain]; !ok {
+			t.Errorf("domain %s missing from %v", domain, list.domains)
+		}
+	}
+
+	if len(list.domains) != 4 || len(list.patterns) != 1 {
+		t.Errorf("got %d domains and %d patterns, want 4 and 1", len(list.domains), len(list.patterns))
+	}
+
+	if _, err = readScreeningList(writeScreeningList(t, "/[unclosed/\n")); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestScreeningListMatches(t *testing.T) {
+	list, err := readScreeningList(writeScreeningList(t, "evil.com\n/\\.exe$/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host        string
+		destination string
+		want        bool
+	}{
+		{host: "evil.com", destination: "https://evil.com/", want: true},
+		{host: "login.evil.com", destination: "https://login.evil.com/", want: true},
+		{host: "notevil.com", destination: "https://notevil.com/"},
+		{host: "evil.com.example", destination: "https://evil.com.example/"},
+		{host: "example.com", destination: "https://example.com/setup.exe", want: true},
+		{host: "example.com", destination: "https://example.com/setup.exe.html"},
+	}
+
+	for _, tt := range tests {
+		if got := list.matches(tt.host, tt.destination); got != tt.want {
+			t.Errorf("matches(%s, %s) = %v, want %v", tt.host, tt.destination, got, tt.want)
+		}
+	}
+}
+
+func TestScreeningVerdict(t *testing.T) {
+	conn, _ := redistest.NewClient(t)
+	repo := repository.NewRedisRepository(conn)
+	log := logger.NewLogger(zap.NewNop())
+	workspaces := NewWorkspaceService(repo, NewAuditLog(AuditConfig{}, repo, log), log)
+
+	_, err := workspaces.Create(Principal{Kind: PrincipalAPIKey, Workspace: DefaultWorkspace}, CreateWorkspaceRequest{
+		ID:      "acme",
+		Name:    "Acme",
+		Domains: []string{"go.acme.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewScreeningService(ScreeningConfig{
+		BlocklistFile: writeScreeningList(t, "evil.com\nxn--bcher-kva.example\n/^https://[^/]+/phish/\n"),
+		AllowlistFile: writeScreeningList(t, "safe.evil.com\n"),
+		ShortDomains:  []string{"https://Short.Example/"},
+	}, repo, workspaces, nil, log)
+
+	tests := []struct {
+		destination string
+		want        string
+	}{
+		{destination: "https://example.com/", want: ""},
+		{destination: "https://evil.com/", want: ReasonBlocklisted},
+		{destination: "https://EVIL.com/", want: ReasonBlocklisted},
+		{destination: "https://evil.com./", want: ReasonBlocklisted},
+		{destination: "https://login.evil.com.:8443/", want: ReasonBlocklisted},
+		{destination: "https://bücher.example/", want: ReasonBlocklisted},
+		{destination: "https://BÜCHER.example./", want: ReasonBlocklisted},
+		{destination: "https://example.com./phish/", want: ReasonBlocklisted},
+		{destination: "https://safe.evil.com./", want: ""},
+		{destination: "https://short.example/abc123", want: ReasonRedirectLoop},
+		{destination: "https://short.example./abc123", want: ReasonRedirectLoop},
+		{destination: "https://go.acme.example./abc123", want: ReasonRedirectLoop},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := svc.verdict(u); got != tt.want {
+			t.Errorf("verdict(%s) = %q, want %q", tt.destination, got, tt.want)
+		}
+	}
+
+	if err := svc.Screen("https://./"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("destination without a host: err = %v, want %v", err, ErrInvalidRequest)
+	}
+
+	if err := svc.Screen("https://evil.com./"); !errors.Is(err, ErrDestinationBlocked) {
+		t.Errorf("blocklisted destination: err = %v, want %v", err, ErrDestinationBlocked)
+	}
+}
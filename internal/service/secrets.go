@@ -0,0 +1,38 @@
+package service
+
+import (
+	"encoding/hex"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const generatedSecretBytes = 32
+
+// SharedSecret returns the configured secret, or when it is empty a random
+// one generated once and kept in Redis, so every instance uses the same one
+// and it survives restarts.
+func SharedSecret(
+	name string,
+	configured string,
+	redisRepo *repository.RedisRepository,
+	logger *logger.Logger) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	candidate, err := randomString(generatedSecretBytes, hex.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := redisRepo.Secret(name, candidate)
+	if err != nil {
+		return "", err
+	}
+
+	if secret == candidate {
+		logger.LogInfo("no secret configured, generated one and stored it in redis", name)
+	}
+
+	return secret, nil
+}
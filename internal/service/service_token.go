@@ -0,0 +1,88 @@
+package service
+
+import (
+	"crypto/subtle"
+	"url-shortener/internal/repository"
+)
+
+// Service token names, the ID of their principals.
+const (
+	ServiceTokenStream  = "stream"
+	ServiceTokenErasure = "erasure"
+)
+
+type ServiceTokenConfig struct {
+	// Opens the live click streams, disabled when empty.
+	StreamToken string
+	// Erases link analytics, disabled when empty.
+	ErasureToken string
+}
+
+// ServiceTokenAuthenticator checks the static tokens of the configuration,
+// meant for jobs that predate API keys. Each token acts in the default
+// workspace and only for the one action it was set for.
+type ServiceTokenAuthenticator struct {
+	tokens map[string]Principal
+}
+
+func NewServiceTokenAuthenticator(cfg ServiceTokenConfig) *ServiceTokenAuthenticator {
+	tokens := make(map[string]Principal, 2)
+
+	if cfg.StreamToken != "" {
+		tokens[hashAPIKey(cfg.StreamToken)] = Principal{
+			Kind:      PrincipalServiceToken,
+			ID:        ServiceTokenStream,
+			Workspace: repository.DefaultWorkspace,
+			Scopes:    []Scope{ScopeRead},
+			Actions:   []Action{ActionStreamEvents},
+		}
+	}
+
+	if cfg.ErasureToken != "" {
+		tokens[hashAPIKey(cfg.ErasureToken)] = Principal{
+			Kind:      PrincipalServiceToken,
+			ID:        ServiceTokenErasure,
+			Workspace: repository.DefaultWorkspace,
+			Scopes:    []Scope{ScopeManage},
+			Actions:   []Action{ActionEraseAnalytics},
+		}
+	}
+
+	return &ServiceTokenAuthenticator{tokens: tokens}
+}
+
+// Accepts reports whether the bearer token is one of the service tokens.
+// They have no format of their own, so this is the check itself.
+func (a *ServiceTokenAuthenticator) Accepts(token string) bool {
+	_, ok := a.principal(token)
+
+	return ok
+}
+
+func (a *ServiceTokenAuthenticator) Authenticate(token string) (Principal, error) {
+	principal, ok := a.principal(token)
+	if !ok {
+		return Principal{}, ErrUnauthorized
+	}
+
+	return principal, nil
+}
+
+// principal compares hashes in constant time, so neither the tokens nor
+// their lengths leak through timing.
+func (a *ServiceTokenAuthenticator) principal(token string) (Principal, bool) {
+	hash := []byte(hashAPIKey(token))
+
+	var (
+		found   Principal
+		matched bool
+	)
+
+	for stored, principal := range a.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(stored)) == 1 {
+			found, matched = principal, true
+		}
+	}
+
+	return found, matched
+}
@@ -1,43 +1,293 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"time"
 	"url-shortener/internal/logger"
 	"url-shortener/internal/repository"
 )
 
+const (
+	DefaultLinksLimit = 50
+	MaxLinksLimit     = 100
+)
+
 type URLShortener struct {
 	hashService *HashService
 	repo        *repository.RedisRepository
+	workspaces  *WorkspaceService
+	access      *AccessService
+	screening   *ScreeningService
+	quotas      *QuotaService
+	codes       *CodeSigner
+	audit       *AuditLog
 	logger      *logger.Logger
 	baseUrl     string
 }
 
-func NewURLShortenerService(hashService *HashService, redisRepo *repository.RedisRepository, logger *logger.Logger, baseUrl string) *URLShortener {
+func NewURLShortenerService(
+	hashService *HashService,
+	redisRepo *repository.RedisRepository,
+	workspaces *WorkspaceService,
+	access *AccessService,
+	screening *ScreeningService,
+	quotas *QuotaService,
+	codes *CodeSigner,
+	audit *AuditLog,
+	logger *logger.Logger,
+	baseUrl string) *URLShortener {
 	return &URLShortener{
 		hashService: hashService,
 		repo:        redisRepo,
+		workspaces:  workspaces,
+		access:      access,
+		screening:   screening,
+		quotas:      quotas,
+		codes:       codes,
+		audit:       audit,
 		logger:      logger,
 		baseUrl:     baseUrl,
 	}
 }
 
-func (svc *URLShortener) Create(req *Request) (Response, error) {
-	hash := svc.createHash()
+// Create stores the link in the workspace of the principal, which becomes
+// its owner. Anonymous principals create unowned links in the default
+// workspace. The destination is screened first, then the link is counted
+// against the quotas of the principal. The short URL uses the workspace's
+// own domain when it has one and a signed code when signing is enabled.
+// Anonymous links come with a manage token.
+func (svc *URLShortener) Create(principal Principal, req *Request) (Response, error) {
+	if principal.Kind != "" {
+		if err := svc.access.Authorize(principal, ActionCreateLink); err != nil {
+			return Response{}, err
+		}
+	}
 
-	err := svc.store(hash, req.URL)
+	if err := svc.screen(principal, req.URL); err != nil {
+		return Response{}, err
+	}
+
+	quotas, err := svc.quotas.Take(principal)
 	if err != nil {
 		return Response{}, err
 	}
 
-	return Response{ShortURL: svc.baseUrl + "/" + hash}, nil
+	link := repository.Link{
+		Hash:         svc.createHash(),
+		URL:          req.URL,
+		CreatedAt:    time.Now().UTC(),
+		Interstitial: req.Interstitial,
+		Owner:        principal.Subject(),
+	}
+
+	var manageToken string
+
+	if principal.Kind == "" {
+		if manageToken, link.ManageToken, err = newManageToken(link.Hash); err != nil {
+			svc.quotas.Release(quotas)
+
+			return Response{}, err
+		}
+	}
+
+	if err = svc.store(principal.Workspace, link); err != nil {
+		svc.quotas.Release(quotas)
+
+		return Response{}, err
+	}
+
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditLinkCreate,
+		Code:   link.Hash,
+		After:  linkState(link),
+	})
+
+	return Response{
+		ShortURL:    svc.shortURL(svc.workspaceBaseURL(principal.Workspace), link.Hash),
+		ManageToken: manageToken,
+		Quotas:      quotas,
+	}, nil
+}
+
+// Links lists the newest links of the workspace.
+func (svc *URLShortener) Links(req LinksRequest) (Links, error) {
+	if err := svc.access.Authorize(req.Principal, ActionListLinks); err != nil {
+		return Links{}, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultLinksLimit
+	}
+
+	if limit > MaxLinksLimit {
+		limit = MaxLinksLimit
+	}
+
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	stored, err := svc.repo.InWorkspace(req.Principal.Workspace).Links(offset, limit)
+	if err != nil {
+		return Links{}, err
+	}
+
+	links := Links{Links: make([]LinkSummary, len(stored))}
+	baseUrl := svc.workspaceBaseURL(req.Principal.Workspace)
+
+	for i, link := range stored {
+		links.Links[i] = svc.toLinkSummary(baseUrl, link)
+	}
+
+	return links, nil
+}
+
+// Update changes the destination or the interstitial flag of a link, it
+// needs the editor role on the link.
+func (svc *URLShortener) Update(principal Principal, code string, req UpdateRequest) (LinkSummary, error) {
+	repo, link, err := svc.access.link(principal, code, ActionEditLink)
+	if err != nil {
+		return LinkSummary{}, err
+	}
+
+	before := linkState(link)
+
+	if req.URL != nil {
+		if err = svc.screen(principal, *req.URL); err != nil {
+			return LinkSummary{}, err
+		}
+
+		link.URL = *req.URL
+		link.Quarantine = ""
+	}
+
+	if req.Interstitial != nil {
+		link.Interstitial = *req.Interstitial
+	}
+
+	if err = repo.UpdateLink(link); err != nil {
+		return LinkSummary{}, err
+	}
+
+	svc.logger.LogInfo("link updated", repo.Workspace(), code, principal.Subject())
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: repo.Workspace(),
+		Action:    AuditLinkUpdate,
+		Code:      code,
+		Before:    before,
+		After:     linkState(link),
+	})
+
+	return svc.toLinkSummary(svc.workspaceBaseURL(repo.Workspace()), link), nil
+}
+
+// Delete removes a link, it needs the owner role on the link. Its analytics
+// are kept until they expire or are erased.
+func (svc *URLShortener) Delete(principal Principal, code string) error {
+	repo, link, err := svc.access.link(principal, code, ActionDeleteLink)
+	if err != nil {
+		return err
+	}
+
+	if err = repo.DeleteLink(code); err != nil {
+		return err
+	}
+
+	svc.logger.LogInfo("link deleted", repo.Workspace(), code, principal.Subject())
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: repo.Workspace(),
+		Action:    AuditLinkDelete,
+		Code:      code,
+		Before:    linkState(link),
+	})
+
+	return nil
+}
+
+// Claim makes the principal the owner of an anonymous link of its
+// workspace, proven by the link's manage token. The token stops working.
+func (svc *URLShortener) Claim(principal Principal, code string, req ClaimRequest) (LinkSummary, error) {
+	if principal.Kind != PrincipalUser && principal.Kind != PrincipalAPIKey {
+		return LinkSummary{}, ErrForbidden
+	}
+
+	if err := svc.access.Authorize(principal, ActionCreateLink); err != nil {
+		return LinkSummary{}, err
+	}
+
+	repo := svc.repo.InWorkspace(principal.Workspace)
+
+	link, err := repo.RetrieveLink(code)
+	if err != nil {
+		return LinkSummary{}, err
+	}
+
+	if link.URL == "" {
+		return LinkSummary{}, ErrLinkNotFound
+	}
+
+	if !checkManageToken(link, req.Token) {
+		return LinkSummary{}, fmt.Errorf("%w: invalid manage token", ErrForbidden)
+	}
+
+	before := linkState(link)
+	link.Owner = principal.Subject()
+
+	if err = repo.ClaimLink(code, link.Owner); err != nil {
+		return LinkSummary{}, err
+	}
+
+	svc.logger.LogInfo("link claimed", repo.Workspace(), code, link.Owner)
+	svc.audit.Record(principal, AuditEntry{
+		Workspace: repo.Workspace(),
+		Action:    AuditLinkClaim,
+		Code:      code,
+		Before:    before,
+		After:     linkState(link),
+	})
+
+	return svc.toLinkSummary(svc.workspaceBaseURL(repo.Workspace()), link), nil
+}
+
+// workspaceBaseURL returns the base URL short links of the workspace are
+// shared on, its own domain when it has one.
+func (svc *URLShortener) workspaceBaseURL(workspace string) string {
+	baseUrl, err := svc.workspaces.ShortDomain(workspace)
+	if err != nil {
+		svc.logger.LogError("workspace short domain", err)
+	}
+
+	if baseUrl == "" {
+		baseUrl = svc.baseUrl
+	}
+
+	return baseUrl
+}
+
+// shortURL signs the code when signing is enabled, unsigned codes may be
+// refused.
+func (svc *URLShortener) shortURL(baseUrl, code string) string {
+	return baseUrl + "/" + svc.codes.Sign(code)
+}
+
+func (svc *URLShortener) screen(principal Principal, destination string) error {
+	err := svc.screening.Screen(destination)
+	if errors.Is(err, ErrDestinationBlocked) {
+		svc.logger.LogInfo("destination blocked", principal.Workspace, principal.Subject(), destination, err.Error())
+	}
+
+	return err
 }
 
 func (svc *URLShortener) createHash() string {
 	return svc.hashService.getHash()
 }
 
-func (svc *URLShortener) store(hash, originUrl string) error {
-	err := svc.repo.Store(hash, originUrl)
+func (svc *URLShortener) store(workspace string, link repository.Link) error {
+	err := svc.repo.InWorkspace(workspace).Store(link)
 	if err != nil {
 		return err
 	}
@@ -47,8 +297,61 @@ func (svc *URLShortener) store(hash, originUrl string) error {
 
 type Request struct {
 	URL string `json:"url"`
+	// Always show the warning page before following this link.
+	Interstitial bool `json:"interstitial"`
 }
 
 type Response struct {
 	ShortURL string `json:"shortURL"`
+	// Updates, deletes and reads the stats of an anonymous link, it is only
+	// ever shown once.
+	ManageToken string `json:"manageToken,omitempty"`
+	// The quotas the link was counted against.
+	Quotas Quotas `json:"-"`
+}
+
+type ClaimRequest struct {
+	Token string `json:"token"`
+}
+
+// UpdateRequest changes the fields that are set.
+type UpdateRequest struct {
+	URL          *string `json:"url"`
+	Interstitial *bool   `json:"interstitial"`
+}
+
+type LinksRequest struct {
+	Principal Principal
+	Offset    int64
+	Limit     int64
+}
+
+type LinkSummary struct {
+	Code string `json:"code"`
+	// Short URL the link is shared on, with its signature when signing is
+	// enabled.
+	ShortURL     string    `json:"shortURL"`
+	Destination  string    `json:"destination"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Interstitial bool      `json:"interstitial"`
+	Owner        string    `json:"owner,omitempty"`
+	Quarantine   string    `json:"quarantine,omitempty"`
+	Disabled     string    `json:"disabled,omitempty"`
+}
+
+type Links struct {
+	Links []LinkSummary `json:"links"`
+}
+
+func (svc *URLShortener) toLinkSummary(baseUrl string, link repository.Link) LinkSummary {
+	return LinkSummary{
+		Code:         link.Hash,
+		ShortURL:     svc.shortURL(baseUrl, link.Hash),
+		Destination:  link.URL,
+		CreatedAt:    link.CreatedAt,
+		Interstitial: link.Interstitial,
+		Owner:        link.Owner,
+		Quarantine:   link.Quarantine,
+		Disabled:     link.Disabled,
+	}
 }
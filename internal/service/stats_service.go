@@ -0,0 +1,248 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"url-shortener/internal/analytics"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultHourlyStatsRange = 24 * time.Hour
+	DefaultDailyStatsRange  = 30 * 24 * time.Hour
+
+	MaxHourlyBuckets = 24 * 31
+	MaxDailyBuckets  = 366
+
+	DefaultTopLinksLimit = 10
+	MaxTopLinksLimit     = 100
+)
+
+type StatsService struct {
+	repo     *repository.RedisRepository
+	visitors analytics.VisitorCounter
+	access   *AccessService
+	logger   *logger.Logger
+}
+
+type StatsRequest struct {
+	Principal Principal
+	Code      string
+	From      time.Time
+	To        time.Time
+	Interval  string
+}
+
+type StatsBucket struct {
+	Start          time.Time `json:"start"`
+	Clicks         int64     `json:"clicks"`
+	UniqueVisitors int64     `json:"uniqueVisitors"`
+}
+
+type LinkStats struct {
+	Code           string           `json:"code"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	Interval       string           `json:"interval"`
+	TotalClicks    int64            `json:"totalClicks"`
+	BotClicks      int64            `json:"botClicks"`
+	UniqueVisitors int64            `json:"uniqueVisitors"`
+	Referrers      map[string]int64 `json:"referrers"`
+	Countries      map[string]int64 `json:"countries"`
+	Devices        map[string]int64 `json:"devices"`
+	Browsers       map[string]int64 `json:"browsers"`
+	Buckets        []StatsBucket    `json:"buckets"`
+}
+
+type TopLinksRequest struct {
+	Principal Principal
+	Window    string
+	Limit     int64
+}
+
+type TopLink struct {
+	Code        string `json:"code"`
+	Destination string `json:"destination"`
+	Clicks      int64  `json:"clicks"`
+}
+
+type TopLinks struct {
+	Window string    `json:"window"`
+	Links  []TopLink `json:"links"`
+}
+
+func NewStatsService(
+	redisRepo *repository.RedisRepository,
+	visitors analytics.VisitorCounter,
+	access *AccessService,
+	logger *logger.Logger) *StatsService {
+	return &StatsService{
+		repo:     redisRepo,
+		visitors: visitors,
+		access:   access,
+		logger:   logger,
+	}
+}
+
+func (svc *StatsService) LinkStats(req StatsRequest) (LinkStats, error) {
+	repo, _, err := svc.access.link(req.Principal, req.Code, ActionViewLink)
+	if err != nil {
+		return LinkStats{}, err
+	}
+
+	interval, starts, err := statsBuckets(req)
+	if err != nil {
+		return LinkStats{}, err
+	}
+
+	buckets, err := repo.LinkStats(req.Code, interval, starts)
+	if err != nil {
+		return LinkStats{}, err
+	}
+
+	visitors, unique, err := svc.visitors.Count(repo.Workspace(), req.Code, interval, starts)
+	if err != nil {
+		return LinkStats{}, err
+	}
+
+	stats := LinkStats{
+		Code:           req.Code,
+		From:           starts[0],
+		To:             starts[len(starts)-1].Add(bucketDuration(interval)),
+		Interval:       string(interval),
+		UniqueVisitors: unique,
+		Referrers:      map[string]int64{},
+		Countries:      map[string]int64{},
+		Devices:        map[string]int64{},
+		Browsers:       map[string]int64{},
+		Buckets:        make([]StatsBucket, 0, len(buckets)),
+	}
+
+	for i, bucket := range buckets {
+		clicks := bucket.Counters[repository.FieldTotal]
+		stats.TotalClicks += clicks
+		stats.BotClicks += bucket.Counters[repository.FieldBots]
+
+		stats.Buckets = append(stats.Buckets, StatsBucket{
+			Start:          bucket.Start,
+			Clicks:         clicks,
+			UniqueVisitors: visitors[i],
+		})
+
+		for field, count := range bucket.Counters {
+			switch {
+			case strings.HasPrefix(field, repository.FieldReferrer):
+				stats.Referrers[strings.TrimPrefix(field, repository.FieldReferrer)] += count
+			case strings.HasPrefix(field, repository.FieldCountry):
+				stats.Countries[strings.TrimPrefix(field, repository.FieldCountry)] += count
+			case strings.HasPrefix(field, repository.FieldDevice):
+				stats.Devices[strings.TrimPrefix(field, repository.FieldDevice)] += count
+			case strings.HasPrefix(field, repository.FieldBrowser):
+				stats.Browsers[strings.TrimPrefix(field, repository.FieldBrowser)] += count
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func (svc *StatsService) TopLinks(req TopLinksRequest) (TopLinks, error) {
+	if err := svc.access.Authorize(req.Principal, ActionListLinks); err != nil {
+		return TopLinks{}, err
+	}
+
+	window := repository.LeaderboardWindow(req.Window)
+	if window == "" {
+		window = repository.WindowDay
+	}
+
+	if !repository.ValidLeaderboardWindow(window) {
+		return TopLinks{}, fmt.Errorf("%w: unknown window %q", ErrInvalidRequest, req.Window)
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultTopLinksLimit
+	}
+
+	if limit > MaxTopLinksLimit {
+		limit = MaxTopLinksLimit
+	}
+
+	entries, err := svc.repo.InWorkspace(req.Principal.Workspace).TopLinks(window, limit)
+	if err != nil {
+		return TopLinks{}, err
+	}
+
+	top := TopLinks{
+		Window: string(window),
+		Links:  make([]TopLink, len(entries)),
+	}
+
+	for i, entry := range entries {
+		top.Links[i] = TopLink{
+			Code:        entry.Code,
+			Destination: entry.Destination,
+			Clicks:      entry.Clicks,
+		}
+	}
+
+	return top, nil
+}
+
+// statsBuckets validates the requested range and returns the start of every
+// bucket inside it.
+func statsBuckets(req StatsRequest) (repository.StatsInterval, []time.Time, error) {
+	interval := repository.StatsInterval(req.Interval)
+	defaultRange := DefaultHourlyStatsRange
+	maxBuckets := MaxHourlyBuckets
+
+	switch interval {
+	case "", repository.IntervalHour:
+		interval = repository.IntervalHour
+	case repository.IntervalDay:
+		defaultRange = DefaultDailyStatsRange
+		maxBuckets = MaxDailyBuckets
+	default:
+		return "", nil, fmt.Errorf("%w: unknown interval %q", ErrInvalidRequest, req.Interval)
+	}
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	from := req.From
+	if from.IsZero() {
+		from = to.Add(-defaultRange)
+	}
+
+	if from.After(to) {
+		return "", nil, fmt.Errorf("%w: from is after to", ErrInvalidRequest)
+	}
+
+	step := bucketDuration(interval)
+	first := repository.BucketStart(from, interval)
+	last := repository.BucketStart(to, interval)
+
+	if int(last.Sub(first)/step)+1 > maxBuckets {
+		return "", nil, fmt.Errorf("%w: range exceeds %d %s buckets", ErrInvalidRequest, maxBuckets, interval)
+	}
+
+	var starts []time.Time
+	for start := first; !start.After(last); start = start.Add(step) {
+		starts = append(starts, start)
+	}
+
+	return interval, starts, nil
+}
+
+func bucketDuration(interval repository.StatsInterval) time.Duration {
+	if interval == repository.IntervalDay {
+		return 24 * time.Hour
+	}
+
+	return time.Hour
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/repository"
+)
+
+const (
+	DefaultWorkspace = repository.DefaultWorkspace
+
+	// Dedicated domains added by other instances are seen after this long.
+	DefaultDomainRefreshInterval = 30 * time.Second
+)
+
+var workspaceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+// WorkspaceService manages workspaces and tells which one a short domain
+// belongs to. Only admins of the default workspace manage workspaces.
+type WorkspaceService struct {
+	repo     *repository.RedisRepository
+	audit    *AuditLog
+	logger   *logger.Logger
+	domains  atomic.Value
+	mu       sync.Mutex
+	loadedAt time.Time
+}
+
+type CreateWorkspaceRequest struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+}
+
+type Workspace struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Domains   []string   `json:"domains"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+func NewWorkspaceService(redisRepo *repository.RedisRepository, audit *AuditLog, logger *logger.Logger) *WorkspaceService {
+	svc := &WorkspaceService{
+		repo:   redisRepo,
+		audit:  audit,
+		logger: logger,
+	}
+	svc.domains.Store(map[string]string{})
+
+	return svc
+}
+
+func (svc *WorkspaceService) Create(principal Principal, req CreateWorkspaceRequest) (Workspace, error) {
+	if principal.Workspace != DefaultWorkspace {
+		return Workspace{}, ErrForbidden
+	}
+
+	if !workspaceIDPattern.MatchString(req.ID) || req.ID == DefaultWorkspace {
+		return Workspace{}, fmt.Errorf("%w: invalid workspace id %q", ErrInvalidRequest, req.ID)
+	}
+
+	domains := make([]string, len(req.Domains))
+
+	for i, domain := range req.Domains {
+		normalized, err := normalizeDomain(domain)
+		if err != nil {
+			return Workspace{}, err
+		}
+
+		domains[i] = normalized
+	}
+
+	existing, err := svc.repo.WorkspaceByID(req.ID)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	if existing.ID != "" {
+		return Workspace{}, fmt.Errorf("%w: workspace %q", ErrConflict, req.ID)
+	}
+
+	for _, domain := range domains {
+		if err = svc.claimDomain(domain, req.ID); err != nil {
+			return Workspace{}, err
+		}
+	}
+
+	workspace := repository.Workspace{
+		ID:        req.ID,
+		Name:      req.Name,
+		Domains:   domains,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err = svc.repo.StoreWorkspace(workspace); err != nil {
+		return Workspace{}, err
+	}
+
+	svc.invalidate()
+	svc.logger.LogInfo("workspace created", req.ID)
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditWorkspaceCreate,
+		Target: req.ID,
+		After:  map[string]interface{}{"name": req.Name, "domains": domains},
+	})
+
+	return toWorkspace(workspace), nil
+}
+
+func (svc *WorkspaceService) List(caller string) ([]Workspace, error) {
+	if caller != DefaultWorkspace {
+		return nil, ErrForbidden
+	}
+
+	stored, err := svc.repo.Workspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := []Workspace{{ID: DefaultWorkspace, Name: DefaultWorkspace, Domains: []string{}}}
+	for _, workspace := range stored {
+		workspaces = append(workspaces, toWorkspace(workspace))
+	}
+
+	return workspaces, nil
+}
+
+// AddDomain dedicates another short domain to the workspace.
+func (svc *WorkspaceService) AddDomain(principal Principal, id, domain string) (Workspace, error) {
+	if principal.Workspace != DefaultWorkspace {
+		return Workspace{}, ErrForbidden
+	}
+
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	workspace, err := svc.repo.WorkspaceByID(id)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	if workspace.ID == "" {
+		return Workspace{}, ErrWorkspaceNotFound
+	}
+
+	if err = svc.claimDomain(domain, id); err != nil {
+		return Workspace{}, err
+	}
+
+	for _, d := range workspace.Domains {
+		if d == domain {
+			return toWorkspace(workspace), nil
+		}
+	}
+
+	workspace.Domains = append(workspace.Domains, domain)
+
+	if err = svc.repo.StoreWorkspace(workspace); err != nil {
+		return Workspace{}, err
+	}
+
+	svc.invalidate()
+	svc.audit.Record(principal, AuditEntry{
+		Action: AuditWorkspaceDomain,
+		Target: id,
+		After:  map[string]interface{}{"domain": domain},
+	})
+
+	return toWorkspace(workspace), nil
+}
+
+// ResolveHost returns the workspace the short domain is dedicated to, or an
+// empty string for the shared domains.
+func (svc *WorkspaceService) ResolveHost(host string) string {
+	svc.maybeReload()
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return svc.domains.Load().(map[string]string)[normalizeHost(host)]
+}
+
+// Exists reports whether the workspace was created, the default one
+// always exists.
+func (svc *WorkspaceService) Exists(id string) (bool, error) {
+	if id == DefaultWorkspace {
+		return true, nil
+	}
+
+	workspace, err := svc.repo.WorkspaceByID(id)
+
+	return workspace.ID != "", err
+}
+
+// ShortDomain returns the domain new links of the workspace are shared on,
+// empty when it has none of its own.
+func (svc *WorkspaceService) ShortDomain(id string) (string, error) {
+	if id == DefaultWorkspace {
+		return "", nil
+	}
+
+	workspace, err := svc.repo.WorkspaceByID(id)
+	if err != nil || len(workspace.Domains) == 0 {
+		return "", err
+	}
+
+	return workspace.Domains[0], nil
+}
+
+func (svc *WorkspaceService) claimDomain(domain, id string) error {
+	claimed, err := svc.repo.ClaimDomain(domain, id)
+	if err != nil {
+		return err
+	}
+
+	if !claimed {
+		return fmt.Errorf("%w: domain %q", ErrConflict, domain)
+	}
+
+	return nil
+}
+
+func (svc *WorkspaceService) maybeReload() {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(svc.loadedAt) < DefaultDomainRefreshInterval {
+		return
+	}
+
+	svc.loadedAt = now
+
+	domains, err := svc.repo.WorkspaceDomains()
+	if err != nil {
+		svc.logger.LogError("load workspace domains", err)
+
+		return
+	}
+
+	svc.domains.Store(domains)
+}
+
+func (svc *WorkspaceService) invalidate() {
+	svc.mu.Lock()
+	svc.loadedAt = time.Time{}
+	svc.mu.Unlock()
+}
+
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSpace(domain)
+
+	if strings.ContainsAny(domain, "/:@ ") || normalizeHost(domain) == "" {
+		return "", fmt.Errorf("%w: invalid domain %q", ErrInvalidRequest, domain)
+	}
+
+	return normalizeHost(domain), nil
+}
+
+func toWorkspace(workspace repository.Workspace) Workspace {
+	result := Workspace{
+		ID:      workspace.ID,
+		Name:    workspace.Name,
+		Domains: workspace.Domains,
+	}
+
+	if result.Domains == nil {
+		result.Domains = []string{}
+	}
+
+	if !workspace.CreatedAt.IsZero() {
+		createdAt := workspace.CreatedAt
+		result.CreatedAt = &createdAt
+	}
+
+	return result
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+var bearerPrefix = []byte("Bearer ")
+
+type AuthConfig struct {
+	// Let requests without a token create links, tokens are still checked
+	// when sent.
+	AllowAnonymousCreate bool
+}
+
+// TokenAuthenticator turns a bearer token into the principal it
+// identifies. Accepts tells the token formats apart without checking them.
+type TokenAuthenticator interface {
+	Accepts(token string) bool
+	Authenticate(token string) (service.Principal, error)
+}
+
+// Authenticator guards handlers with bearer tokens, each token is checked by
+// the first authenticator in the chain that accepts it.
+type Authenticator struct {
+	cfg             AuthConfig
+	authenticators  []TokenAuthenticator
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewAuthenticator(
+	cfg AuthConfig,
+	authenticators []TokenAuthenticator,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *Authenticator {
+	return &Authenticator{
+		cfg:             cfg,
+		authenticators:  authenticators,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+// Require runs the handler only for requests with a valid token that grants
+// the scope. The principal is left in the handlers.PrincipalUserValue user
+// value.
+func (a *Authenticator) Require(scope service.Scope, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		token := bearerToken(ctx)
+		if token == "" {
+			if scope == service.ScopeCreate && a.cfg.AllowAnonymousCreate {
+				next(ctx)
+
+				return
+			}
+
+			a.reject(ctx, http.StatusUnauthorized, metrics.StatusUnauthorized, metrics.AuthMissing)
+
+			return
+		}
+
+		principal, err := a.authenticate(token)
+
+		switch {
+		case errors.Is(err, service.ErrUnauthorized):
+			a.reject(ctx, http.StatusUnauthorized, metrics.StatusUnauthorized, metrics.AuthInvalid)
+
+			return
+		case err != nil:
+			a.logger.LogError("authenticate bearer token", err)
+			ctx.SetStatusCode(http.StatusInternalServerError)
+			a.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+
+			return
+		case !principal.HasScope(scope):
+			a.reject(ctx, http.StatusForbidden, metrics.StatusForbidden, metrics.AuthForbidden)
+
+			return
+		}
+
+		ctx.SetUserValue(handlers.PrincipalUserValue, principal)
+		next(ctx)
+	}
+}
+
+func (a *Authenticator) authenticate(token string) (service.Principal, error) {
+	for _, authenticator := range a.authenticators {
+		if authenticator.Accepts(token) {
+			return authenticator.Authenticate(token)
+		}
+	}
+
+	return service.Principal{}, service.ErrUnauthorized
+}
+
+func (a *Authenticator) reject(
+	ctx *fasthttp.RequestCtx,
+	status int,
+	response metrics.ResponseType,
+	reason metrics.AuthRejection) {
+	if status == http.StatusUnauthorized {
+		ctx.Response.Header.Set(fasthttp.HeaderWWWAuthenticate, "Bearer")
+	}
+
+	ctx.SetStatusCode(status)
+	a.metricsRecorder.RecordResponse(response)
+	a.metricsRecorder.RecordAuthRejected(reason)
+}
+
+func bearerToken(ctx *fasthttp.RequestCtx) string {
+	header := ctx.Request.Header.Peek(fasthttp.HeaderAuthorization)
+	if !bytes.HasPrefix(header, bearerPrefix) {
+		return ""
+	}
+
+	return string(bytes.TrimSpace(header[len(bearerPrefix):]))
+}
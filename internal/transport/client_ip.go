@@ -0,0 +1,151 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	headerForwarded     = "Forwarded"
+	headerXForwardedFor = "X-Forwarded-For"
+)
+
+var ErrProxyConfig = errors.New("invalid trusted proxies")
+
+// TrustedProxies finds the client address of requests that came through the
+// proxies in front of the service. The Forwarded and X-Forwarded-For headers
+// are only believed when the peer is one of the proxies, anyone else could
+// send them to pass for another client.
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies takes addresses and CIDR ranges, none trusts no proxy
+// and every request comes from its peer.
+func NewTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %q is not an address", ErrProxyConfig, proxy)
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrProxyConfig, err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return &TrustedProxies{networks: networks}, nil
+}
+
+// Trusts reports whether the address is one of the proxies.
+func (p *TrustedProxies) Trusts(ip net.IP) bool {
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP leaves the client address of every request in the
+// handlers.ClientIPUserValue user value.
+func (p *TrustedProxies) ClientIP(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(handlers.ClientIPUserValue, p.clientIP(ctx).String())
+
+		next(ctx)
+	}
+}
+
+// clientIP walks the forwarded addresses from the nearest hop back, the
+// client is the first one that is not a proxy. Forwarded wins over
+// X-Forwarded-For when both are sent.
+func (p *TrustedProxies) clientIP(ctx *fasthttp.RequestCtx) net.IP {
+	client := ctx.RemoteIP()
+	if !p.Trusts(client) {
+		return client
+	}
+
+	hops := forwardedFor(&ctx.Request.Header)
+	if len(hops) == 0 {
+		hops = headerList(&ctx.Request.Header, headerXForwardedFor)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHop(hops[i])
+		if hop == nil {
+			break
+		}
+
+		client = hop
+		if !p.Trusts(hop) {
+			break
+		}
+	}
+
+	return client
+}
+
+// headerList joins every occurrence of a comma separated header.
+func headerList(header *fasthttp.RequestHeader, name string) []string {
+	var values []string
+
+	header.VisitAll(func(key, value []byte) {
+		if !bytes.EqualFold(key, []byte(name)) {
+			return
+		}
+
+		for _, v := range strings.Split(string(value), ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	})
+
+	return values
+}
+
+// forwardedFor returns the for parameters of the Forwarded header, one per
+// hop.
+func forwardedFor(header *fasthttp.RequestHeader) []string {
+	var hops []string
+
+	for _, element := range headerList(header, headerForwarded) {
+		for _, pair := range strings.Split(element, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(name, "for") {
+				hops = append(hops, value)
+			}
+		}
+	}
+
+	return hops
+}
+
+// parseHop reads an address that may be quoted, bracketed or carry a port.
+// Obfuscated identifiers such as "unknown" give nil.
+func parseHop(hop string) net.IP {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := NewTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers [][2]string
+		want    string
+	}{
+		{
+			name: "direct client",
+			peer: "203.0.113.7",
+			want: "203.0.113.7",
+		},
+		{
+			name:    "forged header from an untrusted peer",
+			peer:    "203.0.113.7",
+			headers: [][2]string{{"X-Forwarded-For", "198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "one proxy",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"X-Forwarded-For", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client prepends a forged hop",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"X-Forwarded-For", "1.2.3.4, 198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "chain of proxies",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"X-Forwarded-For", "198.51.100.1, 10.1.1.1, 10.2.2.2"}},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "only proxies",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"X-Forwarded-For", "10.1.1.1"}},
+			want:    "10.1.1.1",
+		},
+		{
+			name:    "proxy without a header",
+			peer:    "10.0.0.2",
+			headers: nil,
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "unparsable hop",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"X-Forwarded-For", "198.51.100.1, garbage"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "forwarded with ports and ipv6",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"Forwarded", `for="[2001:db8::cafe]:4711";proto=https, for=10.1.1.1:80`}},
+			want:    "2001:db8::cafe",
+		},
+		{
+			name: "forwarded wins over x-forwarded-for",
+			peer: "10.0.0.2",
+			headers: [][2]string{
+				{"Forwarded", "for=198.51.100.1"},
+				{"X-Forwarded-For", "198.51.100.2"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:    "obfuscated forwarded hop",
+			peer:    "10.0.0.2",
+			headers: [][2]string{{"Forwarded", "for=unknown"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "trusted ipv6 proxy",
+			peer:    "2001:db8::1",
+			headers: [][2]string{{"X-Forwarded-For", "198.51.100.1"}},
+			want:    "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			for _, header := range tt.headers {
+				req.Header.Add(header[0], header[1])
+			}
+
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 1234}, nil)
+
+			var got string
+			proxies.ClientIP(func(ctx *fasthttp.RequestCtx) {
+				got = handlers.ClientIP(ctx)
+			})(&ctx)
+
+			if got != tt.want {
+				t.Errorf("client = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTrustedProxiesRejectsGarbage(t *testing.T) {
+	for _, proxy := range []string{"proxy.internal", "10.0.0.0/33", ""} {
+		if _, err := NewTrustedProxies([]string{proxy}); !errors.Is(err, ErrProxyConfig) {
+			t.Errorf("%q: err = %v, want %v", proxy, err, ErrProxyConfig)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	corsWildcard = "*"
+
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+)
+
+var (
+	ErrCORSConfig = errors.New("invalid cors config")
+
+	apiPathPrefix = []byte("/api/")
+)
+
+type CORSConfig struct {
+	Enabled bool
+	// Origins allowed to call the API. "*" allows any, a leading "*." label
+	// as in "https://*.example.com" stands for any subdomain.
+	AllowedOrigins []string
+	AllowedMethods []string
+	// Request headers allowed, "*" allows whatever the preflight asks for.
+	AllowedHeaders []string
+	// Response headers the browser lets scripts read.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// How long browsers may cache a preflight.
+	MaxAge time.Duration
+}
+
+// CORS lets browser apps on the allowed origins call /create and the
+// management API. Preflights are answered before routing, the redirect and
+// report routes never get CORS headers.
+type CORS struct {
+	cfg             CORSConfig
+	origins         []originPattern
+	anyOrigin       bool
+	methods         map[string]struct{}
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+// originPattern is an allowed origin, subdomains matches the hosts under
+// host rather than host itself.
+type originPattern struct {
+	scheme     string
+	host       string
+	port       string
+	subdomains bool
+}
+
+// NewCORS refuses to allow any origin with credentials, that would let every
+// site call the API with the cookies of its visitors. Origins may only hold
+// a wildcard as their leading host label.
+func NewCORS(cfg CORSConfig, metricsRecorder *prometheus.MetricsRecorder) (*CORS, error) {
+	c := &CORS{
+		cfg:             cfg,
+		methods:         make(map[string]struct{}, len(cfg.AllowedMethods)),
+		metricsRecorder: metricsRecorder,
+	}
+
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = struct{}{}
+	}
+
+	if !cfg.Enabled {
+		return c, nil
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == corsWildcard {
+			if cfg.AllowCredentials {
+				return nil, fmt.Errorf("%w: %q cannot be allowed with credentials", ErrCORSConfig, corsWildcard)
+			}
+
+			c.anyOrigin = true
+
+			continue
+		}
+
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+
+		c.origins = append(c.origins, pattern)
+	}
+
+	return c, nil
+}
+
+func (c *CORS) Handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !c.cfg.Enabled {
+		return next
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		origin := string(ctx.Request.Header.Peek(fasthttp.HeaderOrigin))
+		if origin == "" || !isAPIPath(ctx.Path()) {
+			next(ctx)
+
+			return
+		}
+
+		// The answer depends on the origin whether it is allowed or not, caches
+		// must not hand a refusal to an allowed origin or the other way round.
+		ctx.Response.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderOrigin)
+
+		if ctx.IsOptions() && len(ctx.Request.Header.Peek(headerRequestMethod)) > 0 {
+			c.preflight(ctx, origin)
+
+			return
+		}
+
+		if c.allowsOrigin(origin) {
+			c.setOrigin(ctx, origin)
+
+			if len(c.cfg.ExposedHeaders) > 0 {
+				ctx.Response.Header.Set(headerExposeHeaders, strings.Join(c.cfg.ExposedHeaders, ", "))
+			}
+		}
+
+		next(ctx)
+	}
+}
+
+func (c *CORS) preflight(ctx *fasthttp.RequestCtx, origin string) {
+	method := strings.ToUpper(string(ctx.Request.Header.Peek(headerRequestMethod)))
+
+	if _, ok := c.methods[method]; !ok || !c.allowsOrigin(origin) {
+		ctx.SetStatusCode(http.StatusForbidden)
+		c.metricsRecorder.RecordResponse(metrics.StatusForbidden)
+
+		return
+	}
+
+	c.setOrigin(ctx, origin)
+	ctx.Response.Header.Set(headerAllowMethods, strings.Join(c.cfg.AllowedMethods, ", "))
+
+	allowedHeaders := strings.Join(c.cfg.AllowedHeaders, ", ")
+	if c.allowsAnyHeader() {
+		allowedHeaders = string(ctx.Request.Header.Peek(headerRequestHeaders))
+	}
+
+	if allowedHeaders != "" {
+		ctx.Response.Header.Set(headerAllowHeaders, allowedHeaders)
+	}
+
+	if c.cfg.MaxAge > 0 {
+		ctx.Response.Header.Set(headerMaxAge, strconv.Itoa(int(c.cfg.MaxAge.Seconds())))
+	}
+
+	ctx.SetStatusCode(http.StatusNoContent)
+	c.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
+
+// setOrigin echoes the origin rather than sending "*", which browsers refuse
+// together with credentials.
+func (c *CORS) setOrigin(ctx *fasthttp.RequestCtx, origin string) {
+	ctx.Response.Header.Set(headerAllowOrigin, origin)
+
+	if c.cfg.AllowCredentials {
+		ctx.Response.Header.Set(headerAllowCredentials, "true")
+	}
+}
+
+func (c *CORS) allowsOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	scheme, host, port, ok := splitOrigin(origin)
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range c.origins {
+		if pattern.matches(scheme, host, port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *CORS) allowsAnyHeader() bool {
+	for _, header := range c.cfg.AllowedHeaders {
+		if header == corsWildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseOriginPattern reads scheme://host[:port], the host may start with a
+// "*." label standing for any subdomain.
+func parseOriginPattern(origin string) (originPattern, error) {
+	var pattern originPattern
+
+	scheme, rest, found := strings.Cut(origin, "://")
+	if strings.HasPrefix(rest, corsWildcard+".") {
+		pattern.subdomains = true
+		rest = rest[len(corsWildcard+"."):]
+	}
+
+	var ok bool
+
+	pattern.scheme, pattern.host, pattern.port, ok = splitOrigin(scheme + "://" + rest)
+	// A wildcard right above a top level domain would allow nearly any site.
+	if !found || !ok || strings.Contains(pattern.host, corsWildcard) ||
+		pattern.subdomains && !strings.Contains(pattern.host, ".") {
+		return originPattern{}, fmt.Errorf("%w: invalid origin %q", ErrCORSConfig, origin)
+	}
+
+	return pattern, nil
+}
+
+func (p originPattern) matches(scheme, host, port string) bool {
+	if scheme != p.scheme || port != p.port {
+		return false
+	}
+
+	if p.subdomains {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+
+	return host == p.host
+}
+
+// splitOrigin returns the lowercased parts of an origin, which has nothing
+// after its host and port.
+func splitOrigin(origin string) (scheme, host, port string, ok bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Hostname() == "" || u.User != nil || u.Opaque != "" ||
+		u.Path != "" || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return "", "", "", false
+	}
+
+	return strings.ToLower(u.Scheme), strings.ToLower(u.Hostname()), u.Port(), true
+}
+
+func isAPIPath(path []byte) bool {
+	return string(path) == "/create" || bytes.HasPrefix(path, apiPathPrefix)
+}
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	"url-shortener/internal/metrics/prometheus"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestCORS(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		Enabled:          true,
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}, prometheus.NewMetricsRecorder(prometheus.MetricsConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		origin        string
+		requestMethod string
+		wantStatus    int
+		wantOrigin    string
+		wantVary      bool
+		wantServed    bool
+	}{
+		{
+			name:       "allowed origin",
+			method:     http.MethodPost,
+			path:       "/create",
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://app.example.com",
+			wantVary:   true,
+			wantServed: true,
+		},
+		{
+			name:       "allowed subdomain",
+			method:     http.MethodGet,
+			path:       "/api/v1/links",
+			origin:     "https://team.example.org",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://team.example.org",
+			wantVary:   true,
+			wantServed: true,
+		},
+		{
+			name:       "disallowed origin",
+			method:     http.MethodGet,
+			path:       "/api/v1/links",
+			origin:     "https://evil.example.net",
+			wantStatus: http.StatusOK,
+			wantVary:   true,
+			wantServed: true,
+		},
+		{
+			name:       "same origin request",
+			method:     http.MethodGet,
+			path:       "/api/v1/links",
+			wantStatus: http.StatusOK,
+			wantServed: true,
+		},
+		{
+			name:       "short link",
+			method:     http.MethodGet,
+			path:       "/abc123",
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusOK,
+			wantServed: true,
+		},
+		{
+			name:          "preflight",
+			method:        http.MethodOptions,
+			path:          "/api/v1/links",
+			origin:        "https://app.example.com",
+			requestMethod: http.MethodPost,
+			wantStatus:    http.StatusNoContent,
+			wantOrigin:    "https://app.example.com",
+			wantVary:      true,
+		},
+		{
+			name:          "preflight from a disallowed origin",
+			method:        http.MethodOptions,
+			path:          "/api/v1/links",
+			origin:        "https://evil.example.net",
+			requestMethod: http.MethodPost,
+			wantStatus:    http.StatusForbidden,
+			wantVary:      true,
+		},
+		{
+			name:          "preflight for a disallowed method",
+			method:        http.MethodOptions,
+			path:          "/api/v1/links",
+			origin:        "https://app.example.com",
+			requestMethod: http.MethodDelete,
+			wantStatus:    http.StatusForbidden,
+			wantVary:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx fasthttp.RequestCtx
+			ctx.Request.Header.SetMethod(tt.method)
+			ctx.Request.SetRequestURI(tt.path)
+
+			if tt.origin != "" {
+				ctx.Request.Header.Set(fasthttp.HeaderOrigin, tt.origin)
+			}
+
+			if tt.requestMethod != "" {
+				ctx.Request.Header.Set(headerRequestMethod, tt.requestMethod)
+			}
+
+			served := false
+			cors.Handle(func(ctx *fasthttp.RequestCtx) {
+				served = true
+			})(&ctx)
+
+			if served != tt.wantServed {
+				t.Errorf("served = %v, want %v", served, tt.wantServed)
+			}
+
+			if status := ctx.Response.StatusCode(); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+
+			if origin := string(ctx.Response.Header.Peek(headerAllowOrigin)); origin != tt.wantOrigin {
+				t.Errorf("allowed origin = %q, want %q", origin, tt.wantOrigin)
+			}
+
+			if vary := string(ctx.Response.Header.Peek(fasthttp.HeaderVary)) == fasthttp.HeaderOrigin; vary != tt.wantVary {
+				t.Errorf("vary on origin = %v, want %v", vary, tt.wantVary)
+			}
+
+			credentials := string(ctx.Response.Header.Peek(headerAllowCredentials)) == "true"
+			if credentials != (tt.wantOrigin != "") {
+				t.Errorf("credentials allowed = %v with origin %q", credentials, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestNewCORSRejectsAnyOriginWithCredentials(t *testing.T) {
+	cfg := CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}
+
+	if _, err := NewCORS(cfg, nil); !errors.Is(err, ErrCORSConfig) {
+		t.Fatalf("err = %v, want %v", err, ErrCORSConfig)
+	}
+
+	cfg.AllowCredentials = false
+
+	if _, err := NewCORS(cfg, nil); err != nil {
+		t.Fatalf("any origin without credentials: %v", err)
+	}
+}
+
+func TestNewCORSRejectsWildcardsOutsideTheLeadingLabel(t *testing.T) {
+	for _, origin := range []string{
+		"https://*",
+		"*://*",
+		"http*",
+		"https://*.com",
+		"https://app.*.example.com",
+		"https://*app.example.com",
+		"https://app.example.*",
+		"https://*.example.com/",
+		"https://*.example.com/path",
+		"app.example.com",
+		"https://user@app.example.com",
+	} {
+		cfg := CORSConfig{Enabled: true, AllowedOrigins: []string{origin}}
+
+		if _, err := NewCORS(cfg, nil); !errors.Is(err, ErrCORSConfig) {
+			t.Errorf("%q: err = %v, want %v", origin, err, ErrCORSConfig)
+		}
+	}
+}
+
+func TestCORSAllowsOrigin(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		Enabled:          true,
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"},
+		AllowCredentials: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for origin, want := range map[string]bool{
+		"https://app.example.com":          true,
+		"HTTPS://APP.example.com":          true,
+		"https://team.example.org":         true,
+		"https://a.b.example.org":          true,
+		"http://localhost:3000":            true,
+		"http://app.example.com":           false,
+		"https://app.example.com:8443":     false,
+		"https://example.org":              false,
+		"https://evilexample.org":          false,
+		"https://team.example.org.evil.io": false,
+		"https://evil.io/.example.org":     false,
+		"https://evil.io?.example.org":     false,
+		"http://localhost:3001":            false,
+		"null":                             false,
+	} {
+		if got := cors.allowsOrigin(origin); got != want {
+			t.Errorf("allowsOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type APIKeyManager interface {
+	Create(principal service.Principal, req service.CreateAPIKeyRequest) (service.CreatedAPIKey, error)
+	List(workspace string) ([]service.APIKey, error)
+	Revoke(principal service.Principal, id string) error
+}
+
+type APIKeysHandler struct {
+	baseHandler
+	apiKeyService   *service.APIKeyService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewAPIKeysHandler(
+	apiKeyService *service.APIKeyService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *APIKeysHandler {
+	return &APIKeysHandler{
+		apiKeyService:   apiKeyService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *APIKeysHandler) Create(ctx *fasthttp.RequestCtx) {
+	var req service.CreateAPIKeyRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeAPIKeys)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	key, err := h.apiKeyService.Create(h.Principal(ctx), req)
+	if err != nil {
+		h.logger.LogError("create api key", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(key)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *APIKeysHandler) List(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeAPIKeys)
+
+	keys, err := h.apiKeyService.List(h.Workspace(ctx))
+	if err != nil {
+		h.logger.LogError("list api keys", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(keys)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *APIKeysHandler) Revoke(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeAPIKeys)
+
+	if err := h.apiKeyService.Revoke(h.Principal(ctx), ctx.UserValue("id").(string)); err != nil {
+		h.logger.LogError("revoke api key", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.RespondNoContent(ctx)
+	h.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	AuditFormatNDJSON = "ndjson"
+	AuditFormatCSV    = "csv"
+
+	ndjsonContentType = "application/x-ndjson"
+	csvContentType    = "text/csv; charset=utf-8"
+)
+
+var auditCSVHeader = []string{
+	"id", "time", "workspace", "actor", "action", "code", "target", "before", "after", "request_id",
+}
+
+type AuditReader interface {
+	Query(query service.AuditQuery) (service.AuditPage, error)
+}
+
+type AuditHandler struct {
+	baseHandler
+	auditService    *service.AuditService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewAuditHandler(
+	auditService *service.AuditService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *AuditHandler {
+	return &AuditHandler{
+		auditService:    auditService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *AuditHandler) Query(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeAudit)
+
+	query, err := h.query(ctx)
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	page, err := h.auditService.Query(query)
+	if err != nil {
+		h.logger.LogError("query audit log", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(page)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+// Export streams every entry matching the filters as NDJSON, or as CSV with
+// format=csv.
+func (h *AuditHandler) Export(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeAudit)
+
+	format := string(ctx.QueryArgs().Peek("format"))
+	if format == "" {
+		format = AuditFormatNDJSON
+	}
+
+	query, err := h.query(ctx)
+	if err == nil && format != AuditFormatNDJSON && format != AuditFormatCSV {
+		err = fmt.Errorf("%w: unknown format %q", service.ErrInvalidRequest, format)
+	}
+
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	query.Limit = service.MaxAuditLimit
+
+	// The first page is read up front so errors still get a status.
+	page, err := h.auditService.Query(query)
+	if err != nil {
+		h.logger.LogError("export audit log", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	contentType := ndjsonContentType
+	if format == AuditFormatCSV {
+		contentType = csvContentType
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType(contentType)
+	ctx.Response.Header.Set(fasthttp.HeaderContentDisposition, fmt.Sprintf(
+		"attachment; filename=\"audit-%s-%s.%s\"", query.Principal.Workspace, time.Now().UTC().Format("20060102"), format))
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer w.Flush()
+
+		write := func(entry service.AuditEntry) error {
+			return writeAuditNDJSON(w, entry)
+		}
+
+		if format == AuditFormatCSV {
+			out := csv.NewWriter(w)
+			if out.Write(auditCSVHeader) != nil {
+				return
+			}
+
+			write = func(entry service.AuditEntry) error {
+				return writeAuditCSV(out, entry)
+			}
+		}
+
+		for {
+			for _, entry := range page.Entries {
+				if write(entry) != nil {
+					return
+				}
+			}
+
+			if page.Next == "" || w.Flush() != nil {
+				return
+			}
+
+			query.Cursor = page.Next
+
+			if page, err = h.auditService.Query(query); err != nil {
+				// The status is sent already, the export just ends early.
+				h.logger.LogError("export audit log", err)
+
+				return
+			}
+		}
+	})
+}
+
+func (h *AuditHandler) query(ctx *fasthttp.RequestCtx) (service.AuditQuery, error) {
+	args := ctx.QueryArgs()
+	limit, _ := args.GetUint("limit")
+
+	query := service.AuditQuery{
+		Principal: h.Principal(ctx),
+		Code:      string(args.Peek("code")),
+		Actor:     string(args.Peek("actor")),
+		Action:    service.AuditAction(args.Peek("action")),
+		Cursor:    string(args.Peek("cursor")),
+		Limit:     int64(limit),
+	}
+
+	var err error
+
+	if query.Since, err = parseTimeArg(args, "since"); err != nil {
+		return service.AuditQuery{}, err
+	}
+
+	if query.Until, err = parseTimeArg(args, "until"); err != nil {
+		return service.AuditQuery{}, err
+	}
+
+	return query, nil
+}
+
+func parseTimeArg(args *fasthttp.Args, name string) (time.Time, error) {
+	value := string(args.Peek(name))
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an RFC 3339 time", service.ErrInvalidRequest, name)
+	}
+
+	return t, nil
+}
+
+func writeAuditNDJSON(w *bufio.Writer, entry service.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+// writeAuditCSV keeps the states as JSON in their columns.
+func writeAuditCSV(out *csv.Writer, entry service.AuditEntry) error {
+	before, after := "", ""
+
+	if entry.Before != nil {
+		data, _ := json.Marshal(entry.Before)
+		before = string(data)
+	}
+
+	if entry.After != nil {
+		data, _ := json.Marshal(entry.After)
+		after = string(data)
+	}
+
+	err := out.Write([]string{
+		entry.ID, entry.Time.Format(time.RFC3339Nano), entry.Workspace, entry.Actor, string(entry.Action),
+		entry.Code, entry.Target, before, after, entry.RequestID,
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
@@ -1,12 +1,33 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/templates"
 
+	json "github.com/json-iterator/go"
 	"github.com/valyala/fasthttp"
 )
 
-const jsonContentType = "application/json"
+const (
+	jsonContentType = "application/json"
+	htmlContentType = "text/html; charset=utf-8"
+
+	// PrincipalUserValue holds the service.Principal the request was
+	// authenticated as.
+	PrincipalUserValue = "principal"
+	// RequestIDUserValue holds the id the request is logged and audited
+	// under.
+	RequestIDUserValue = "request_id"
+	// ClientIPUserValue holds the address of the client, which is not the
+	// peer when the request came through a trusted proxy.
+	ClientIPUserValue = "client_ip"
+)
 
 type baseHandler struct {
 }
@@ -15,6 +36,117 @@ func (h *baseHandler) RespondBadRequest(ctx *fasthttp.RequestCtx) {
 	ctx.SetStatusCode(http.StatusBadRequest)
 }
 
+func (h *baseHandler) RespondNoContent(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(http.StatusNoContent)
+}
+
+// Principal returns who the request was authenticated as, anonymous
+// requests get a zero principal of the default workspace.
+func (h *baseHandler) Principal(ctx *fasthttp.RequestCtx) service.Principal {
+	principal, _ := ctx.UserValue(PrincipalUserValue).(service.Principal)
+	if principal.Workspace == "" {
+		principal.Workspace = service.DefaultWorkspace
+	}
+
+	principal.RequestID, _ = ctx.UserValue(RequestIDUserValue).(string)
+
+	return principal
+}
+
+// ClientIP returns the address of the client, the peer when it was not
+// resolved from the forwarded headers.
+func ClientIP(ctx *fasthttp.RequestCtx) string {
+	if ip, ok := ctx.UserValue(ClientIPUserValue).(string); ok {
+		return ip
+	}
+
+	return ctx.RemoteIP().String()
+}
+
+// Workspace returns the workspace the request acts in, anonymous requests
+// act in the default one.
+func (h *baseHandler) Workspace(ctx *fasthttp.RequestCtx) string {
+	return h.Principal(ctx).Workspace
+}
+
+func (h *baseHandler) RespondNotFound(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(http.StatusNotFound)
+}
+
+// RespondError maps domain errors to HTTP statuses and returns the response
+// type to record.
+func (h *baseHandler) RespondError(ctx *fasthttp.RequestCtx, err error) metrics.ResponseType {
+	switch {
+	case errors.Is(err, service.ErrLinkNotFound),
+		errors.Is(err, service.ErrAPIKeyNotFound),
+		errors.Is(err, service.ErrWorkspaceNotFound),
+		errors.Is(err, service.ErrMemberNotFound):
+		h.RespondNotFound(ctx)
+
+		return metrics.StatusNotFound
+	case errors.Is(err, service.ErrInvalidRequest):
+		h.RespondBadRequest(ctx)
+
+		return metrics.StatusBadRequest
+	case errors.Is(err, service.ErrForbidden):
+		ctx.SetStatusCode(http.StatusForbidden)
+
+		// Tell the caller which role it was missing.
+		var permissionErr *service.PermissionError
+		if errors.As(err, &permissionErr) {
+			responseBody, _ := json.Marshal(permissionErr)
+			ctx.SetContentType(jsonContentType)
+			_, _ = ctx.Write(responseBody)
+		}
+
+		return metrics.StatusForbidden
+	case errors.Is(err, service.ErrDestinationBlocked):
+		ctx.SetStatusCode(http.StatusUnprocessableEntity)
+
+		var screeningErr *service.ScreeningError
+		if errors.As(err, &screeningErr) {
+			responseBody, _ := json.Marshal(screeningErr)
+			ctx.SetContentType(jsonContentType)
+			_, _ = ctx.Write(responseBody)
+		}
+
+		return metrics.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrQuotaExceeded):
+		// A used up daily quota has room again tomorrow, a total one does
+		// not.
+		status, response := http.StatusForbidden, metrics.StatusForbidden
+
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			if quotaErr.ResetAt != nil {
+				status, response = http.StatusTooManyRequests, metrics.StatusTooManyRequests
+				ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, secondsUntil(*quotaErr.ResetAt))
+			}
+
+			responseBody, _ := json.Marshal(quotaErr)
+			ctx.SetContentType(jsonContentType)
+			_, _ = ctx.Write(responseBody)
+		}
+
+		ctx.SetStatusCode(status)
+
+		return response
+	case errors.Is(err, service.ErrConflict):
+		ctx.SetStatusCode(http.StatusConflict)
+
+		return metrics.StatusConflict
+	default:
+		h.RespondInternalError(ctx)
+
+		return metrics.StatusInternalError
+	}
+}
+
+// secondsUntil rounds up, so clients never retry too early.
+func secondsUntil(t time.Time) string {
+	return strconv.Itoa(int(math.Ceil(time.Until(t).Seconds())))
+}
+
 func (h *baseHandler) RespondInternalError(ctx *fasthttp.RequestCtx) {
 	ctx.SetStatusCode(http.StatusInternalServerError)
 }
@@ -25,3 +157,18 @@ func (h *baseHandler) RespondOK(ctx *fasthttp.RequestCtx, responseBody []byte) {
 	_, _ = ctx.Write(responseBody)
 
 }
+
+func (h *baseHandler) RespondHTML(ctx *fasthttp.RequestCtx, page string, data interface{}) error {
+	ctx.Response.ResetBody()
+
+	if err := templates.Render(ctx, page, data); err != nil {
+		ctx.Response.ResetBody()
+
+		return err
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType(htmlContentType)
+
+	return nil
+}
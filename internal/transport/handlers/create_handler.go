@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"strconv"
+	"time"
 	"url-shortener/internal/logger"
 	"url-shortener/internal/metrics"
 	"url-shortener/internal/metrics/prometheus"
@@ -10,8 +13,16 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+const (
+	headerQuotaDailyLimit     = "Quota-Daily-Limit"
+	headerQuotaDailyRemaining = "Quota-Daily-Remaining"
+	headerQuotaDailyReset     = "Quota-Daily-Reset"
+	headerQuotaTotalLimit     = "Quota-Total-Limit"
+	headerQuotaTotalRemaining = "Quota-Total-Remaining"
+)
+
 type Creator interface {
-	Create(req *service.Request) (service.Response, error)
+	Create(principal service.Principal, req *service.Request) (service.Response, error)
 }
 
 type CreateHandler struct {
@@ -44,16 +55,52 @@ func (h *CreateHandler) Create(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	response, err := h.shortURLCreator.Create(&req)
+	response, err := h.shortURLCreator.Create(h.Principal(ctx), &req)
 	if err != nil {
-		h.RespondInternalError(ctx)
-		h.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			h.quotas(ctx, quotaErr.Quotas)
+		}
+
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
 
 		return
 	}
 
+	h.quotas(ctx, response.Quotas)
+
 	responseBody, _ := json.Marshal(response)
 
 	h.RespondOK(ctx, responseBody)
 	h.metricsRecorder.RecordResponse(metrics.StatusOk)
 }
+
+// quotas sets the quota headers from the tightest quota of each period,
+// unlimited periods get none, and updates the usage gauges.
+func (h *CreateHandler) quotas(ctx *fasthttp.RequestCtx, quotas service.Quotas) {
+	if daily, ok := quotas.Tightest(service.QuotaDaily); ok {
+		ctx.Response.Header.Set(headerQuotaDailyLimit, strconv.FormatInt(daily.Limit, 10))
+		ctx.Response.Header.Set(headerQuotaDailyRemaining, strconv.FormatInt(*daily.Remaining, 10))
+		ctx.Response.Header.Set(headerQuotaDailyReset, secondsUntil(quotas[0].ResetAt))
+	}
+
+	if total, ok := quotas.Tightest(service.QuotaTotal); ok {
+		ctx.Response.Header.Set(headerQuotaTotalLimit, strconv.FormatInt(total.Limit, 10))
+		ctx.Response.Header.Set(headerQuotaTotalRemaining, strconv.FormatInt(*total.Remaining, 10))
+	}
+
+	recordQuotas(h.metricsRecorder, quotas)
+}
+
+func recordQuotas(metricsRecorder *prometheus.MetricsRecorder, quotas service.Quotas) {
+	for _, quota := range quotas {
+		if quota.Scope != service.QuotaScopeWorkspace {
+			continue
+		}
+
+		metricsRecorder.SetQuotaUsage(
+			quota.ID, string(service.QuotaDaily), quota.Daily.Used, quota.Daily.Limit, quota.ResetAt)
+		metricsRecorder.SetQuotaUsage(
+			quota.ID, string(service.QuotaTotal), quota.Total.Used, quota.Total.Limit, time.Time{})
+	}
+}
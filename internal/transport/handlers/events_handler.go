@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"time"
+	"url-shortener/internal/analytics"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+
+	DefaultHeartbeatInterval = 15 * time.Second
+)
+
+type EventsHandler struct {
+	baseHandler
+	cfg             EventsHandlerConfig
+	broker          *analytics.Broker
+	access          *service.AccessService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+type EventsHandlerConfig struct {
+	HeartbeatInterval time.Duration
+}
+
+// clickMessage is the public part of a click event sent to stream
+// subscribers.
+type clickMessage struct {
+	Timestamp time.Time `json:"ts"`
+	Code      string    `json:"code"`
+	Referrer  string    `json:"referrer"`
+	Country   string    `json:"country"`
+	Device    string    `json:"device"`
+	Browser   string    `json:"browser"`
+	Bot       bool      `json:"bot"`
+}
+
+func NewEventsHandler(
+	cfg EventsHandlerConfig,
+	broker *analytics.Broker,
+	access *service.AccessService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *EventsHandler {
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+
+	return &EventsHandler{
+		cfg:             cfg,
+		broker:          broker,
+		access:          access,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *EventsHandler) LinkEvents(ctx *fasthttp.RequestCtx) {
+	h.stream(ctx, ctx.UserValue("code").(string))
+}
+
+func (h *EventsHandler) AllEvents(ctx *fasthttp.RequestCtx) {
+	h.stream(ctx, "")
+}
+
+func (h *EventsHandler) stream(ctx *fasthttp.RequestCtx, code string) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeEvents)
+
+	principal := h.Principal(ctx)
+
+	err := h.access.Authorize(principal, service.ActionStreamEvents)
+	if code != "" {
+		err = h.access.AuthorizeCode(principal, code, service.ActionStreamEvents)
+	}
+
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	sub := h.broker.Subscribe(principal.Workspace, code)
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType(eventStreamContentType)
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.broker.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(h.cfg.HeartbeatInterval)
+		defer heartbeat.Stop()
+
+		// Tell the client the stream is open before the first click arrives.
+		if _, err := w.WriteString(": connected\n\n"); err != nil || w.Flush() != nil {
+			return
+		}
+
+		for {
+			select {
+			case event := <-sub.Events:
+				if err := writeClick(w, event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-sub.Done:
+				return
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeClick(w *bufio.Writer, event repository.ClickEvent) error {
+	data, err := json.Marshal(clickMessage{
+		Timestamp: event.Timestamp,
+		Code:      event.Code,
+		Referrer:  event.ReferrerDomain,
+		Country:   event.Country,
+		Device:    event.Device,
+		Browser:   event.Browser,
+		Bot:       event.Bot,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: click\ndata: %s\n\n", data)
+
+	return err
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type LinkManager interface {
+	Links(req service.LinksRequest) (service.Links, error)
+	Update(principal service.Principal, code string, req service.UpdateRequest) (service.LinkSummary, error)
+	Delete(principal service.Principal, code string) error
+	Claim(principal service.Principal, code string, req service.ClaimRequest) (service.LinkSummary, error)
+}
+
+type LinkSharer interface {
+	Shares(principal service.Principal, code string) ([]service.Member, error)
+	Share(principal service.Principal, code, user string, req service.SetMemberRequest) (service.Member, error)
+	Unshare(principal service.Principal, code, user string) error
+}
+
+type LinksHandler struct {
+	baseHandler
+	linkService     *service.URLShortener
+	accessService   *service.AccessService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewLinksHandler(
+	linkService *service.URLShortener,
+	accessService *service.AccessService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *LinksHandler {
+	return &LinksHandler{
+		linkService:     linkService,
+		accessService:   accessService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *LinksHandler) List(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	offset, _ := ctx.QueryArgs().GetUint("offset")
+	limit, _ := ctx.QueryArgs().GetUint("limit")
+
+	links, err := h.linkService.Links(service.LinksRequest{
+		Principal: h.Principal(ctx),
+		Offset:    int64(offset),
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		h.logger.LogError("list links", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(links)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *LinksHandler) Update(ctx *fasthttp.RequestCtx) {
+	var req service.UpdateRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	link, err := h.linkService.Update(h.Principal(ctx), ctx.UserValue("code").(string), req)
+	if err != nil {
+		h.logger.LogError("update link", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(link)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *LinksHandler) Delete(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	if err := h.linkService.Delete(h.Principal(ctx), ctx.UserValue("code").(string)); err != nil {
+		h.logger.LogError("delete link", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.RespondNoContent(ctx)
+	h.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
+
+// Claim takes over an anonymous link with its manage token.
+func (h *LinksHandler) Claim(ctx *fasthttp.RequestCtx) {
+	var req service.ClaimRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	link, err := h.linkService.Claim(h.Principal(ctx), ctx.UserValue("code").(string), req)
+	if err != nil {
+		h.logger.LogError("claim link", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(link)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *LinksHandler) Shares(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	shares, err := h.accessService.Shares(h.Principal(ctx), ctx.UserValue("code").(string))
+	if err != nil {
+		h.logger.LogError("list link shares", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(shares)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *LinksHandler) Share(ctx *fasthttp.RequestCtx) {
+	var req service.SetMemberRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	share, err := h.accessService.Share(
+		h.Principal(ctx), ctx.UserValue("code").(string), ctx.UserValue("user").(string), req)
+	if err != nil {
+		h.logger.LogError("share link", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(share)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *LinksHandler) Unshare(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeLinks)
+
+	err := h.accessService.Unshare(h.Principal(ctx), ctx.UserValue("code").(string), ctx.UserValue("user").(string))
+	if err != nil {
+		h.logger.LogError("unshare link", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.RespondNoContent(ctx)
+	h.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
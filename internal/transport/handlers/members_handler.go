@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type MemberManager interface {
+	Members(principal service.Principal) ([]service.Member, error)
+	SetMember(principal service.Principal, user string, req service.SetMemberRequest) (service.Member, error)
+	RemoveMember(principal service.Principal, user string) error
+}
+
+type MembersHandler struct {
+	baseHandler
+	accessService   *service.AccessService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewMembersHandler(
+	accessService *service.AccessService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *MembersHandler {
+	return &MembersHandler{
+		accessService:   accessService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *MembersHandler) List(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeMembers)
+
+	members, err := h.accessService.Members(h.Principal(ctx))
+	if err != nil {
+		h.logger.LogError("list members", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(members)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *MembersHandler) Set(ctx *fasthttp.RequestCtx) {
+	var req service.SetMemberRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeMembers)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	member, err := h.accessService.SetMember(h.Principal(ctx), ctx.UserValue("user").(string), req)
+	if err != nil {
+		h.logger.LogError("set member", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(member)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *MembersHandler) Remove(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeMembers)
+
+	if err := h.accessService.RemoveMember(h.Principal(ctx), ctx.UserValue("user").(string)); err != nil {
+		h.logger.LogError("remove member", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.RespondNoContent(ctx)
+	h.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type Moderator interface {
+	Queue(req service.ReportQueueRequest) (service.ReportQueue, error)
+	Link(principal service.Principal, code string) (service.LinkModeration, error)
+	Disable(principal service.Principal, code string, req service.ModerationRequest) (service.LinkModeration, error)
+	Restore(principal service.Principal, code string, req service.ModerationRequest) (service.LinkModeration, error)
+}
+
+type ModerationHandler struct {
+	baseHandler
+	moderationService *service.ModerationService
+	logger            *logger.Logger
+	metricsRecorder   *prometheus.MetricsRecorder
+}
+
+func NewModerationHandler(
+	moderationService *service.ModerationService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+		logger:            logger,
+		metricsRecorder:   metricsRecorder,
+	}
+}
+
+func (h *ModerationHandler) Queue(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeModeration)
+
+	offset, _ := ctx.QueryArgs().GetUint("offset")
+	limit, _ := ctx.QueryArgs().GetUint("limit")
+
+	queue, err := h.moderationService.Queue(service.ReportQueueRequest{
+		Principal: h.Principal(ctx),
+		Offset:    int64(offset),
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		h.logger.LogError("list report queue", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(queue)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *ModerationHandler) Link(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeModeration)
+
+	moderation, err := h.moderationService.Link(h.Principal(ctx), ctx.UserValue("code").(string))
+	if err != nil {
+		h.logger.LogError("get link reports", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(moderation)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *ModerationHandler) Disable(ctx *fasthttp.RequestCtx) {
+	h.decide(ctx, "disable link", h.moderationService.Disable)
+}
+
+func (h *ModerationHandler) Restore(ctx *fasthttp.RequestCtx) {
+	h.decide(ctx, "restore link", h.moderationService.Restore)
+}
+
+func (h *ModerationHandler) decide(
+	ctx *fasthttp.RequestCtx,
+	operation string,
+	decide func(service.Principal, string, service.ModerationRequest) (service.LinkModeration, error)) {
+	var req service.ModerationRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeModeration)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	moderation, err := decide(h.Principal(ctx), ctx.UserValue("code").(string), req)
+	if err != nil {
+		h.logger.LogError(operation, err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(moderation)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/templates"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	PreviewSuffix = "+"
+	PreviewParam  = "preview"
+)
+
+type Previewer interface {
+	Preview(host, shortURL string) (service.Preview, error)
+}
+
+type PreviewHandler struct {
+	baseHandler
+	previewer       *service.RedirectService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewPreviewHandler(
+	previewer *service.RedirectService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *PreviewHandler {
+	return &PreviewHandler{
+		previewer:       previewer,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+// IsPreviewRequest reports whether a request to the short link asks for its
+// preview instead of the redirect.
+func IsPreviewRequest(ctx *fasthttp.RequestCtx) bool {
+	hash, _ := ctx.UserValue("hash").(string)
+
+	return strings.HasSuffix(hash, PreviewSuffix) ||
+		bytes.Equal(ctx.QueryArgs().Peek(PreviewParam), []byte("1"))
+}
+
+func (h *PreviewHandler) Preview(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypePreview)
+
+	shortURL := strings.TrimSuffix(ctx.UserValue("hash").(string), PreviewSuffix)
+
+	preview, err := h.previewer.Preview(string(ctx.Host()), shortURL)
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	if strings.Contains(string(ctx.Request.Header.Peek(fasthttp.HeaderAccept)), jsonContentType) {
+		responseBody, _ := json.Marshal(preview)
+
+		h.RespondOK(ctx, responseBody)
+		h.metricsRecorder.RecordResponse(metrics.StatusOk)
+
+		return
+	}
+
+	err = h.RespondHTML(ctx, templates.Preview, templates.PreviewPage{
+		Host:    string(ctx.Host()),
+		Preview: preview,
+	})
+	if err != nil {
+		h.logger.LogError("render preview page", err)
+		h.RespondInternalError(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+
+		return
+	}
+
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type PrivacyHandler struct {
+	baseHandler
+	privacyService  *service.PrivacyService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewPrivacyHandler(
+	privacyService *service.PrivacyService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService:  privacyService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *PrivacyHandler) EraseLinkAnalytics(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeErasure)
+
+	if err := h.privacyService.EraseLinkAnalytics(h.Principal(ctx), ctx.UserValue("code").(string)); err != nil {
+		h.logger.LogError("erase link analytics", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.RespondNoContent(ctx)
+	h.metricsRecorder.RecordResponse(metrics.StatusNoContent)
+}
+
+// EraseOwnerAnalytics erases the analytics of every link created by the
+// owner in the path.
+func (h *PrivacyHandler) EraseOwnerAnalytics(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeErasure)
+
+	erasure, err := h.privacyService.EraseOwnerAnalytics(h.Principal(ctx), ctx.UserValue("owner").(string))
+	if err != nil {
+		h.logger.LogError("erase owner analytics", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(erasure)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
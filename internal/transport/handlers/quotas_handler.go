@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type QuotaManager interface {
+	Usage(principal service.Principal) (service.Quotas, error)
+	Workspace(principal service.Principal, id string) (service.Quota, error)
+	SetWorkspace(principal service.Principal, id string, req service.SetQuotaRequest) (service.Quota, error)
+	APIKey(principal service.Principal, id string) (service.Quota, error)
+	SetAPIKey(principal service.Principal, id string, req service.SetQuotaRequest) (service.Quota, error)
+}
+
+type QuotasHandler struct {
+	baseHandler
+	quotaService    *service.QuotaService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewQuotasHandler(
+	quotaService *service.QuotaService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *QuotasHandler {
+	return &QuotasHandler{
+		quotaService:    quotaService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+// Usage returns the quotas the links of the caller count against.
+func (h *QuotasHandler) Usage(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeQuotas)
+
+	quotas, err := h.quotaService.Usage(h.Principal(ctx))
+	if err != nil {
+		h.logger.LogError("get quota usage", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(quotas)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *QuotasHandler) Workspace(ctx *fasthttp.RequestCtx) {
+	h.get(ctx, "get workspace quota", h.quotaService.Workspace)
+}
+
+func (h *QuotasHandler) SetWorkspace(ctx *fasthttp.RequestCtx) {
+	h.set(ctx, "set workspace quota", h.quotaService.SetWorkspace)
+}
+
+func (h *QuotasHandler) APIKey(ctx *fasthttp.RequestCtx) {
+	h.get(ctx, "get api key quota", h.quotaService.APIKey)
+}
+
+func (h *QuotasHandler) SetAPIKey(ctx *fasthttp.RequestCtx) {
+	h.set(ctx, "set api key quota", h.quotaService.SetAPIKey)
+}
+
+func (h *QuotasHandler) get(
+	ctx *fasthttp.RequestCtx,
+	operation string,
+	get func(service.Principal, string) (service.Quota, error)) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeQuotas)
+
+	quota, err := get(h.Principal(ctx), ctx.UserValue("id").(string))
+	if err != nil {
+		h.logger.LogError(operation, err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(quota)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *QuotasHandler) set(
+	ctx *fasthttp.RequestCtx,
+	operation string,
+	set func(service.Principal, string, service.SetQuotaRequest) (service.Quota, error)) {
+	var req service.SetQuotaRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeQuotas)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	quota, err := set(h.Principal(ctx), ctx.UserValue("id").(string), req)
+	if err != nil {
+		h.logger.LogError(operation, err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	recordQuotas(h.metricsRecorder, service.Quotas{quota})
+
+	responseBody, _ := json.Marshal(quota)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
@@ -2,31 +2,54 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"time"
+	"url-shortener/internal/analytics"
 	"url-shortener/internal/logger"
 	"url-shortener/internal/metrics"
 	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/repository"
 	"url-shortener/internal/service"
+	"url-shortener/internal/transport/templates"
 
 	"github.com/valyala/fasthttp"
 )
 
+const continueParam = "continue"
+
 type IRedirectService interface {
-	Redirect(shortURL string) string
+	Redirect(host, shortURL string) (service.Destination, error)
 }
 
 type RedirectHandler struct {
 	baseHandler
+	cfg             RedirectHandlerConfig
 	redirectService *service.RedirectService
+	clicks          *analytics.Pipeline
+	bots            *analytics.BotClassifier
 	logger          *logger.Logger
 	metricsRecorder *prometheus.MetricsRecorder
 }
 
+type RedirectHandlerConfig struct {
+	// Interstitial page countdown.
+	Countdown time.Duration
+	// Request header carrying the visitor country code.
+	CountryHeader string
+}
+
 func NewRedirectHandler(
+	cfg RedirectHandlerConfig,
 	redirectService *service.RedirectService,
+	clicks *analytics.Pipeline,
+	bots *analytics.BotClassifier,
 	logger *logger.Logger,
 	metricsRecorder *prometheus.MetricsRecorder) *RedirectHandler {
 	return &RedirectHandler{
+		cfg:             cfg,
 		redirectService: redirectService,
+		clicks:          clicks,
+		bots:            bots,
 		logger:          logger,
 		metricsRecorder: metricsRecorder,
 	}
@@ -36,8 +59,103 @@ func (h *RedirectHandler) Redirect(ctx *fasthttp.RequestCtx) {
 	h.metricsRecorder.RecordRequest(metrics.EventTypeRedirect)
 
 	shortURL := ctx.UserValue("hash").(string)
-	url := h.redirectService.Redirect(shortURL)
+	destination, err := h.redirectService.Redirect(string(ctx.Host()), shortURL)
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	// A moderator's decision outranks the screening verdict.
+	if destination.Disabled {
+		h.blocked(ctx, templates.Disabled, templates.DisabledPage{Host: string(ctx.Host())})
+
+		return
+	}
+
+	if destination.Quarantined {
+		h.blocked(ctx, templates.Blocked, templates.BlockedPage{Host: string(ctx.Host())})
+
+		return
+	}
+
+	if destination.Interstitial {
+		if !ctx.QueryArgs().Has(continueParam) {
+			h.interstitial(ctx, destination.URL)
+
+			return
+		}
+
+		h.metricsRecorder.RecordInterstitialContinue()
+	}
+
+	ctx.Redirect(destination.URL, http.StatusFound)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
 
-	ctx.Redirect(url, http.StatusFound)
+	event := h.clickEvent(ctx, destination.Workspace, shortURL)
+	if event.Bot {
+		h.metricsRecorder.RecordBotClick()
+	}
+
+	h.clicks.Track(event)
+}
+
+func (h *RedirectHandler) clickEvent(ctx *fasthttp.RequestCtx, workspace, shortURL string) repository.ClickEvent {
+	return repository.ClickEvent{
+		Timestamp: ctx.Time().UTC(),
+		Workspace: workspace,
+		Code:      shortURL,
+		Referrer:  string(ctx.Referer()),
+		UserAgent: string(ctx.UserAgent()),
+		IP:        ClientIP(ctx),
+		Country:   string(ctx.Request.Header.Peek(h.cfg.CountryHeader)),
+		DoNotTrack: string(ctx.Request.Header.Peek("DNT")) == "1" ||
+			string(ctx.Request.Header.Peek("Sec-GPC")) == "1",
+		Bot: h.bots.IsBot(analytics.BotSignals{
+			Method:    string(ctx.Method()),
+			UserAgent: string(ctx.UserAgent()),
+			Header: func(name string) string {
+				return string(ctx.Request.Header.Peek(name))
+			},
+		}),
+	}
+}
+
+func (h *RedirectHandler) interstitial(ctx *fasthttp.RequestCtx, destination string) {
+	continueURL := url.URL{
+		Path:     string(ctx.Path()),
+		RawQuery: continueParam + "=1",
+	}
+
+	err := h.RespondHTML(ctx, templates.Interstitial, templates.InterstitialPage{
+		Host:             string(ctx.Host()),
+		Destination:      destination,
+		ContinueURL:      continueURL.String(),
+		CountdownSeconds: int(h.cfg.Countdown / time.Second),
+	})
+	if err != nil {
+		h.logger.LogError("render interstitial page", err)
+		h.RespondInternalError(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+
+		return
+	}
+
+	h.metricsRecorder.RecordInterstitialView()
 	h.metricsRecorder.RecordResponse(metrics.StatusOk)
 }
+
+// blocked tells the visitor the link was disabled, without revealing where
+// it led.
+func (h *RedirectHandler) blocked(ctx *fasthttp.RequestCtx, page string, data interface{}) {
+	if err := h.RespondHTML(ctx, page, data); err != nil {
+		h.logger.LogError("render blocked page", err)
+		h.RespondInternalError(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusForbidden)
+	h.metricsRecorder.RecordResponse(metrics.StatusForbidden)
+}
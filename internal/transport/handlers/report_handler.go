@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/templates"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type AbuseReporter interface {
+	Reportable(host, code string) error
+	Report(host, code, reporterIP string, req service.ReportRequest) error
+}
+
+// ReportHandler takes abuse reports from visitors, as a form for browsers
+// and as JSON for clients sending it.
+type ReportHandler struct {
+	baseHandler
+	moderationService *service.ModerationService
+	logger            *logger.Logger
+	metricsRecorder   *prometheus.MetricsRecorder
+}
+
+func NewReportHandler(
+	moderationService *service.ModerationService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *ReportHandler {
+	return &ReportHandler{
+		moderationService: moderationService,
+		logger:            logger,
+		metricsRecorder:   metricsRecorder,
+	}
+}
+
+// Form renders the report form of the link.
+func (h *ReportHandler) Form(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeReport)
+
+	code := ctx.UserValue("hash").(string)
+
+	if err := h.moderationService.Reportable(string(ctx.Host()), code); err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	h.render(ctx, h.page(ctx, code), http.StatusOK)
+}
+
+func (h *ReportHandler) Report(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeReport)
+
+	code := ctx.UserValue("hash").(string)
+	isJSON := bytes.HasPrefix(ctx.Request.Header.ContentType(), []byte(jsonContentType))
+
+	var req service.ReportRequest
+
+	if isJSON {
+		if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+			h.RespondBadRequest(ctx)
+			h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+			return
+		}
+	} else {
+		args := ctx.PostArgs()
+		req = service.ReportRequest{
+			Reason:  service.ReportReason(args.Peek("reason")),
+			Details: string(args.Peek("details")),
+			Email:   string(args.Peek("email")),
+		}
+	}
+
+	err := h.moderationService.Report(string(ctx.Host()), code, ClientIP(ctx), req)
+
+	invalid := errors.Is(err, service.ErrInvalidRequest)
+
+	// Invalid forms are shown again with what was typed in.
+	if err != nil && (isJSON || !invalid) {
+		if !invalid && !errors.Is(err, service.ErrLinkNotFound) {
+			h.logger.LogError("report link", err)
+		}
+
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	if err == nil {
+		h.metricsRecorder.RecordAbuseReport(string(req.Reason))
+	}
+
+	if isJSON {
+		ctx.SetStatusCode(http.StatusAccepted)
+		h.metricsRecorder.RecordResponse(metrics.StatusAccepted)
+
+		return
+	}
+
+	page := h.page(ctx, code)
+	page.Reason, page.Details, page.Email = req.Reason, req.Details, req.Email
+
+	if err != nil {
+		page.Error = strings.TrimPrefix(err.Error(), service.ErrInvalidRequest.Error()+": ")
+		h.render(ctx, page, http.StatusBadRequest)
+
+		return
+	}
+
+	page.Sent = true
+	h.render(ctx, page, http.StatusOK)
+}
+
+func (h *ReportHandler) page(ctx *fasthttp.RequestCtx, code string) templates.ReportPage {
+	return templates.ReportPage{
+		Host:       string(ctx.Host()),
+		Code:       code,
+		Reasons:    service.ReportReasons,
+		MaxDetails: h.moderationService.MaxDetailsLength(),
+	}
+}
+
+func (h *ReportHandler) render(ctx *fasthttp.RequestCtx, page templates.ReportPage, status int) {
+	if err := h.RespondHTML(ctx, templates.Report, page); err != nil {
+		h.logger.LogError("render report page", err)
+		h.RespondInternalError(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusInternalError)
+
+		return
+	}
+
+	ctx.SetStatusCode(status)
+
+	if status == http.StatusBadRequest {
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type StatsReader interface {
+	LinkStats(req service.StatsRequest) (service.LinkStats, error)
+	TopLinks(req service.TopLinksRequest) (service.TopLinks, error)
+}
+
+type StatsHandler struct {
+	baseHandler
+	statsService    *service.StatsService
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewStatsHandler(
+	statsService *service.StatsService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *StatsHandler {
+	return &StatsHandler{
+		statsService:    statsService,
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (h *StatsHandler) LinkStats(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeStats)
+
+	from, errFrom := parseTime(ctx.QueryArgs().Peek("from"))
+	to, errTo := parseTime(ctx.QueryArgs().Peek("to"))
+
+	if errFrom != nil || errTo != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	stats, err := h.statsService.LinkStats(service.StatsRequest{
+		Principal: h.Principal(ctx),
+		Code:      ctx.UserValue("code").(string),
+		From:      from,
+		To:        to,
+		Interval:  string(ctx.QueryArgs().Peek("interval")),
+	})
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(stats)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *StatsHandler) TopLinks(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeStats)
+
+	limit, _ := ctx.QueryArgs().GetUint("limit")
+
+	top, err := h.statsService.TopLinks(service.TopLinksRequest{
+		Principal: h.Principal(ctx),
+		Window:    string(ctx.QueryArgs().Peek("window")),
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(top)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+// parseTime accepts RFC 3339 timestamps and unix seconds, empty values
+// yield the zero time.
+func parseTime(value []byte) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, string(value)); err == nil {
+		return t, nil
+	}
+
+	seconds, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time %q: %w", value, err)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
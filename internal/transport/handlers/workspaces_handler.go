@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+
+	json "github.com/json-iterator/go"
+	"github.com/valyala/fasthttp"
+)
+
+type WorkspaceManager interface {
+	Create(principal service.Principal, req service.CreateWorkspaceRequest) (service.Workspace, error)
+	List(caller string) ([]service.Workspace, error)
+	AddDomain(principal service.Principal, id, domain string) (service.Workspace, error)
+}
+
+type WorkspacesHandler struct {
+	baseHandler
+	workspaceService *service.WorkspaceService
+	logger           *logger.Logger
+	metricsRecorder  *prometheus.MetricsRecorder
+}
+
+type addDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+func NewWorkspacesHandler(
+	workspaceService *service.WorkspaceService,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) *WorkspacesHandler {
+	return &WorkspacesHandler{
+		workspaceService: workspaceService,
+		logger:           logger,
+		metricsRecorder:  metricsRecorder,
+	}
+}
+
+func (h *WorkspacesHandler) Create(ctx *fasthttp.RequestCtx) {
+	var req service.CreateWorkspaceRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeWorkspaces)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	workspace, err := h.workspaceService.Create(h.Principal(ctx), req)
+	if err != nil {
+		h.logger.LogError("create workspace", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(workspace)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *WorkspacesHandler) List(ctx *fasthttp.RequestCtx) {
+	h.metricsRecorder.RecordRequest(metrics.EventTypeWorkspaces)
+
+	workspaces, err := h.workspaceService.List(h.Workspace(ctx))
+	if err != nil {
+		h.logger.LogError("list workspaces", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(workspaces)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
+
+func (h *WorkspacesHandler) AddDomain(ctx *fasthttp.RequestCtx) {
+	var req addDomainRequest
+	h.metricsRecorder.RecordRequest(metrics.EventTypeWorkspaces)
+
+	if err := json.Unmarshal(ctx.Request.Body(), &req); err != nil {
+		h.RespondBadRequest(ctx)
+		h.metricsRecorder.RecordResponse(metrics.StatusBadRequest)
+
+		return
+	}
+
+	workspace, err := h.workspaceService.AddDomain(h.Principal(ctx), ctx.UserValue("id").(string), req.Domain)
+	if err != nil {
+		h.logger.LogError("add workspace domain", err)
+		h.metricsRecorder.RecordResponse(h.RespondError(ctx, err))
+
+		return
+	}
+
+	responseBody, _ := json.Marshal(workspace)
+
+	h.RespondOK(ctx, responseBody)
+	h.metricsRecorder.RecordResponse(metrics.StatusOk)
+}
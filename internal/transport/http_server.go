@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"bytes"
 	"time"
 	"url-shortener/internal/logger"
 
@@ -12,12 +13,29 @@ const (
 	DefaultTimeout        = 10 * time.Second
 )
 
-func NewFastHTTPServer(handler fasthttp.RequestHandler, logger *logger.Logger) (server *fasthttp.Server, cleanup func()) {
+var (
+	eventStreamPrefix = []byte("/api/v1/")
+	eventStreamSuffix = []byte("/events")
+)
+
+type FastHTTPServerConfig struct {
+	// Write timeout of the long-lived click event streams.
+	StreamWriteTimeout time.Duration
+}
+
+func NewFastHTTPServer(cfg FastHTTPServerConfig, handler fasthttp.RequestHandler, logger *logger.Logger) (server *fasthttp.Server, cleanup func()) {
 	server = &fasthttp.Server{
 		Handler:        handler,
 		ReadBufferSize: DefaultReadBufferSize,
 		ReadTimeout:    DefaultTimeout,
 		WriteTimeout:   DefaultTimeout,
+		HeaderReceived: func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+			if isEventStream(header.RequestURI()) {
+				return fasthttp.RequestConfig{WriteTimeout: cfg.StreamWriteTimeout}
+			}
+
+			return fasthttp.RequestConfig{}
+		},
 	}
 	cleanup = func() {
 		logger.LogInfo("shuts down gracefully")
@@ -29,3 +47,13 @@ func NewFastHTTPServer(handler fasthttp.RequestHandler, logger *logger.Logger) (
 
 	return
 }
+
+// isEventStream tells the click event streams apart from the rest of the
+// API, by the path of the request without its query.
+func isEventStream(uri []byte) bool {
+	if i := bytes.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+
+	return bytes.HasPrefix(uri, eventStreamPrefix) && bytes.HasSuffix(uri, eventStreamSuffix)
+}
@@ -0,0 +1,25 @@
+package transport
+
+import "testing"
+
+func TestIsEventStream(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{uri: "/api/v1/events", want: true},
+		{uri: "/api/v1/events?since=1", want: true},
+		{uri: "/api/v1/links/abc/events", want: true},
+		{uri: "/api/v1/links/abc/events?a=b/events", want: true},
+		{uri: "/events", want: false},
+		{uri: "/abc/events", want: false},
+		{uri: "/api/v1/links?q=/events", want: false},
+		{uri: "/api/v1/stats/top", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isEventStream([]byte(tt.uri)); got != tt.want {
+			t.Errorf("isEventStream(%q) = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/ratelimit"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// RateLimitByIP counts requests by client address.
+	RateLimitByIP = "ip"
+	// RateLimitByAPIKey counts requests by API key or SSO user, requests
+	// without a token by client address.
+	RateLimitByAPIKey = "api_key"
+	// RateLimitByWorkspace counts requests by the workspace of the token, or
+	// by Host header for requests without one.
+	RateLimitByWorkspace = "workspace"
+
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+type RateLimitPolicy struct {
+	ratelimit.Policy
+	// What requests are counted by, see the RateLimitBy constants.
+	Key string
+}
+
+type RateLimitConfig struct {
+	Enabled  bool
+	Create   RateLimitPolicy
+	Redirect RateLimitPolicy
+	Report   RateLimitPolicy
+}
+
+// RateLimiter throttles the create, redirect and report routes, throttled
+// requests get a 429 with a Retry-After header.
+type RateLimiter struct {
+	cfg             RateLimitConfig
+	limiter         *ratelimit.Limiter
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewRateLimiter(
+	cfg RateLimitConfig,
+	limiter *ratelimit.Limiter,
+	metricsRecorder *prometheus.MetricsRecorder) *RateLimiter {
+	return &RateLimiter{
+		cfg:             cfg,
+		limiter:         limiter,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+// LimitCreate applies the create policy. It runs after authentication so
+// the policy can count by API key or workspace.
+func (l *RateLimiter) LimitCreate(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return l.limit(l.cfg.Create, next)
+}
+
+func (l *RateLimiter) LimitRedirect(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return l.limit(l.cfg.Redirect, next)
+}
+
+func (l *RateLimiter) LimitReport(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return l.limit(l.cfg.Report, next)
+}
+
+func (l *RateLimiter) limit(policy RateLimitPolicy, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !l.cfg.Enabled || policy.Rate <= 0 {
+		return next
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		result := l.limiter.Allow(policy.Policy, rateLimitKey(ctx, policy.Key))
+
+		ctx.Response.Header.Set(headerRateLimitLimit, strconv.Itoa(result.Limit))
+		ctx.Response.Header.Set(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+		ctx.Response.Header.Set(headerRateLimitReset, seconds(result.Reset))
+
+		if !result.Allowed {
+			ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, seconds(result.RetryAfter))
+			ctx.SetStatusCode(http.StatusTooManyRequests)
+			l.metricsRecorder.RecordResponse(metrics.StatusTooManyRequests)
+			l.metricsRecorder.RecordRateLimited(policy.Name)
+
+			return
+		}
+
+		next(ctx)
+	}
+}
+
+func rateLimitKey(ctx *fasthttp.RequestCtx, key string) string {
+	principal, authenticated := ctx.UserValue(handlers.PrincipalUserValue).(service.Principal)
+
+	switch {
+	case key == RateLimitByAPIKey && authenticated:
+		return "principal:" + principal.Subject()
+	case key == RateLimitByWorkspace && authenticated:
+		return "workspace:" + principal.Workspace
+	case key == RateLimitByWorkspace:
+		return "host:" + string(ctx.Host())
+	default:
+		return "ip:" + handlers.ClientIP(ctx)
+	}
+}
+
+// seconds rounds up, so clients never retry too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	headerRequestID = "X-Request-ID"
+
+	requestIDBytes     = 12
+	maxRequestIDLength = 128
+)
+
+// RequestID tags every request with an id, taken from the X-Request-ID
+// header when one of the trusted proxies sent a usable one. Ids sent by
+// anyone else are replaced, they could be picked to collide with other
+// requests in the logs and the audit log. The id is echoed in the response
+// and recorded with the audit entries of the request.
+func RequestID(proxies *TrustedProxies, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		var id string
+		if proxies.Trusts(ctx.RemoteIP()) {
+			id = string(ctx.Request.Header.Peek(headerRequestID))
+		}
+
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		ctx.SetUserValue(handlers.RequestIDUserValue, id)
+		ctx.Response.Header.Set(headerRequestID, id)
+
+		next(ctx)
+	}
+}
+
+func newRequestID() string {
+	buf := make([]byte, requestIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID keeps ids to characters that are safe in logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
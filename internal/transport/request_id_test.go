@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"net"
+	"testing"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRequestID(t *testing.T) {
+	proxies, err := NewTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		peer     string
+		header   string
+		wantKept bool
+	}{
+		{name: "trusted proxy", peer: "10.0.0.2", header: "req-42", wantKept: true},
+		{name: "untrusted client", peer: "203.0.113.7", header: "req-42"},
+		{name: "trusted proxy with an unsafe id", peer: "10.0.0.2", header: "req 42\r\nX-Admin: 1"},
+		{name: "trusted proxy without an id", peer: "10.0.0.2"},
+		{name: "untrusted client without an id", peer: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			if tt.header != "" {
+				req.Header.Set(headerRequestID, tt.header)
+			}
+
+			var ctx fasthttp.RequestCtx
+			ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 1234}, nil)
+
+			var got string
+			RequestID(proxies, func(ctx *fasthttp.RequestCtx) {
+				got, _ = ctx.UserValue(handlers.RequestIDUserValue).(string)
+			})(&ctx)
+
+			if kept := got == tt.header; kept != tt.wantKept {
+				t.Fatalf("id = %q, header %q kept = %v, want %v", got, tt.header, kept, tt.wantKept)
+			}
+
+			if !validRequestID(got) {
+				t.Errorf("id = %q is not a valid id", got)
+			}
+
+			if echoed := string(ctx.Response.Header.Peek(headerRequestID)); echoed != got {
+				t.Errorf("echoed id = %q, want %q", echoed, got)
+			}
+		})
+	}
+}
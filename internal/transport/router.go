@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"url-shortener/internal/service"
 	"url-shortener/internal/transport/handlers"
 
 	"github.com/fasthttp/router"
@@ -8,23 +9,121 @@ import (
 )
 
 type FastHTTPHandlers struct {
-	CreateHandler   *handlers.CreateHandler
-	RedirectHandler *handlers.RedirectHandler
+	CreateHandler     *handlers.CreateHandler
+	RedirectHandler   *handlers.RedirectHandler
+	PreviewHandler    *handlers.PreviewHandler
+	StatsHandler      *handlers.StatsHandler
+	EventsHandler     *handlers.EventsHandler
+	PrivacyHandler    *handlers.PrivacyHandler
+	APIKeysHandler    *handlers.APIKeysHandler
+	WorkspacesHandler *handlers.WorkspacesHandler
+	LinksHandler      *handlers.LinksHandler
+	MembersHandler    *handlers.MembersHandler
+	ReportHandler     *handlers.ReportHandler
+	ModerationHandler *handlers.ModerationHandler
+	AuditHandler      *handlers.AuditHandler
+	QuotasHandler     *handlers.QuotasHandler
 }
 
-func NewFastHTTPHandlers(createHandler *handlers.CreateHandler, redirectHandler *handlers.RedirectHandler) *FastHTTPHandlers {
+func NewFastHTTPHandlers(
+	createHandler *handlers.CreateHandler,
+	redirectHandler *handlers.RedirectHandler,
+	previewHandler *handlers.PreviewHandler,
+	statsHandler *handlers.StatsHandler,
+	eventsHandler *handlers.EventsHandler,
+	privacyHandler *handlers.PrivacyHandler,
+	apiKeysHandler *handlers.APIKeysHandler,
+	workspacesHandler *handlers.WorkspacesHandler,
+	linksHandler *handlers.LinksHandler,
+	membersHandler *handlers.MembersHandler,
+	reportHandler *handlers.ReportHandler,
+	moderationHandler *handlers.ModerationHandler,
+	auditHandler *handlers.AuditHandler,
+	quotasHandler *handlers.QuotasHandler) *FastHTTPHandlers {
 	return &FastHTTPHandlers{
-		CreateHandler:   createHandler,
-		RedirectHandler: redirectHandler,
+		CreateHandler:     createHandler,
+		RedirectHandler:   redirectHandler,
+		PreviewHandler:    previewHandler,
+		StatsHandler:      statsHandler,
+		EventsHandler:     eventsHandler,
+		PrivacyHandler:    privacyHandler,
+		APIKeysHandler:    apiKeysHandler,
+		WorkspacesHandler: workspacesHandler,
+		LinksHandler:      linksHandler,
+		MembersHandler:    membersHandler,
+		ReportHandler:     reportHandler,
+		ModerationHandler: moderationHandler,
+		AuditHandler:      auditHandler,
+		QuotasHandler:     quotasHandler,
 	}
 }
 
-func NewFastHTTPRouter(h *FastHTTPHandlers) fasthttp.RequestHandler {
+// NewFastHTTPRouter registers the routes. The API acts in the workspace of
+// the caller's token, the public routes in the one the Host header names.
+func NewFastHTTPRouter(
+	h *FastHTTPHandlers,
+	auth *Authenticator,
+	limits *RateLimiter,
+	codes *CodeVerifier,
+	scans *ScanGuard,
+	cors *CORS,
+	adminCerts *ClientCertGuard,
+	proxies *TrustedProxies) fasthttp.RequestHandler {
 
 	r := router.New()
 
-	r.POST("/create", h.CreateHandler.Create)
-	r.GET("/{hash}", h.RedirectHandler.Redirect)
+	admin := func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return adminCerts.Require(auth.Require(service.ScopeAdmin, next))
+	}
+
+	r.POST("/create", auth.Require(service.ScopeCreate, limits.LimitCreate(h.CreateHandler.Create)))
+	r.GET("/api/v1/links", auth.Require(service.ScopeRead, h.LinksHandler.List))
+	r.PATCH("/api/v1/links/{code}", auth.Require(service.ScopeManage, h.LinksHandler.Update))
+	r.DELETE("/api/v1/links/{code}", auth.Require(service.ScopeManage, h.LinksHandler.Delete))
+	r.POST("/api/v1/links/{code}/claim", auth.Require(service.ScopeCreate, h.LinksHandler.Claim))
+	r.GET("/api/v1/links/{code}/shares", auth.Require(service.ScopeRead, h.LinksHandler.Shares))
+	r.PUT("/api/v1/links/{code}/shares/{user}", auth.Require(service.ScopeManage, h.LinksHandler.Share))
+	r.DELETE("/api/v1/links/{code}/shares/{user}", auth.Require(service.ScopeManage, h.LinksHandler.Unshare))
+	r.GET("/api/v1/links/{code}/stats", auth.Require(service.ScopeRead, h.StatsHandler.LinkStats))
+	r.GET("/api/v1/stats/top", auth.Require(service.ScopeRead, h.StatsHandler.TopLinks))
+	r.GET("/api/v1/links/{code}/events", auth.Require(service.ScopeRead, h.EventsHandler.LinkEvents))
+	r.GET("/api/v1/events", auth.Require(service.ScopeRead, h.EventsHandler.AllEvents))
+	r.DELETE("/api/v1/links/{code}/analytics", auth.Require(service.ScopeManage, h.PrivacyHandler.EraseLinkAnalytics))
+	r.DELETE("/api/v1/owners/{owner}/analytics", auth.Require(service.ScopeManage, h.PrivacyHandler.EraseOwnerAnalytics))
+	r.POST("/api/v1/keys", admin(h.APIKeysHandler.Create))
+	r.GET("/api/v1/keys", admin(h.APIKeysHandler.List))
+	r.DELETE("/api/v1/keys/{id}", admin(h.APIKeysHandler.Revoke))
+	r.GET("/api/v1/keys/{id}/quota", admin(h.QuotasHandler.APIKey))
+	r.PUT("/api/v1/keys/{id}/quota", admin(h.QuotasHandler.SetAPIKey))
+	r.GET("/api/v1/members", admin(h.MembersHandler.List))
+	r.PUT("/api/v1/members/{user}", admin(h.MembersHandler.Set))
+	r.DELETE("/api/v1/members/{user}", admin(h.MembersHandler.Remove))
+	r.POST("/api/v1/workspaces", admin(h.WorkspacesHandler.Create))
+	r.GET("/api/v1/workspaces", admin(h.WorkspacesHandler.List))
+	r.POST("/api/v1/workspaces/{id}/domains", admin(h.WorkspacesHandler.AddDomain))
+	r.GET("/api/v1/workspaces/{id}/quota", admin(h.QuotasHandler.Workspace))
+	r.PUT("/api/v1/workspaces/{id}/quota", admin(h.QuotasHandler.SetWorkspace))
+	r.GET("/api/v1/quota", auth.Require(service.ScopeRead, h.QuotasHandler.Usage))
+	r.GET("/api/v1/audit", admin(h.AuditHandler.Query))
+	r.GET("/api/v1/audit/export", admin(h.AuditHandler.Export))
+	r.GET("/api/v1/reports", admin(h.ModerationHandler.Queue))
+	r.GET("/api/v1/links/{code}/reports", admin(h.ModerationHandler.Link))
+	r.POST("/api/v1/links/{code}/disable", admin(h.ModerationHandler.Disable))
+	r.POST("/api/v1/links/{code}/restore", admin(h.ModerationHandler.Restore))
+	redirect := limits.LimitRedirect(scans.Guard(codes.Verify(func(ctx *fasthttp.RequestCtx) {
+		if handlers.IsPreviewRequest(ctx) {
+			h.PreviewHandler.Preview(ctx)
+
+			return
+		}
+
+		h.RedirectHandler.Redirect(ctx)
+	})))
+
+	r.GET("/{hash}", redirect)
+	r.HEAD("/{hash}", redirect)
+	r.GET("/{hash}/report", limits.LimitRedirect(scans.Guard(codes.Verify(h.ReportHandler.Form))))
+	r.POST("/{hash}/report", limits.LimitReport(scans.Guard(codes.Verify(h.ReportHandler.Report))))
 
-	return r.Handler
+	return proxies.ClientIP(RequestID(proxies, cors.Handle(r.Handler)))
 }
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/scandetect"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ScanGuard puts the public short link routes behind the scan detector,
+// clients are told apart by their address behind the trusted proxies.
+// Blocked clients get a 429 with a Retry-After header, slowed down ones wait
+// before being served.
+type ScanGuard struct {
+	detector        *scandetect.Detector
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewScanGuard(detector *scandetect.Detector, metricsRecorder *prometheus.MetricsRecorder) *ScanGuard {
+	return &ScanGuard{
+		detector:        detector,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (g *ScanGuard) Guard(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !g.detector.Enabled() {
+		return next
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		client := handlers.ClientIP(ctx)
+
+		decision := g.detector.Check(client)
+
+		switch decision.Action {
+		case scandetect.ActionBlock:
+			ctx.Response.Header.Set(fasthttp.HeaderRetryAfter, seconds(decision.RetryAfter))
+			ctx.SetStatusCode(http.StatusTooManyRequests)
+			g.metricsRecorder.RecordResponse(metrics.StatusTooManyRequests)
+			g.metricsRecorder.RecordScannerRequest(string(decision.Action))
+
+			return
+		case scandetect.ActionSlow:
+			g.metricsRecorder.RecordScannerRequest(string(decision.Action))
+			time.Sleep(g.detector.SlowDelay())
+		}
+
+		next(ctx)
+
+		// The code verifier leaves the code without its signature.
+		code, _ := ctx.UserValue("hash").(string)
+		code = strings.TrimSuffix(code, handlers.PreviewSuffix)
+
+		action := g.detector.Observe(client, code, ctx.Response.StatusCode() == http.StatusNotFound)
+		if action != scandetect.ActionAllow {
+			g.metricsRecorder.RecordScannerDetected(string(action))
+		}
+	}
+}
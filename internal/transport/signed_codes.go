@@ -0,0 +1,51 @@
+package transport
+
+import (
+	"net/http"
+	"strings"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+	"url-shortener/internal/service"
+	"url-shortener/internal/transport/handlers"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CodeVerifier guards the public short link routes when codes are signed.
+// Codes with a bad signature get a 404 without touching storage, valid ones
+// reach the handlers without their signature.
+type CodeVerifier struct {
+	signer          *service.CodeSigner
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewCodeVerifier(signer *service.CodeSigner, metricsRecorder *prometheus.MetricsRecorder) *CodeVerifier {
+	return &CodeVerifier{
+		signer:          signer,
+		metricsRecorder: metricsRecorder,
+	}
+}
+
+func (v *CodeVerifier) Verify(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		value, _ := ctx.UserValue("hash").(string)
+
+		// The preview suffix follows the signature.
+		suffix := ""
+		if strings.HasSuffix(value, handlers.PreviewSuffix) {
+			value, suffix = strings.TrimSuffix(value, handlers.PreviewSuffix), handlers.PreviewSuffix
+		}
+
+		code, ok := v.signer.Verify(value)
+		if !ok {
+			ctx.SetStatusCode(http.StatusNotFound)
+			v.metricsRecorder.RecordResponse(metrics.StatusNotFound)
+			v.metricsRecorder.RecordSignatureRejected()
+
+			return
+		}
+
+		ctx.SetUserValue("hash", code+suffix)
+		next(ctx)
+	}
+}
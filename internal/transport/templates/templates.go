@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"url-shortener/internal/service"
+)
+
+const (
+	Interstitial = "interstitial.html"
+	Preview      = "preview.html"
+	Blocked      = "blocked.html"
+	Disabled     = "disabled.html"
+	Report       = "report.html"
+)
+
+//go:embed *.html
+var files embed.FS
+
+var pages = template.Must(template.ParseFS(files, "*.html"))
+
+type InterstitialPage struct {
+	Host             string
+	Destination      string
+	ContinueURL      string
+	CountdownSeconds int
+}
+
+type BlockedPage struct {
+	Host string
+}
+
+type DisabledPage struct {
+	Host string
+}
+
+// ReportPage is the abuse report form, or its confirmation once Sent.
+type ReportPage struct {
+	Host       string
+	Code       string
+	Reasons    []service.ReportReason
+	MaxDetails int
+	Sent       bool
+	Error      string
+	Reason     service.ReportReason
+	Details    string
+	Email      string
+}
+
+type PreviewPage struct {
+	Host    string
+	Preview service.Preview
+}
+
+func Render(w io.Writer, name string, data interface{}) error {
+	return pages.ExecuteTemplate(w, name, data)
+}
@@ -0,0 +1,331 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/metrics/prometheus"
+
+	"github.com/valyala/fasthttp"
+)
+
+const DefaultCertReloadInterval = time.Minute
+
+var ErrTLSConfig = errors.New("invalid tls config")
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type TLSConfig struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
+	// Oldest protocol version accepted, "1.2" or "1.3".
+	MinVersion string
+	// TLS 1.2 cipher suites offered, by their Go names, in order of
+	// preference. Empty keeps the Go defaults, TLS 1.3 suites are fixed.
+	CipherSuites []string
+	// Client certificates are verified against this CA bundle when set,
+	// ClientAuth tells whether clients must present one.
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+	// How often the files are checked for changes, SIGHUP reloads them right
+	// away.
+	ReloadInterval time.Duration
+}
+
+// CertReloader serves TLS with the certificate, key and client CA bundle
+// read from disk, reloading them when they change or on SIGHUP. New
+// handshakes pick up the reloaded files, open connections are left alone. A
+// failed reload keeps the previous files in use.
+type CertReloader struct {
+	server          string
+	cfg             TLSConfig
+	minVersion      uint16
+	cipherSuites    []uint16
+	current         atomic.Value
+	modTimes        map[string]time.Time
+	logger          *logger.Logger
+	metricsRecorder *prometheus.MetricsRecorder
+	stop            chan struct{}
+}
+
+// NewCertReloader loads the files of the server, named in logs and metrics,
+// and fails when they or the settings are invalid.
+func NewCertReloader(
+	server string,
+	cfg TLSConfig,
+	logger *logger.Logger,
+	metricsRecorder *prometheus.MetricsRecorder) (*CertReloader, error) {
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = DefaultCertReloadInterval
+	}
+
+	c := &CertReloader{
+		server:          server,
+		cfg:             cfg,
+		modTimes:        map[string]time.Time{},
+		logger:          logger,
+		metricsRecorder: metricsRecorder,
+		stop:            make(chan struct{}),
+	}
+
+	if !cfg.Enabled {
+		return c, nil
+	}
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("%w: %s needs a certificate and a key", ErrTLSConfig, server)
+	}
+
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported minimum version %q", ErrTLSConfig, cfg.MinVersion)
+	}
+
+	c.minVersion = minVersion
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	for _, name := range cfg.CipherSuites {
+		id, found := suites[name]
+		if !found {
+			return nil, fmt.Errorf("%w: unknown or insecure cipher suite %q", ErrTLSConfig, name)
+		}
+
+		c.cipherSuites = append(c.cipherSuites, id)
+	}
+
+	c.modTimes = c.statFiles()
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *CertReloader) Enabled() bool {
+	return c.cfg.Enabled
+}
+
+// VerifiesClientCerts tells whether clients may present certificates to be
+// verified.
+func (c *CertReloader) VerifiesClientCerts() bool {
+	return c.cfg.Enabled && c.cfg.ClientCAFile != "" && c.cfg.ClientAuth != tls.NoClientCert
+}
+
+func (c *CertReloader) Scheme() string {
+	if c.cfg.Enabled {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Listen listens on the address, serving TLS when enabled.
+func (c *CertReloader) Listen(network, addr string) (net.Listener, error) {
+	ln, err := net.Listen(network, addr)
+	if err != nil || !c.cfg.Enabled {
+		return ln, err
+	}
+
+	return tls.NewListener(ln, &tls.Config{
+		MinVersion: c.minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.current.Load().(*tls.Config), nil
+		},
+	}), nil
+}
+
+// Reload reads the files again and uses them for new handshakes.
+func (c *CertReloader) Reload() error {
+	config, notAfter, err := c.load()
+	if err != nil {
+		c.metricsRecorder.RecordCertReload(c.server, metrics.CertReloadFailed)
+
+		return err
+	}
+
+	c.current.Store(config)
+	c.metricsRecorder.RecordCertReload(c.server, metrics.CertReloaded)
+	c.metricsRecorder.SetCertExpiry(c.server, notAfter)
+
+	return nil
+}
+
+// Run checks the files every ReloadInterval and reloads them when they
+// changed, or right away on SIGHUP.
+func (c *CertReloader) Run() error {
+	if !c.cfg.Enabled {
+		<-c.stop
+
+		return nil
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(c.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			modTimes := c.statFiles()
+			if !c.changed(modTimes) {
+				continue
+			}
+
+			// Remember the attempt, a half written pair is retried once the
+			// other file changes too.
+			c.modTimes = modTimes
+			c.reload("files changed")
+		case <-hangup:
+			c.modTimes = c.statFiles()
+			c.reload("SIGHUP")
+		case <-c.stop:
+			return nil
+		}
+	}
+}
+
+func (c *CertReloader) Stop() {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+}
+
+func (c *CertReloader) reload(reason string) {
+	if err := c.Reload(); err != nil {
+		c.logger.LogError(c.server+" tls certificate reload failed, keeping the previous one", err)
+
+		return
+	}
+
+	c.logger.LogInfo("tls certificate reloaded", c.server, reason)
+}
+
+func (c *CertReloader) load() (*tls.Config, time.Time, error) {
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: load %s certificate: %v", ErrTLSConfig, c.server, err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: parse %s certificate: %v", ErrTLSConfig, c.server, err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   c.minVersion,
+		CipherSuites: c.cipherSuites,
+	}
+
+	if c.cfg.ClientCAFile != "" {
+		bundle, errRead := os.ReadFile(c.cfg.ClientCAFile)
+		if errRead != nil {
+			return nil, time.Time{}, fmt.Errorf("%w: read %s client CA: %v", ErrTLSConfig, c.server, errRead)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, time.Time{}, fmt.Errorf("%w: no certificate in %s client CA", ErrTLSConfig, c.server)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = c.cfg.ClientAuth
+	}
+
+	return config, leaf.NotAfter, nil
+}
+
+func (c *CertReloader) statFiles() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+
+	for _, file := range []string{c.cfg.CertFile, c.cfg.KeyFile, c.cfg.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+func (c *CertReloader) changed(modTimes map[string]time.Time) bool {
+	if len(modTimes) != len(c.modTimes) {
+		return true
+	}
+
+	for file, modTime := range modTimes {
+		if !modTime.Equal(c.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCertGuard puts the admin API behind client certificates, on top of
+// the bearer token. Requests without a certificate verified against the
+// client CA get a 403.
+type ClientCertGuard struct {
+	required        bool
+	metricsRecorder *prometheus.MetricsRecorder
+}
+
+func NewClientCertGuard(
+	required bool,
+	certs *CertReloader,
+	metricsRecorder *prometheus.MetricsRecorder) (*ClientCertGuard, error) {
+	if required && !certs.VerifiesClientCerts() {
+		return nil, fmt.Errorf("%w: client certificates need tls and a client CA", ErrTLSConfig)
+	}
+
+	return &ClientCertGuard{
+		required:        required,
+		metricsRecorder: metricsRecorder,
+	}, nil
+}
+
+func (g *ClientCertGuard) Require(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if !g.required {
+		return next
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		state := ctx.TLSConnectionState()
+		if state == nil || len(state.VerifiedChains) == 0 {
+			ctx.SetStatusCode(http.StatusForbidden)
+			g.metricsRecorder.RecordResponse(metrics.StatusForbidden)
+			g.metricsRecorder.RecordAuthRejected(metrics.AuthClientCert)
+
+			return
+		}
+
+		next(ctx)
+	}
+}
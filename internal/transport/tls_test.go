@@ -0,0 +1,414 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+	"url-shortener/internal/logger"
+	"url-shortener/internal/metrics/prometheus"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCert issues a certificate for the name, self-signed when there is
+// no parent.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// writeFile writes the file, moving its modification time forward so that a
+// rewrite within the file system's time granularity is seen as a change.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeCert writes the certificate and key of a new self-signed
+// certificate for the name.
+func writeCert(t *testing.T, cfg TLSConfig, name string) {
+	t.Helper()
+
+	cert := newTestCert(t, name, nil)
+	writeFile(t, cfg.CertFile, cert.pem)
+	writeFile(t, cfg.KeyFile, cert.keyPEM(t))
+}
+
+func testTLSConfig(t *testing.T) TLSConfig {
+	dir := t.TempDir()
+
+	return TLSConfig{
+		Enabled:    true,
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		MinVersion: "1.2",
+	}
+}
+
+// testTLSConfigWithCert is testTLSConfig with a certificate for 127.0.0.1.
+func testTLSConfigWithCert(t *testing.T) TLSConfig {
+	cfg := testTLSConfig(t)
+	writeCert(t, cfg, "127.0.0.1")
+
+	return cfg
+}
+
+func newTestCertReloader(t *testing.T, cfg TLSConfig) *CertReloader {
+	t.Helper()
+
+	certs, err := NewCertReloader(
+		"http", cfg, logger.NewLogger(zap.NewNop()), prometheus.NewMetricsRecorder(prometheus.MetricsConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certs
+}
+
+// serveTLS completes the handshakes of the connections to the listener of
+// the reloader and returns its address.
+func serveTLS(t *testing.T, certs *CertReloader) string {
+	ln, err := certs.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, errAccept := ln.Accept()
+			if errAccept != nil {
+				return
+			}
+
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// servedName returns the common name of the certificate a new handshake
+// gets.
+func servedName(t *testing.T, addr string) string {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+// waitForName waits for new handshakes to get the certificate for the name.
+func waitForName(t *testing.T, addr, name string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, addr) != name {
+		if time.Now().After(deadline) {
+			t.Fatalf("served %q, want %q", servedName(t, addr), name)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNewCertReloader(t *testing.T) {
+	cfg := testTLSConfig(t)
+	writeCert(t, cfg, "old")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, newTestCert(t, "ca", nil).pem)
+
+	noCAFile := filepath.Join(t.TempDir(), "empty.pem")
+	writeFile(t, noCAFile, []byte("no certificate here\n"))
+
+	// A disabled reloader takes any config.
+	newTestCertReloader(t, TLSConfig{MinVersion: "1.0", CipherSuites: []string{"nope"}})
+
+	certs := newTestCertReloader(t, TLSConfig{
+		Enabled:      cfg.Enabled,
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+		ClientCAFile: caFile,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	})
+	if certs.minVersion != tls.VersionTLS13 || len(certs.cipherSuites) != 2 ||
+		certs.cipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("min version = %x, cipher suites = %x", certs.minVersion, certs.cipherSuites)
+	}
+
+	tests := map[string]func(cfg *TLSConfig){
+		"no certificate":           func(cfg *TLSConfig) { cfg.CertFile = "" },
+		"no key":                   func(cfg *TLSConfig) { cfg.KeyFile = "" },
+		"missing certificate":      func(cfg *TLSConfig) { cfg.CertFile += ".missing" },
+		"key of another cert":      func(cfg *TLSConfig) { cfg.KeyFile = caFile },
+		"no minimum version":       func(cfg *TLSConfig) { cfg.MinVersion = "" },
+		"minimum version 1.1":      func(cfg *TLSConfig) { cfg.MinVersion = "1.1" },
+		"unknown cipher suite":     func(cfg *TLSConfig) { cfg.CipherSuites = []string{"TLS_MADE_UP"} },
+		"insecure cipher suite":    func(cfg *TLSConfig) { cfg.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} },
+		"missing client CA":        func(cfg *TLSConfig) { cfg.ClientCAFile = caFile + ".missing" },
+		"no certificate in the CA": func(cfg *TLSConfig) { cfg.ClientCAFile = noCAFile },
+		"lowercase cipher suite":   func(cfg *TLSConfig) { cfg.CipherSuites = []string{"tls_aes_128_gcm_sha256"} },
+	}
+
+	for name, change := range tests {
+		invalid := cfg
+		change(&invalid)
+
+		_, err := NewCertReloader("http", invalid, nil, prometheus.NewMetricsRecorder(prometheus.MetricsConfig{}))
+		if !errors.Is(err, ErrTLSConfig) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrTLSConfig)
+		}
+	}
+}
+
+func TestCertReloaderChanged(t *testing.T) {
+	now := time.Now()
+	certs := &CertReloader{modTimes: map[string]time.Time{"cert.pem": now, "key.pem": now}}
+
+	tests := []struct {
+		name     string
+		modTimes map[string]time.Time
+		want     bool
+	}{
+		{name: "unchanged", modTimes: map[string]time.Time{"cert.pem": now, "key.pem": now}},
+		{name: "rewritten", modTimes: map[string]time.Time{"cert.pem": now.Add(time.Second), "key.pem": now}, want: true},
+		{name: "removed", modTimes: map[string]time.Time{"cert.pem": now}, want: true},
+		{name: "added", modTimes: map[string]time.Time{"cert.pem": now, "key.pem": now, "ca.pem": now}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := certs.changed(tt.modTimes); got != tt.want {
+			t.Errorf("%s: changed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCertReloaderReloadsChangedFiles(t *testing.T) {
+	cfg := testTLSConfig(t)
+	cfg.ReloadInterval = 5 * time.Millisecond
+	writeCert(t, cfg, "old")
+
+	certs := newTestCertReloader(t, cfg)
+	addr := serveTLS(t, certs)
+
+	go func() { _ = certs.Run() }()
+	defer certs.Stop()
+
+	if name := servedName(t, addr); name != "old" {
+		t.Fatalf("served %q, want %q", name, "old")
+	}
+
+	writeCert(t, cfg, "new")
+	waitForName(t, addr, "new")
+
+	// A half written pair fails to load and the previous one stays in use.
+	writeFile(t, cfg.KeyFile, []byte("truncated"))
+	time.Sleep(10 * cfg.ReloadInterval)
+
+	if name := servedName(t, addr); name != "new" {
+		t.Errorf("served %q after a failed reload, want %q", name, "new")
+	}
+
+	writeCert(t, cfg, "newer")
+	waitForName(t, addr, "newer")
+}
+
+func TestCertReloaderReloadsOnSIGHUP(t *testing.T) {
+	// Keep the signal from ending the test before Run listens for it.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	cfg := testTLSConfig(t)
+	cfg.ReloadInterval = time.Hour
+	writeCert(t, cfg, "old")
+
+	certs := newTestCertReloader(t, cfg)
+	addr := serveTLS(t, certs)
+
+	go func() { _ = certs.Run() }()
+	defer certs.Stop()
+
+	writeCert(t, cfg, "new")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, addr) != "new" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not reloaded on SIGHUP")
+		}
+
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClientCertGuard(t *testing.T) {
+	metricsRecorder := prometheus.NewMetricsRecorder(prometheus.MetricsConfig{})
+
+	if _, err := NewClientCertGuard(true, newTestCertReloader(t, TLSConfig{}), metricsRecorder); !errors.Is(err, ErrTLSConfig) {
+		t.Errorf("required without tls: err = %v, want %v", err, ErrTLSConfig)
+	}
+
+	if _, err := NewClientCertGuard(true, newTestCertReloader(t, testTLSConfigWithCert(t)), metricsRecorder); !errors.Is(err, ErrTLSConfig) {
+		t.Errorf("required without a client CA: err = %v, want %v", err, ErrTLSConfig)
+	}
+
+	ca := newTestCert(t, "ca", nil)
+	cfg := testTLSConfigWithCert(t)
+	cfg.ClientCAFile = filepath.Join(t.TempDir(), "ca.pem")
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	writeFile(t, cfg.ClientCAFile, ca.pem)
+
+	certs := newTestCertReloader(t, cfg)
+
+	guard, err := NewClientCertGuard(true, certs, metricsRecorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := certs.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fasthttp.Server{Handler: guard.Require(func(ctx *fasthttp.RequestCtx) {})}
+	go func() { _ = server.Serve(ln) }()
+	defer func() { _ = server.Shutdown() }()
+
+	tests := []struct {
+		name       string
+		clientCert *testCert
+		wantStatus int
+	}{
+		{name: "no certificate", wantStatus: http.StatusForbidden},
+		{name: "certificate issued by the client CA", clientCert: newTestCert(t, "admin", ca), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		config := &tls.Config{InsecureSkipVerify: true}
+		if tt.clientCert != nil {
+			config.Certificates = []tls.Certificate{tt.clientCert.tlsCertificate(t)}
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+		resp, err := client.Get("https://" + ln.Addr().String() + "/api/v1/keys")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	// Not required, requests go through without a certificate.
+	optional, err := NewClientCertGuard(false, newTestCertReloader(t, TLSConfig{}), metricsRecorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ctx fasthttp.RequestCtx
+
+	optional.Require(func(ctx *fasthttp.RequestCtx) {})(&ctx)
+
+	if status := ctx.Response.StatusCode(); status != http.StatusOK {
+		t.Errorf("status = %d without a required certificate, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,125 @@
+package hyperloglog
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	MinPrecision     = 4
+	MaxPrecision     = 18
+	DefaultPrecision = 14
+)
+
+var ErrPrecisionMismatch = errors.New("sketches have different precision")
+
+// Sketch is a HyperLogLog cardinality estimator with 2^precision registers.
+// It is not safe for concurrent use.
+type Sketch struct {
+	precision uint8
+	registers []uint8
+}
+
+func New(precision uint8) *Sketch {
+	if precision < MinPrecision {
+		precision = MinPrecision
+	}
+
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+
+	return &Sketch{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+func (s *Sketch) Add(value string) {
+	h := hash(value)
+
+	index := h >> (64 - s.precision)
+	rank := uint8(bits.LeadingZeros64(h<<s.precision|1<<(s.precision-1))) + 1
+
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+// Merge folds other into s, afterwards s estimates the union of both.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.precision != other.precision {
+		return ErrPrecisionMismatch
+	}
+
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+
+	return nil
+}
+
+func (s *Sketch) Clone() *Sketch {
+	registers := make([]uint8, len(s.registers))
+	copy(registers, s.registers)
+
+	return &Sketch{precision: s.precision, registers: registers}
+}
+
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, rank := range s.registers {
+		sum += math.Ldexp(1, -int(rank))
+
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(m) * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/m)
+	}
+}
+
+// hash is FNV-1a followed by the murmur3 finalizer to spread the bits.
+func hash(value string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,104 @@
+package hyperloglog
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func fill(s *Sketch, from, to int) *Sketch {
+	for i := from; i < to; i++ {
+		s.Add("visitor-" + strconv.Itoa(i))
+	}
+
+	return s
+}
+
+// maxError is three standard errors of the estimate, 1.04/sqrt(m).
+func maxError(precision uint8) float64 {
+	return 3 * 1.04 / math.Sqrt(float64(int(1)<<precision))
+}
+
+func TestCount(t *testing.T) {
+	for _, precision := range []uint8{MinPrecision, 12, DefaultPrecision} {
+		for _, n := range []int{1, 10, 100, 1000, 10000, 100000} {
+			count := fill(New(precision), 0, n).Count()
+
+			if got := math.Abs(float64(count)-float64(n)) / float64(n); got > maxError(precision) {
+				t.Errorf("precision %d: count = %d for %d values, error %.3f above %.3f",
+					precision, count, n, got, maxError(precision))
+			}
+		}
+	}
+
+	if count := New(DefaultPrecision).Count(); count != 0 {
+		t.Errorf("empty sketch count = %d", count)
+	}
+}
+
+func TestCountIgnoresDuplicates(t *testing.T) {
+	s := New(DefaultPrecision)
+
+	for i := 0; i < 10; i++ {
+		fill(s, 0, 1000)
+	}
+
+	if count := s.Count(); count != fill(New(DefaultPrecision), 0, 1000).Count() {
+		t.Errorf("count = %d after adding the same values ten times", count)
+	}
+}
+
+func TestNewClampsPrecision(t *testing.T) {
+	for precision, want := range map[uint8]int{0: 1 << MinPrecision, 12: 1 << 12, 30: 1 << MaxPrecision} {
+		if got := len(New(precision).registers); got != want {
+			t.Errorf("New(%d) has %d registers, want %d", precision, got, want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := fill(New(12), 0, 6000)
+	b := fill(New(12), 4000, 10000)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merging is lossless, the union sketch is the one of all the values.
+	if count, want := a.Count(), fill(New(12), 0, 10000).Count(); count != want {
+		t.Errorf("merged count = %d, want %d", count, want)
+	}
+
+	if got := math.Abs(float64(a.Count())-10000) / 10000; got > maxError(12) {
+		t.Errorf("merged count = %d for 10000 values", a.Count())
+	}
+
+	if count := b.Count(); count != fill(New(12), 4000, 10000).Count() {
+		t.Errorf("merge changed the other sketch, count = %d", count)
+	}
+}
+
+func TestMergePrecisionMismatch(t *testing.T) {
+	a := fill(New(12), 0, 100)
+	want := a.Count()
+
+	if err := a.Merge(fill(New(14), 100, 1000)); !errors.Is(err, ErrPrecisionMismatch) {
+		t.Fatalf("err = %v, want %v", err, ErrPrecisionMismatch)
+	}
+
+	if count := a.Count(); count != want {
+		t.Errorf("count = %d after a failed merge, want %d", count, want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	s := fill(New(12), 0, 100)
+	clone := s.Clone()
+
+	fill(clone, 100, 1000)
+
+	if count := s.Count(); count != fill(New(12), 0, 100).Count() {
+		t.Errorf("adding to the clone changed the sketch, count = %d", count)
+	}
+}
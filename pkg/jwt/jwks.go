@@ -0,0 +1,319 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	DefaultRefreshInterval    = time.Hour
+	DefaultMinRefreshInterval = time.Minute
+	DefaultFetchTimeout       = 5 * time.Second
+
+	maxJWKSSize = 1 << 20
+)
+
+var (
+	ErrKeyNotFound = errors.New("signing key not found")
+	ErrJWKSStatus  = errors.New("unexpected JWKS response status")
+)
+
+type KeySetConfig struct {
+	// Path of a local JWKS file or an http(s) URL serving one.
+	Source string
+	// Keys are reloaded after this long.
+	RefreshInterval time.Duration
+	// Unknown key ids trigger a reload at most this often.
+	MinRefreshInterval time.Duration
+	FetchTimeout       time.Duration
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet caches the signing keys of a JWKS. Keys are reloaded when they get
+// old or when a token names a key id that is not known yet, which is how
+// key rotation is picked up. A failed reload keeps the previous keys.
+// Reloads run outside the lock, one at a time, and the cached keys keep
+// being served while they do.
+type KeySet struct {
+	cfg         KeySetConfig
+	client      *http.Client
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// The reload in flight, nil when there is none.
+	reload *keySetReload
+}
+
+type keySetReload struct {
+	done chan struct{}
+	err  error
+}
+
+func NewKeySet(cfg KeySetConfig) *KeySet {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = DefaultMinRefreshInterval
+	}
+
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = DefaultFetchTimeout
+	}
+
+	return &KeySet{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.FetchTimeout},
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+// Refresh reloads the keys from the source and waits for them.
+func (s *KeySet) Refresh() error {
+	s.mu.Lock()
+	reload := s.startReload(time.Now())
+	s.mu.Unlock()
+
+	<-reload.done
+
+	return reload.err
+}
+
+// Key returns the key with the id. Tokens without a key id are accepted
+// when the set holds a single key. Stale keys are returned right away and
+// reloaded in the background, only unknown key ids wait for the reload.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+
+	now := time.Now()
+	key, ok := s.lookup(kid)
+
+	var reload *keySetReload
+
+	stale := now.Sub(s.fetchedAt) >= s.cfg.RefreshInterval
+	if (!ok || stale) && (s.reload != nil || now.Sub(s.attemptedAt) >= s.cfg.MinRefreshInterval) {
+		reload = s.startReload(now)
+	}
+
+	s.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if reload == nil {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+	}
+
+	<-reload.done
+
+	s.mu.Lock()
+	key, ok = s.lookup(kid)
+	s.mu.Unlock()
+
+	switch {
+	case ok:
+		return key, nil
+	case reload.err != nil:
+		return nil, reload.err
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+	}
+}
+
+func (s *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+
+	return key, ok
+}
+
+// startReload returns the reload in flight, starting one when there is
+// none. It must be called with s.mu held.
+func (s *KeySet) startReload(now time.Time) *keySetReload {
+	if s.reload != nil {
+		return s.reload
+	}
+
+	reload := &keySetReload{done: make(chan struct{})}
+	s.reload = reload
+	s.attemptedAt = now
+
+	go func() {
+		keys, err := s.fetch()
+
+		s.mu.Lock()
+		if err == nil {
+			s.keys = keys
+			s.fetchedAt = now
+		}
+
+		reload.err = err
+		s.reload = nil
+		s.mu.Unlock()
+
+		close(reload.done)
+	}()
+
+	return reload
+}
+
+func (s *KeySet) fetch() (map[string]crypto.PublicKey, error) {
+	data, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(data)
+}
+
+func (s *KeySet) load() ([]byte, error) {
+	if !strings.HasPrefix(s.cfg.Source, "http://") && !strings.HasPrefix(s.cfg.Source, "https://") {
+		return os.ReadFile(s.cfg.Source)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.FetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrJWKSStatus, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS keeps the RSA and P-256 signing keys, other keys are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var (
+			key crypto.PublicKey
+			err error
+		)
+
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+
+			key, err = k.ecKey()
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("parse JWK %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, ErrMalformed
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecKey() (*ecdsa.PublicKey, error) {
+	x, err := decodeInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := elliptic.P256()
+	if !curve.IsOnCurve(x, y) {
+		return nil, ErrMalformed
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,220 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// jwksServer serves the JWKS of the keys it holds, counting fetches. While
+// blocked, fetches wait until it is released.
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*ecdsa.PrivateKey
+	blocked chan struct{}
+	fetches int32
+}
+
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	s := &jwksServer{keys: map[string]*ecdsa.PrivateKey{}}
+	s.add(t, kids...)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+
+		s.mu.Lock()
+		blocked := s.blocked
+		s.mu.Unlock()
+
+		if blocked != nil {
+			<-blocked
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+
+		for kid, key := range s.keys {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "EC",
+				Kid: kid,
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+				Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+			})
+		}
+
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jwksServer) add(t *testing.T, kids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, kid := range kids {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.keys[kid] = key
+	}
+}
+
+// block holds fetches until the returned function is called.
+func (s *jwksServer) block() func() {
+	blocked := make(chan struct{})
+
+	s.mu.Lock()
+	s.blocked = blocked
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.blocked = nil
+		s.mu.Unlock()
+
+		close(blocked)
+	}
+}
+
+func (s *jwksServer) fetchCount() int32 {
+	return atomic.LoadInt32(&s.fetches)
+}
+
+func TestKeySetServesCachedKeysWhileReloading(t *testing.T) {
+	server := newJWKSServer(t, "k1")
+	keys := NewKeySet(KeySetConfig{
+		Source:             server.URL,
+		RefreshInterval:    time.Millisecond,
+		MinRefreshInterval: time.Nanosecond,
+	})
+
+	if err := keys.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	release := server.block()
+	defer release()
+
+	time.Sleep(2 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		start := time.Now()
+
+		if _, err := keys.Key("k1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if waited := time.Since(start); waited > 100*time.Millisecond {
+			t.Fatalf("stale key served after %v, want right away", waited)
+		}
+	}
+
+	// The first stale lookup started a reload, the others found it running.
+	for server.fetchCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if fetches := server.fetchCount(); fetches != 2 {
+		t.Errorf("fetches = %d, want one reload in flight", fetches)
+	}
+}
+
+func TestKeySetReloadsUnknownKeysOnce(t *testing.T) {
+	server := newJWKSServer(t, "k1")
+	keys := NewKeySet(KeySetConfig{Source: server.URL, MinRefreshInterval: time.Hour})
+
+	if err := keys.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.add(t, "k2")
+	release := server.block()
+
+	// A token for the new key arrives once rotation may be picked up.
+	keys.mu.Lock()
+	keys.attemptedAt = time.Time{}
+	keys.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 10)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := keys.Key("k2")
+			errs <- err
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	release()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("key not found after the reload: %v", err)
+		}
+	}
+
+	if fetches := server.fetchCount(); fetches != 2 {
+		t.Errorf("fetches = %d, want a single reload for every waiting lookup", fetches)
+	}
+
+	// Reloads for unknown ids are rate limited.
+	if _, err := keys.Key("k3"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("err = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	if fetches := server.fetchCount(); fetches != 2 {
+		t.Errorf("fetches = %d, unknown key reloaded before the minimum interval", fetches)
+	}
+}
+
+func TestKeySetKeepsKeysWhenReloadFails(t *testing.T) {
+	server := newJWKSServer(t, "k1")
+	keys := NewKeySet(KeySetConfig{Source: server.URL, RefreshInterval: time.Millisecond, MinRefreshInterval: time.Nanosecond})
+
+	if err := keys.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	time.Sleep(2 * time.Millisecond)
+
+	if err := keys.Refresh(); err == nil {
+		t.Fatal("reload from a closed server succeeded")
+	}
+
+	if _, err := keys.Key("k1"); err != nil {
+		t.Errorf("cached key dropped after a failed reload: %v", err)
+	}
+
+	if _, err := keys.Key("k2"); err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("err = %v, want the reload error", err)
+	}
+}
@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+
+	es256KeySize = 32
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrExpired          = errors.New("token expired")
+	ErrMissingExpiry    = errors.New("token has no expiry")
+	ErrNotYetValid      = errors.New("token not valid yet")
+	ErrInvalidIssuer    = errors.New("unexpected token issuer")
+	ErrInvalidAudience  = errors.New("unexpected token audience")
+)
+
+// Claims are the decoded claims of a verified token.
+type Claims map[string]interface{}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// KeyFunc returns the public key for the key id in the token header.
+type KeyFunc func(kid string) (crypto.PublicKey, error)
+
+type VerifyOptions struct {
+	// Tokens must carry exactly these iss and aud when they are not empty.
+	Issuer   string
+	Audience string
+	// Clock skew tolerated for exp and nbf.
+	Leeway time.Duration
+}
+
+// Verify checks the signature and the registered claims of a compact JWS
+// token and returns its claims. Only RS256 and ES256 are accepted, and
+// tokens without an exp claim are rejected.
+func Verify(token string, keyFunc KeyFunc, opts VerifyOptions) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+
+	key, err := keyFunc(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err = verifySignature(h.Alg, key, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err = claims.validate(time.Now(), opts); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) error {
+	switch alg {
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s with a non-RSA key", ErrUnsupportedAlg, alg)
+		}
+
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature) != nil {
+			return ErrInvalidSignature
+		}
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != es256KeySize*8 {
+			return fmt.Errorf("%w: %s with a non P-256 key", ErrUnsupportedAlg, alg)
+		}
+
+		if len(signature) != 2*es256KeySize {
+			return ErrInvalidSignature
+		}
+
+		r := new(big.Int).SetBytes(signature[:es256KeySize])
+		s := new(big.Int).SetBytes(signature[es256KeySize:])
+
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlg, alg)
+	}
+
+	return nil
+}
+
+func (c Claims) validate(now time.Time, opts VerifyOptions) error {
+	exp, ok, err := c.time("exp")
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrMissingExpiry
+	}
+
+	if now.After(exp.Add(opts.Leeway)) {
+		return ErrExpired
+	}
+
+	nbf, ok, err := c.time("nbf")
+	if err != nil {
+		return err
+	}
+
+	if ok && now.Add(opts.Leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+
+	if opts.Issuer != "" && c.String("iss") != opts.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if opts.Audience != "" && !contains(c.audience(), opts.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// String returns the claim when it is a string.
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+
+	return value
+}
+
+// Strings returns a claim holding a list of strings. A single string is
+// split on spaces, the way OAuth scopes are sent.
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// audience returns the aud claim, a single string or a list of them. Unlike
+// Strings a single string is never split.
+func (c Claims) audience() []string {
+	if aud, ok := c["aud"].(string); ok {
+		return []string{aud}
+	}
+
+	return c.Strings("aud")
+}
+
+// time returns a NumericDate claim and whether the token has it, claims of
+// another type make the token malformed.
+func (c Claims) time(name string) (time.Time, bool, error) {
+	value, found := c[name]
+	if !found {
+		return time.Time{}, false, nil
+	}
+
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrMalformed, name)
+	}
+
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,324 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+type signer func(digest []byte) []byte
+
+func rs256(t *testing.T, key *rsa.PrivateKey) signer {
+	return func(digest []byte) []byte {
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signature
+	}
+}
+
+// es256 signs the way JWS wants, r and s as fixed size big-endian halves.
+func es256(t *testing.T, key *ecdsa.PrivateKey) signer {
+	return func(digest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature := make([]byte, 2*es256KeySize)
+		r.FillBytes(signature[:es256KeySize])
+		s.FillBytes(signature[es256KeySize:])
+
+		return signature
+	}
+}
+
+// es256DER signs with the ASN.1 encoding crypto/ecdsa uses, which JWS does
+// not allow.
+func es256DER(t *testing.T, key *ecdsa.PrivateKey) signer {
+	return func(digest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signature
+	}
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}, signDigest signer) string {
+	signingInput := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) +
+		"." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signDigest(digest[:]))
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]crypto.PublicKey{
+		"rsa": &rsaKey.PublicKey,
+		"ec":  &ecKey.PublicKey,
+	}
+	keyFunc := func(kid string) (crypto.PublicKey, error) {
+		key, ok := keys[kid]
+		if !ok {
+			return nil, ErrKeyNotFound
+		}
+
+		return key, nil
+	}
+
+	now := time.Now()
+	valid := func(extra map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://sso.example.com",
+			"aud": "url-shortener",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+
+		for name, value := range extra {
+			if value == nil {
+				delete(claims, name)
+
+				continue
+			}
+
+			claims[name] = value
+		}
+
+		return claims
+	}
+	opts := VerifyOptions{
+		Issuer:   "https://sso.example.com",
+		Audience: "url-shortener",
+		Leeway:   30 * time.Second,
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		opts    VerifyOptions
+		wantErr error
+	}{
+		{
+			name:  "RS256",
+			token: sign(t, AlgRS256, "rsa", valid(nil), rs256(t, rsaKey)),
+			opts:  opts,
+		},
+		{
+			name:  "ES256 with r and s concatenated",
+			token: sign(t, AlgES256, "ec", valid(nil), es256(t, ecKey)),
+			opts:  opts,
+		},
+		{
+			name:    "ES256 with an ASN.1 signature",
+			token:   sign(t, AlgES256, "ec", valid(nil), es256DER(t, ecKey)),
+			opts:    opts,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "ES256 signed by another key",
+			token:   sign(t, AlgES256, "ec", valid(nil), es256(t, otherKey)),
+			opts:    opts,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "unknown key id",
+			token:   sign(t, AlgES256, "rotated", valid(nil), es256(t, ecKey)),
+			opts:    opts,
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name:    "RS256 header on an EC key",
+			token:   sign(t, AlgRS256, "ec", valid(nil), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrUnsupportedAlg,
+		},
+		{
+			name:    "ES256 header on an RSA key",
+			token:   sign(t, AlgES256, "rsa", valid(nil), es256(t, ecKey)),
+			opts:    opts,
+			wantErr: ErrUnsupportedAlg,
+		},
+		{
+			name:    "HS256",
+			token:   sign(t, "HS256", "rsa", valid(nil), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrUnsupportedAlg,
+		},
+		{
+			name:    "none",
+			token:   sign(t, "none", "rsa", valid(nil), func([]byte) []byte { return nil }),
+			opts:    opts,
+			wantErr: ErrUnsupportedAlg,
+		},
+		{
+			name:    "expired",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrExpired,
+		},
+		{
+			name:  "expired within the leeway",
+			token: sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()}), rs256(t, rsaKey)),
+			opts:  opts,
+		},
+		{
+			name:    "missing exp",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"exp": nil}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrMissingExpiry,
+		},
+		{
+			name:    "exp not a number",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"exp": "tomorrow"}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "not valid yet",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrNotYetValid,
+		},
+		{
+			name:  "nbf within the leeway",
+			token: sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"nbf": now.Add(10 * time.Second).Unix()}), rs256(t, rsaKey)),
+			opts:  opts,
+		},
+		{
+			name:    "wrong issuer",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"iss": "https://evil.example.com"}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:    "missing issuer",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"iss": nil}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:  "issuer not configured",
+			token: sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"iss": nil}), rs256(t, rsaKey)),
+			opts:  VerifyOptions{Audience: opts.Audience},
+		},
+		{
+			name:  "audience in a list",
+			token: sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"aud": []string{"other", "url-shortener"}}), rs256(t, rsaKey)),
+			opts:  opts,
+		},
+		{
+			name:    "wrong audience",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"aud": "other"}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "audience string is not split",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"aud": "other url-shortener"}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "missing audience",
+			token:   sign(t, AlgRS256, "rsa", valid(map[string]interface{}{"aud": nil}), rs256(t, rsaKey)),
+			opts:    opts,
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "two segments",
+			token:   "eyJhbGciOiJSUzI1NiJ9.e30",
+			opts:    opts,
+			wantErr: ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.token, keyFunc, tt.opts)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+
+			if got := claims.String("sub"); got != "alice" {
+				t.Errorf("sub = %q, want alice", got)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedPayload(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := sign(t, AlgES256, "", map[string]interface{}{"sub": "alice", "exp": exp}, es256(t, key))
+	forged := sign(t, AlgES256, "", map[string]interface{}{"sub": "admin", "exp": exp}, es256(t, key))
+
+	// Keep the header and signature of the first token on the payload of
+	// the second.
+	parts := strings.Split(token, ".")
+	parts[1] = strings.Split(forged, ".")[1]
+
+	keyFunc := func(string) (crypto.PublicKey, error) { return &key.PublicKey, nil }
+
+	if _, err = Verify(strings.Join(parts, "."), keyFunc, VerifyOptions{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+	}
+}